@@ -0,0 +1,59 @@
+package policy
+
+// AllowedActions returns every action of the statements for the resource that the user is allowed to perform,
+// in the order they first appear in the policies. The action set on the validator is not used.
+//
+// Each action is checked the same way as IsAccessAllowed, so "Deny" statements, conditions,
+// the ValidationOverrider and the post validators are respected.
+func (pv *policyValidator) AllowedActions() ([]string, error) {
+	if pv.Err != nil {
+		return nil, pv.Err
+	}
+
+	var statements []Statement
+	var actions []string
+	seen := make(map[string]bool)
+	for _, stmt := range extractStatements(pv.Policies) {
+		if stmt.Resource != pv.resource.Resource {
+			continue
+		}
+		statements = append(statements, stmt)
+		for _, action := range stmt.Actions {
+			if !seen[action] {
+				seen[action] = true
+				actions = append(actions, action)
+			}
+		}
+	}
+
+	// Properties are loaded once for all actions.
+	res, err := pv.loadResourceProperties(statements, pv.resource)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, len(actions))
+	for i, action := range actions {
+		resources[i] = Resource{
+			Resource:   res.Resource,
+			Action:     action,
+			Properties: res.Properties,
+		}
+	}
+
+	results, err := pv.IsAccessAllowedBatch(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]string, 0, len(actions))
+	for i, result := range results {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		if result.Allowed {
+			allowed = append(allowed, actions[i])
+		}
+	}
+	return allowed, nil
+}
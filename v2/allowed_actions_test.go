@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func allowedActionsPolicies() []Policy {
+	return []Policy{
+		{
+			PolicyID: "policy-A",
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read", "act:::doc:write", "act:::doc:delete"},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:publish"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:reviewed": {BooleanEqual: ptr(true)},
+						},
+					},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::folder",
+					Actions:  []string{"act:::folder:read"},
+				},
+			},
+		},
+		{
+			PolicyID: "policy-B",
+			Statements: []Statement{
+				{
+					Effect:   statementEffectDeny,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:delete", "act:::doc:write"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:locked": {BooleanEqual: ptr(true)},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestAllowedActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		reviewed bool
+		locked   bool
+		want     []string
+	}{
+		{
+			name: "not reviewed, not locked",
+			want: []string{"act:::doc:read", "act:::doc:write", "act:::doc:delete"},
+		},
+		{
+			name:     "reviewed, not locked",
+			reviewed: true,
+			want:     []string{"act:::doc:read", "act:::doc:write", "act:::doc:delete", "act:::doc:publish"},
+		},
+		{
+			name:     "reviewed and locked",
+			reviewed: true,
+			locked:   true,
+			want:     []string{"act:::doc:read", "act:::doc:publish"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = allowedActionsPolicies()
+			pv.SetResource("res:::doc")
+			pv.AddPropertyBoolean("prop:::doc:reviewed", tt.reviewed)
+			pv.AddPropertyBoolean("prop:::doc:locked", tt.locked)
+
+			// Act
+			got, err := pv.AllowedActions()
+
+			// Assert
+			if err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, but want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedActions_NoStatements(t *testing.T) {
+	pv := New()
+	pv.Policies = allowedActionsPolicies()
+	pv.SetResource("res:::unknown")
+
+	got, err := pv.AllowedActions()
+
+	if err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want no actions, but got %v", got)
+	}
+}
+
+func TestAllowedActions_ResourcePropertyGetter(t *testing.T) {
+	// Arrange
+	getter := &MockResourceGetter{Values: map[string]interface{}{
+		"prop:::doc:reviewed": true,
+		"prop:::doc:locked":   true,
+	}}
+	pv := New()
+	pv.Policies = allowedActionsPolicies()
+	pv.ResourcePropertyGetter = getter
+	pv.SetResource("res:::doc")
+
+	// Act
+	got, err := pv.AllowedActions()
+
+	// Assert
+	if err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+	want := []string{"act:::doc:read", "act:::doc:publish"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, but want %v", got, want)
+	}
+	for key, count := range getter.Calls {
+		if count != 1 {
+			t.Errorf("%s was loaded %d times, want 1", key, count)
+		}
+	}
+}
+
+func TestAllowedActions_Errors(t *testing.T) {
+	t.Run("validator with Error, expect error", func(t *testing.T) {
+		pv := New()
+		pv.SetError(errors.New("error"))
+
+		_, err := pv.AllowedActions()
+
+		if err == nil {
+			t.Error("want error, but got nil")
+		}
+	})
+
+	t.Run("post validator error, expect error", func(t *testing.T) {
+		pv := New()
+		pv.Policies = allowedActionsPolicies()
+		pv.SetResource("res:::doc")
+		pv.AddPostExecutor(&MockPostValidator{Result: DENIED, Error: errors.New("error")})
+
+		got, err := pv.AllowedActions()
+
+		if err == nil {
+			t.Error("want error, but got nil")
+		}
+		if got != nil {
+			t.Errorf("want nil, but got %v", got)
+		}
+	})
+}
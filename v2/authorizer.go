@@ -0,0 +1,33 @@
+package policy
+
+import "context"
+
+// Authorizer checks if the user is allowed to perform the action on the resource.
+// It is the interface used by the middlewares, so that a validator, a PolicyLoader or a remote service can be used.
+type Authorizer interface {
+	Authorize(ctx context.Context, user UserPropertyGetter, res Resource) (bool, error)
+}
+
+// AuthorizerFunc is a function that can be used as an Authorizer.
+type AuthorizerFunc func(ctx context.Context, user UserPropertyGetter, res Resource) (bool, error)
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, user UserPropertyGetter, res Resource) (bool, error) {
+	return f(ctx, user, res)
+}
+
+// Authorize validates the user and the resource with the policies, validation functions and post validators of the validator.
+// The user and the resource replace those of the validator for this call only,
+// so a validator can be configured once and used by concurrent requests.
+// The user can be nil, e.g. for an unauthenticated request, then no condition on a user property matches.
+func (pv *policyValidator) Authorize(ctx context.Context, user UserPropertyGetter, res Resource) (bool, error) {
+	validator := *pv
+	validator.UserPropertyGetter = user
+	validator.resource = res
+	validator.ctx = ctx
+	return validator.IsAccessAllowed()
+}
+
+// Authorize validates the user and the resource with the current policies.
+func (l *PolicyLoader) Authorize(ctx context.Context, user UserPropertyGetter, res Resource) (bool, error) {
+	return l.New().Authorize(ctx, user, res)
+}
@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	// Arrange
+	pv := New()
+	pv.Policies = []Policy{
+		{
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+						},
+					},
+				},
+			},
+		},
+	}
+	pv.SetResource("res:::ignored")
+
+	tests := []struct {
+		name   string
+		userID string
+		owner  string
+		want   bool
+	}{
+		{"owner", "u1", "u1", true},
+		{"not owner", "u2", "u1", false},
+	}
+
+	var wg sync.WaitGroup
+	for _, tt := range tests {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res := Resource{
+					Resource:   "res:::doc",
+					Action:     "act:::doc:read",
+					Properties: Property{String: map[string]string{"prop:::doc:owner": tt.owner}},
+				}
+				user := &MockUserGetter{UserValue: map[string]string{"user:::id": tt.userID}}
+
+				// Act
+				got, err := pv.Authorize(context.Background(), user, res)
+
+				// Assert
+				if err != nil || got != tt.want {
+					t.Errorf("%s: want %v, but got %v, %v", tt.name, tt.want, got, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if pv.UserPropertyGetter != nil || pv.resource.Resource != "res:::ignored" {
+		t.Error("want the validator unchanged")
+	}
+}
+
+func TestAuthorize_NilUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparator Comparator
+	}{
+		{"user property", Comparator{UserPropEqual: ptr("user:::id")}},
+		{"user argument", Comparator{ValidationFunc: &ValidationFunc{Function: "equal", UserArg: ptr("user:::id")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.SetValidationFunction("equal", func(a, b string) (bool, error) { return a == b, nil })
+			pv.Policies = []Policy{
+				{
+					Statements: []Statement{
+						{
+							Effect:     statementEffectAllow,
+							Resource:   "res:::doc",
+							Actions:    []string{"act:::doc:read"},
+							Conditions: &Condition{MustHaveAll: map[string]Comparator{"prop:::doc:owner": tt.comparator}},
+						},
+					},
+				},
+			}
+			res := Resource{
+				Resource:   "res:::doc",
+				Action:     "act:::doc:read",
+				Properties: Property{String: map[string]string{"prop:::doc:owner": ""}},
+			}
+
+			// Act
+			got, err := pv.Authorize(context.Background(), nil, res)
+
+			// Assert
+			if got || err != nil {
+				t.Errorf("want false, but got %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestPolicyLoader_Authorize(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policies.json")
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	l, err := NewPolicyLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var authorizer Authorizer = l
+
+	// Act
+	got, err := authorizer.Authorize(context.Background(), nil, Resource{Resource: "res:::doc", Action: "act:::doc:read"})
+
+	// Assert
+	if !got || err != nil {
+		t.Errorf("want true, but got %v, %v", got, err)
+	}
+}
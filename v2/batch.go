@@ -0,0 +1,100 @@
+package policy
+
+import "time"
+
+// BatchResult is the decision for one resource of IsAccessAllowedBatch.
+type BatchResult struct {
+	Allowed bool
+	Err     error
+}
+
+// IsAccessAllowedBatch checks if the user is allowed to perform the action of each resource,
+// the results are in the same order as the resources.
+//
+// The statements are merged and checked once, statements matching a resource and action are shared
+// by all resources with the same resource and action, and each user property is read once.
+// The resource, action and properties set on the validator are not used, and neither is
+// the ResourcePropertyGetter, since it loads the properties of a single resource: the resources must have
+// all properties of the statements. The Coverage counts the hits of each resource, like separate validations.
+//
+// With a DecisionLogger, each resource is checked and recorded like ExplainAccess, without sharing the statements.
+//
+// The error is returned when no resource can be checked, e.g. when a statement is invalid.
+func (pv *policyValidator) IsAccessAllowedBatch(resources []Resource) ([]BatchResult, error) {
+	if pv.Err != nil {
+		return nil, pv.Err
+	}
+
+	if pv.ValidationOverrider == nil {
+		if err := checkValidPolicies(pv.Policies); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := *pv
+	batch.ResourcePropertyGetter = nil
+	if pv.UserPropertyGetter != nil {
+		batch.UserPropertyGetter = &cachedUserPropertyGetter{
+			getter: pv.UserPropertyGetter,
+			values: make(map[string]string),
+		}
+	}
+
+	type resourceAction struct {
+		resource string
+		action   string
+	}
+	matchedStatements := make(map[resourceAction][]policyStatement)
+
+	decide := func(batch *policyValidator) (bool, error) {
+		res := batch.resource
+
+		if batch.DecisionLogger != nil {
+			explanation, err := batch.explainAndLog()
+			return explanation.Allowed, err
+		}
+		if batch.ValidationOverrider != nil {
+			return batch.ValidationOverrider.OverridePolicyValidation(batch.Policies, batch.UserPropertyGetter, res)
+		}
+
+		key := resourceAction{resource: res.Resource, action: res.Action}
+		matched, ok := matchedStatements[key]
+		if !ok {
+			matched = batch.filterWithResourceAndAction(res)
+			matchedStatements[key] = matched
+		} else {
+			// the Coverage counts the statements of each resource, like those of separate validations
+			for _, stmt := range matched {
+				batch.Coverage.recordStatement(stmt.Statement)
+			}
+		}
+
+		explanation, err := batch.decide(matched, res, false)
+		return explanation.Allowed, err
+	}
+
+	results := make([]BatchResult, len(resources))
+	for i, res := range resources {
+		start := time.Now()
+		batch.resource = res
+		results[i].Allowed, results[i].Err = batch.traceDecision(res, decide)
+		batch.observeDecision(res, results[i].Allowed, results[i].Err, start)
+	}
+
+	return results, nil
+}
+
+// cachedUserPropertyGetter reads each user property once.
+type cachedUserPropertyGetter struct {
+	getter UserPropertyGetter
+	values map[string]string
+}
+
+func (c *cachedUserPropertyGetter) GetUserProperty(key string) string {
+	if value, ok := c.values[key]; ok {
+		return value
+	}
+	value := c.getter.GetUserProperty(key)
+	c.values[key] = value
+	return value
+}
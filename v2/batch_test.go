@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type CountingUserGetter struct {
+	UserValue map[string]string
+	Calls     map[string]int
+}
+
+func (mock *CountingUserGetter) GetUserProperty(key string) string {
+	if mock.Calls == nil {
+		mock.Calls = make(map[string]int)
+	}
+	mock.Calls[key]++
+	return mock.UserValue[key]
+}
+
+func TestIsAccessAllowedBatch(t *testing.T) {
+	// Arrange
+	b, _ := os.ReadFile("test_data/is_access_allowed/1policy_full_conditions.json")
+	p, err := ParsePolicyArray(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := []Resource{
+		{
+			Resource: "res:::resource_1",
+			Action:   "act:::resource_1:action_1",
+			Properties: Property{
+				String:  map[string]string{"prop:::resource_1:prop_1": "hello"},
+				Integer: map[string]int{"prop:::resource_1:prop_3": 1},
+				Boolean: map[string]bool{"prop:::resource_1:prop_4": true},
+			},
+		},
+		{
+			Resource: "res:::resource_1",
+			Action:   "act:::resource_1:action_1",
+			Properties: Property{
+				String:  map[string]string{"prop:::resource_1:prop_1": "hello"},
+				Integer: map[string]int{"prop:::resource_1:prop_3": 2},
+				Boolean: map[string]bool{"prop:::resource_1:prop_4": true},
+			},
+		},
+		{
+			Resource: "res:::resource_2",
+			Action:   "act:::resource_2:action_1",
+			Properties: Property{
+				String:  map[string]string{"prop:::resource_2:prop_1": "hello"},
+				Float:   map[string]float64{"prop:::resource_2:prop_3": 1.1},
+				Boolean: map[string]bool{"prop:::resource_2:prop_4": false},
+			},
+		},
+		{
+			Resource: "res:::resource_3",
+			Action:   "act:::resource_3:action_1",
+		},
+	}
+	want := []bool{ALLOWED, DENIED, DENIED, DENIED}
+	pv := New()
+	pv.Policies = p
+
+	// Act
+	results, err := pv.IsAccessAllowedBatch(resources)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, but want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("[%d] want nil, but got %v", i, result.Err)
+		}
+		if result.Allowed != want[i] {
+			t.Errorf("[%d] got %v, but want %v", i, result.Allowed, want[i])
+		}
+	}
+}
+
+func TestIsAccessAllowedBatch_UserPropertiesReadOnce(t *testing.T) {
+	// Arrange
+	userGetter := &CountingUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+	pv := New()
+	pv.UserPropertyGetter = userGetter
+	pv.Policies = []Policy{
+		{
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+						},
+					},
+				},
+			},
+		},
+	}
+	var resources []Resource
+	for _, owner := range []string{"u1", "u2", "u1", "u3"} {
+		resources = append(resources, Resource{
+			Resource:   "res:::doc",
+			Action:     "act:::doc:read",
+			Properties: Property{String: map[string]string{"prop:::doc:owner": owner}},
+		})
+	}
+	want := []bool{ALLOWED, DENIED, ALLOWED, DENIED}
+
+	// Act
+	results, err := pv.IsAccessAllowedBatch(resources)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	for i, result := range results {
+		if result.Allowed != want[i] {
+			t.Errorf("[%d] got %v, but want %v", i, result.Allowed, want[i])
+		}
+	}
+	if userGetter.Calls["user:::id"] != 1 {
+		t.Errorf("want user property read once, but got %d", userGetter.Calls["user:::id"])
+	}
+	if pv.UserPropertyGetter != userGetter {
+		t.Error("want user property getter of validator unchanged")
+	}
+}
+
+func TestIsAccessAllowedBatch_Errors(t *testing.T) {
+	t.Run("validator with Error, expect error", func(t *testing.T) {
+		pv := New()
+		pv.SetError(errors.New("error"))
+
+		results, err := pv.IsAccessAllowedBatch([]Resource{{}})
+
+		if err == nil {
+			t.Error("want error, but got nil")
+		}
+		if results != nil {
+			t.Errorf("want nil results, but got %v", results)
+		}
+	})
+
+	t.Run("invalid effect, expect error", func(t *testing.T) {
+		pv := New()
+		pv.Policies = []Policy{{Statements: []Statement{{Effect: "Invalid Effect"}}}}
+
+		_, err := pv.IsAccessAllowedBatch([]Resource{{}})
+
+		if err == nil {
+			t.Error("want error, but got nil")
+		}
+	})
+
+	t.Run("post validator error, expect error per resource", func(t *testing.T) {
+		pv := New()
+		pv.Policies = []Policy{
+			{
+				Statements: []Statement{
+					{Effect: statementEffectAllow, Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+				},
+			},
+		}
+		pv.AddPostExecutor(&MockPostValidator{Result: DENIED, Error: errors.New("error")})
+
+		results, err := pv.IsAccessAllowedBatch([]Resource{
+			{Resource: "res:::doc", Action: "act:::doc:read"},
+			{Resource: "res:::doc", Action: "act:::doc:write"},
+		})
+
+		if err != nil {
+			t.Fatalf("want nil, but got %v", err)
+		}
+		if results[0].Allowed != DENIED || results[0].Err == nil {
+			t.Errorf("want DENIED with error, but got %v", results[0])
+		}
+		if results[1].Allowed != DENIED || results[1].Err != nil {
+			t.Errorf("want DENIED without error, but got %v", results[1])
+		}
+	})
+}
+
+func TestIsAccessAllowedBatch_ValidationOverrider(t *testing.T) {
+	// Arrange
+	overrider := &MockValidationOverrider{Result: ALLOWED}
+	pv := New()
+	pv.ValidationOverrider = overrider
+
+	// Act
+	results, err := pv.IsAccessAllowedBatch([]Resource{{Resource: "res:::doc"}, {Resource: "res:::doc"}})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	for i, result := range results {
+		if result.Allowed != ALLOWED {
+			t.Errorf("[%d] got %v, but want %v", i, result.Allowed, ALLOWED)
+		}
+	}
+	if !overrider.WasCalled {
+		t.Error("want overrider called")
+	}
+}
+
+func TestIsAccessAllowedBatch_Coverage(t *testing.T) {
+	// Arrange
+	policies := coveragePolicies()
+	coverage := NewCoverage()
+	coverage.Add(policies)
+	pv := New()
+	pv.Policies = policies
+	pv.Coverage = coverage
+	resources := []Resource{
+		{Resource: "res:::doc", Action: "act:::doc:read"},
+		{Resource: "res:::doc", Action: "act:::doc:read"},
+		{Resource: "res:::doc", Action: "act:::doc:delete"},
+	}
+
+	// Act
+	_, err := pv.IsAccessAllowedBatch(resources)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	var got []int
+	for _, sc := range coverage.Report().Policies[0].Statements {
+		got = append(got, sc.Hits)
+	}
+	if want := []int{2, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+}
@@ -0,0 +1,121 @@
+// Command policy-pdp serves the decisions of the policies of a store over HTTP, see package pdp for the API.
+//
+//	policy-pdp -dir ./policies -addr :8080
+//	policy-pdp -sqlite ./policies.db -addr :8080
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/golfz/policy/v2"
+	"github.com/golfz/policy/v2/pdp"
+	"github.com/golfz/policy/v2/sqlstore"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("policy-pdp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	dir := flags.String("dir", "", "directory of policy JSON files, see policy.NewDirectoryPolicyStore")
+	sqlitePath := flags.String("sqlite", "", "SQLite database of the policies, see package sqlstore")
+	refresh := flags.Duration("refresh", 5*time.Second, "how often the store is checked for changed policies")
+	allPolicies := flags.Bool("all-policies", false, "decide the requests without Principals with all policies of the store")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 10*time.Second, "how long running requests may take on shutdown")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStore(ctx, *dir, *sqlitePath)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	logger := log.New(stderr, "", log.LstdFlags)
+	server, err := pdp.NewServer(ctx, store)
+	if err != nil {
+		return err
+	}
+	server.AllPoliciesWithoutPrincipals = *allPolicies
+	go server.Watch(ctx, *refresh, func(err error) { logger.Printf("refresh policies: %v", err) })
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	logger.Printf("listening on %s", listener.Addr())
+	return serve(ctx, listener, server, *shutdownTimeout)
+}
+
+func openStore(ctx context.Context, dir, sqlitePath string) (policy.PolicyStore, func(), error) {
+	switch {
+	case dir != "" && sqlitePath != "":
+		return nil, nil, errors.New("use either -dir or -sqlite")
+	case dir != "":
+		store, err := policy.NewDirectoryPolicyStore(dir)
+		return store, func() {}, err
+	case sqlitePath != "":
+		db, err := sql.Open("sqlite", sqlitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		store := sqlstore.New(db, sqlstore.SQLite)
+		if err := store.Migrate(ctx); err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("cannot migrate %s: %w", sqlitePath, err)
+		}
+		return store, func() { db.Close() }, nil
+	default:
+		return nil, nil, errors.New("no store, use -dir or -sqlite")
+	}
+}
+
+// serve serves the handler until the context is done, then waits for the running requests up to the timeout.
+func serve(ctx context.Context, listener net.Listener, handler http.Handler, shutdownTimeout time.Duration) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Serve(listener) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	// Arrange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- serve(ctx, listener, handler, 5*time.Second) }()
+
+	responses := make(chan string)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	// Act
+	<-started
+	cancel()
+
+	// Assert
+	if got := <-responses; got != "done" {
+		t.Errorf("want the running request finished, but got %s", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+}
+
+func TestRun_StoreFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no store", []string{}},
+		{"both stores", []string{"-dir", t.TempDir(), "-sqlite", "policies.db"}},
+		{"missing directory", []string{"-dir", "does-not-exist"}},
+		{"unknown flag", []string{"-unknown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := run(context.Background(), tt.args, io.Discard)
+
+			// Assert
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
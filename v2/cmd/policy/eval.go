@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/golfz/policy/v2"
+)
+
+// eval prints the decision and its explanation, e.g. `allowed: allowed by statements (policy "p1" statement 0)`.
+// The resource file is a policy.Resource, the user file is the JSON object of the user properties.
+func (c *command) eval(args []string) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	policiesFile := flags.String("policies", "", "file of the policies")
+	resourceFile := flags.String("resource", "", "file of the resource, action and properties")
+	userFile := flags.String("user", "", "file of the user properties")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *policiesFile == "" || *resourceFile == "" {
+		return c.errorf("eval: -policies and -resource are required")
+	}
+	stdinFiles := 0
+	for _, name := range []string{*policiesFile, *resourceFile, *userFile} {
+		if name == "-" {
+			stdinFiles++
+		}
+	}
+	if stdinFiles > 1 {
+		return c.errorf("eval: only one of -policies, -resource and -user can read the standard input")
+	}
+
+	b, err := c.readFile(*policiesFile)
+	if err != nil {
+		return c.errorf("eval: %v", err)
+	}
+	policies, err := policy.ParsePolicyFile(*policiesFile, b)
+	if err != nil {
+		return c.errorf("eval: cannot parse %s: %v", *policiesFile, err)
+	}
+
+	b, err = c.readFile(*resourceFile)
+	if err != nil {
+		return c.errorf("eval: %v", err)
+	}
+	var res policy.Resource
+	if err := json.Unmarshal(b, &res); err != nil {
+		return c.errorf("eval: cannot parse %s: %v", *resourceFile, err)
+	}
+
+	userData := "{}"
+	if *userFile != "" {
+		b, err = c.readFile(*userFile)
+		if err != nil {
+			return c.errorf("eval: %v", err)
+		}
+		var user map[string]interface{}
+		if err := json.Unmarshal(b, &user); err != nil {
+			return c.errorf("eval: cannot parse %s: %v", *userFile, err)
+		}
+		userData = string(b)
+	}
+
+	pv := policy.New()
+	pv.Policies = policies
+	pv.UserPropertyGetter = policy.NewDefaultUserPropertyGetter(userData)
+	pv.SetResource(res.Resource)
+	pv.SetAction(res.Action)
+	pv.AddProperties(res.Properties)
+
+	explanation, err := pv.ExplainAccess()
+	if err != nil {
+		return c.errorf("eval: %v", err)
+	}
+	fmt.Fprintln(c.stdout, explanation)
+	if !explanation.Allowed {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	policies := writeTestFile(t, "policies.json", testPolicies)
+	user := writeTestFile(t, "user.json", `{"id": "u1"}`)
+	write := writeTestFile(t, "write.json", `{"Resource": "res:::doc", "Action": "act:::doc:write", "Properties": {"String": {"prop:::doc:owner": "u1"}}}`)
+	writeOther := writeTestFile(t, "write_other.json", `{"Resource": "res:::doc", "Action": "act:::doc:write", "Properties": {"String": {"prop:::doc:owner": "u2"}}}`)
+	invalid := writeTestFile(t, "invalid.json", `{"Resource": `)
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+	}{
+		{"allowed", []string{"-policies", policies, "-user", user, "-resource", write}, "", exitOK, `allowed: allowed by statements (policy "p1" statement 1)`},
+		{"denied", []string{"-policies", policies, "-user", user, "-resource", writeOther}, "", exitFailure, "denied: no statement matched"},
+		{"policies from stdin", []string{"-policies", "-", "-user", user, "-resource", write}, testPolicies, exitOK, "allowed"},
+		{"resource from stdin", []string{"-policies", policies, "-user", user, "-resource", "-"}, `{"Resource": "res:::doc", "Action": "act:::doc:write", "Properties": {"String": {"prop:::doc:owner": "u1"}}}`, exitOK, "allowed"},
+		{"stdin twice", []string{"-policies", "-", "-user", user, "-resource", "-"}, testPolicies, exitError, ""},
+		{"no user", []string{"-policies", policies, "-resource", write}, "", exitFailure, "denied"},
+		{"missing resource flag", []string{"-policies", policies}, "", exitError, ""},
+		{"invalid resource", []string{"-policies", policies, "-resource", invalid}, "", exitError, ""},
+		{"invalid user", []string{"-policies", policies, "-user", invalid, "-resource", write}, "", exitError, ""},
+		{"missing file", []string{"-policies", "does-not-exist.json", "-resource", write}, "", exitError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			code, stdout, _ := runCommand(tt.stdin, append([]string{"eval"}, tt.args...)...)
+
+			// Assert
+			if code != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, code)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("want %q, but got %q", tt.wantStdout, stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golfz/policy/v2"
+)
+
+// fmt prints the files in the canonical format, or rewrites them with -w, or lists the files that are not formatted with -l.
+// The canonical format is the JSON of the policies indented with 4 spaces, without the fields that are null,
+// or the YAML of policy.FormatPolicyYAML for a YAML file.
+func (c *command) fmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	write := flags.Bool("w", false, "write the result to the file instead of the standard output")
+	list := flags.Bool("l", false, "list the files whose formatting differs, and exit with 1 if any")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		return c.errorf("fmt: no files")
+	}
+	if *write && *list {
+		return c.errorf("fmt: -w and -l cannot be used together")
+	}
+
+	code := exitOK
+	for _, name := range flags.Args() {
+		if *write && name == "-" {
+			return c.errorf("fmt: -w cannot write the standard input")
+		}
+		b, err := c.readFile(name)
+		if err != nil {
+			return c.errorf("fmt: %v", err)
+		}
+		formatted, err := formatPolicies(name, b)
+		if err != nil {
+			return c.errorf("fmt: cannot parse %s: %v", name, err)
+		}
+
+		switch {
+		case *list:
+			if !bytes.Equal(b, formatted) {
+				fmt.Fprintln(c.stdout, name)
+				code = exitFailure
+			}
+		case *write:
+			if bytes.Equal(b, formatted) {
+				continue
+			}
+			info, err := os.Stat(name)
+			if err != nil {
+				return c.errorf("fmt: %v", err)
+			}
+			if err := os.WriteFile(name, formatted, info.Mode().Perm()); err != nil {
+				return c.errorf("fmt: %v", err)
+			}
+		default:
+			c.stdout.Write(formatted)
+		}
+	}
+	return code
+}
+
+// formatPolicies returns the policy, or the array of policies, of the file in the canonical format.
+func formatPolicies(name string, b []byte) ([]byte, error) {
+	if policy.IsDSLFile(name) {
+		// the printer of the DSL would drop the comments
+		return nil, fmt.Errorf("files in the policy DSL are not formatted")
+	}
+	if policy.IsYAMLFile(name) {
+		return policy.FormatPolicyYAML(b)
+	}
+	policies, err := policy.ParsePolicyFile(name, b)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{} = policies
+	if !isJSONArray(b) {
+		v = policies[0]
+	}
+
+	var compact bytes.Buffer
+	encoder := json.NewEncoder(&compact)
+	// expressions such as "prop.amount < 100" are kept readable
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	stripped, err := removeNullFields(bytes.TrimSpace(compact.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, stripped, "", "    "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+func isJSONArray(b []byte) bool {
+	return !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{"))
+}
+
+// removeNullFields removes the object members that are null, keeping the order of the other members.
+func removeNullFields(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return data, nil
+	}
+	isObject := delim == '{'
+
+	var buf bytes.Buffer
+	buf.WriteByte(byte(delim))
+	first := true
+	for decoder.More() {
+		var key json.RawMessage
+		if isObject {
+			tok, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			if key, err = marshalString(tok.(string)); err != nil {
+				return nil, err
+			}
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		if isObject && string(value) == "null" {
+			continue
+		}
+		if value, err = removeNullFields(value); err != nil {
+			return nil, err
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		if isObject {
+			buf.Write(key)
+			buf.WriteByte(':')
+		}
+		buf.Write(value)
+	}
+	if isObject {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
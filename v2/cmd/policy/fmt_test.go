@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const unformattedPolicies = `[{"Version":1,"PolicyID":"p1","Statements":[
+  {"Effect":"Allow","Resource":"res:::doc","Actions":["act:::doc:read"],"Conditions":null},
+  {"Resource":"res:::doc","Effect":"Allow","Actions":["act:::doc:write"],"Conditions":{"MustHaveAll":{"prop:::doc:owner":{"UserPropEqual":"user:::id"}}}}
+]}]`
+
+func TestFormatPolicies(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"formatted", testPolicies, testPolicies},
+		{"unformatted", unformattedPolicies, testPolicies},
+		{
+			name: "single policy",
+			data: `{"PolicyID": "p1", "Version": 1, "Statements": null}`,
+			want: "{\n    \"Version\": 1,\n    \"PolicyID\": \"p1\"\n}\n",
+		},
+		{
+			name: "expression is not escaped",
+			data: `{"Version": 1, "PolicyID": "p1", "Statements": [{"Conditions": {"AtLeastOne": {"amount": {"Expression": "prop.amount < 100 && prop.amount > 0"}}}}]}`,
+			want: `{
+    "Version": 1,
+    "PolicyID": "p1",
+    "Statements": [
+        {
+            "Effect": "",
+            "Resource": "",
+            "Conditions": {
+                "AtLeastOne": {
+                    "amount": {
+                        "Expression": "prop.amount < 100 && prop.amount > 0"
+                    }
+                }
+            }
+        }
+    ]
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := formatPolicies("policies.json", []byte(tt.data))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s, but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFmt(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      []string
+		content    string
+		wantCode   int
+		wantStdout func(path string) string
+		wantFile   string
+	}{
+		{"print", nil, unformattedPolicies, exitOK, func(string) string { return testPolicies }, unformattedPolicies},
+		{"list unformatted", []string{"-l"}, unformattedPolicies, exitFailure, func(path string) string { return path + "\n" }, unformattedPolicies},
+		{"list formatted", []string{"-l"}, testPolicies, exitOK, func(string) string { return "" }, testPolicies},
+		{"write", []string{"-w"}, unformattedPolicies, exitOK, func(string) string { return "" }, testPolicies},
+		{"invalid", nil, `[{"Version": `, exitError, func(string) string { return "" }, `[{"Version": `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			path := writeTestFile(t, "policies.json", tt.content)
+
+			// Act
+			code, stdout, _ := runCommand("", append(append([]string{"fmt"}, tt.flags...), path)...)
+
+			// Assert
+			if code != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, code)
+			}
+			if want := tt.wantStdout(path); stdout != want {
+				t.Errorf("want %q, but got %q", want, stdout)
+			}
+			if b, _ := os.ReadFile(path); string(b) != tt.wantFile {
+				t.Errorf("want file %q, but got %q", tt.wantFile, b)
+			}
+		})
+	}
+}
+
+func TestFmt_YAML(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte("# documents\n- PolicyID: p1 # stable ID\n  Version: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	code, stdout, _ := runCommand("", "fmt", path)
+
+	// Assert
+	want := "# documents\n- Version: 1\n  PolicyID: p1 # stable ID\n"
+	if code != exitOK || stdout != want {
+		t.Errorf("want %q, but got %v, %q", want, code, stdout)
+	}
+}
+
+func TestFmt_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no files", nil},
+		{"-w and -l", []string{"-w", "-l", "policies.json"}},
+		{"-w with stdin", []string{"-w", "-"}},
+		{"unknown flag", []string{"-x", "policies.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			code, _, _ := runCommand("", append([]string{"fmt"}, tt.args...)...)
+
+			// Assert
+			if code != exitError {
+				t.Errorf("want %v, but got %v", exitError, code)
+			}
+		})
+	}
+}
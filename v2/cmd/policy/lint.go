@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/golfz/policy/v2"
+)
+
+const propertyPrefix = "prop:::"
+
+// lint reports the validation errors of the files, and the mistakes that are valid but probably unintended.
+func (c *command) lint(args []string) int {
+	if len(args) == 0 {
+		return c.errorf("lint: no files")
+	}
+
+	code := exitOK
+	for _, name := range args {
+		b, err := c.readFile(name)
+		if err != nil {
+			return c.errorf("lint: %v", err)
+		}
+		policies, err := policy.ParsePolicyFile(name, b)
+		if err != nil {
+			fmt.Fprintf(c.stdout, "%s: %v\n", name, err)
+			code = exitFailure
+			continue
+		}
+
+		var findings []string
+		if err := policy.ValidatePolicies(policies); err != nil {
+			findings = append(findings, errorLines(err)...)
+		}
+		findings = append(findings, lintPolicies(policies)...)
+		for _, finding := range findings {
+			fmt.Fprintf(c.stdout, "%s: %s\n", name, finding)
+		}
+		if len(findings) > 0 {
+			code = exitFailure
+		}
+	}
+	return code
+}
+
+// lintPolicies returns the findings that are not validation errors.
+func lintPolicies(policies []policy.Policy) []string {
+	var findings []string
+	for _, p := range policies {
+		report := func(format string, a ...interface{}) {
+			findings = append(findings, fmt.Sprintf("policy %q: ", p.PolicyID)+fmt.Sprintf(format, a...))
+		}
+
+		if p.PolicyID == "" {
+			report("no PolicyID")
+		}
+		if p.Version == 0 {
+			report("no Version")
+		}
+		if len(p.Statements) == 0 {
+			report("no statements")
+		}
+		for i, stmt := range p.Statements {
+			for _, finding := range lintStatement(stmt) {
+				report("statement %d: %s", i, finding)
+			}
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(p.Statements[j], stmt) {
+					report("statement %d: duplicate of statement %d", i, j)
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func lintStatement(stmt policy.Statement) []string {
+	var findings []string
+
+	seen := make(map[string]bool)
+	for _, action := range stmt.Actions {
+		if seen[action] {
+			findings = append(findings, fmt.Sprintf("duplicate action %q", action))
+		}
+		seen[action] = true
+	}
+
+	if stmt.Conditions == nil {
+		return findings
+	}
+	if len(stmt.Conditions.AtLeastOne) == 0 && len(stmt.Conditions.MustHaveAll) == 0 {
+		findings = append(findings, "empty Conditions always match, remove them")
+	}
+	findings = append(findings, lintConditions("AtLeastOne", stmt.Conditions.AtLeastOne)...)
+	findings = append(findings, lintConditions("MustHaveAll", stmt.Conditions.MustHaveAll)...)
+	return findings
+}
+
+func lintConditions(name string, conditions map[string]policy.Comparator) []string {
+	var findings []string
+	for _, key := range policy.ConditionKeys(conditions) {
+		comparator := conditions[key]
+		if reflect.DeepEqual(comparator, policy.Comparator{}) {
+			findings = append(findings, fmt.Sprintf("%s %s: no comparison, it always matches", name, key))
+			continue
+		}
+		// an expression references its properties by itself, the key is only a name
+		usesKey := !reflect.DeepEqual(comparator, policy.Comparator{Expression: comparator.Expression})
+		if usesKey && !strings.HasPrefix(key, propertyPrefix) {
+			findings = append(findings, fmt.Sprintf("%s %s: key should start with %q", name, key, propertyPrefix))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golfz/policy/v2"
+)
+
+func TestLint(t *testing.T) {
+	clean := writeTestFile(t, "clean.json", testPolicies)
+	findings := writeTestFile(t, "findings.json", `{"PolicyID": "p1", "Statements": [{"Effect": "Deny", "Resource": "doc", "Actions": ["act:::doc:read"], "Conditions": {}}]}`)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+	}{
+		{"clean", []string{clean}, exitOK, nil},
+		{"findings", []string{clean, findings}, exitFailure, []string{
+			findings + `: policy "p1": statement 0: invalid resource`,
+			findings + `: policy "p1": no Version`,
+			findings + `: policy "p1": statement 0: empty Conditions always match, remove them`,
+		}},
+		{"missing file", []string{"does-not-exist.json"}, exitError, nil},
+		{"no files", nil, exitError, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			code, stdout, _ := runCommand("", append([]string{"lint"}, tt.args...)...)
+
+			// Assert
+			if code != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, code)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("want %q, but got %q", want, stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestLintPolicies(t *testing.T) {
+	read := policy.Statement{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}}
+	owner := "user:::id"
+	amount := mustCompileExpression(t, "prop.doc.amount < 100")
+
+	tests := []struct {
+		name     string
+		policies []policy.Policy
+		want     []string
+	}{
+		{
+			name:     "no findings",
+			policies: []policy.Policy{{Version: 1, PolicyID: "p1", Statements: []policy.Statement{read}}},
+			want:     nil,
+		},
+		{
+			name:     "no PolicyID, Version and statements",
+			policies: []policy.Policy{{}},
+			want:     []string{`policy "": no PolicyID`, `policy "": no Version`, `policy "": no statements`},
+		},
+		{
+			name:     "duplicate statement",
+			policies: []policy.Policy{{Version: 1, PolicyID: "p1", Statements: []policy.Statement{read, read}}},
+			want:     []string{`policy "p1": statement 1: duplicate of statement 0`},
+		},
+		{
+			name: "duplicate action",
+			policies: []policy.Policy{{Version: 1, PolicyID: "p1", Statements: []policy.Statement{
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read", "act:::doc:read"}},
+			}}},
+			want: []string{`policy "p1": statement 0: duplicate action "act:::doc:read"`},
+		},
+		{
+			name: "conditions",
+			policies: []policy.Policy{{Version: 1, PolicyID: "p1", Statements: []policy.Statement{
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}, Conditions: &policy.Condition{
+					AtLeastOne: map[string]policy.Comparator{
+						"prop:::doc:public": {},
+						"amount":            {Expression: amount},
+					},
+					MustHaveAll: map[string]policy.Comparator{
+						"doc:owner": {UserPropEqual: &owner},
+					},
+				}},
+			}}},
+			want: []string{
+				`policy "p1": statement 0: AtLeastOne prop:::doc:public: no comparison, it always matches`,
+				`policy "p1": statement 0: MustHaveAll doc:owner: key should start with "prop:::"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := lintPolicies(tt.policies)
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func mustCompileExpression(t *testing.T, source string) *policy.Expression {
+	t.Helper()
+	expression, err := policy.CompileExpression(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expression
+}
@@ -0,0 +1,94 @@
+// Command policy checks and evaluates policy files.
+//
+// Usage:
+//
+//	policy eval -policies FILE -resource FILE [-user FILE]
+//	policy validate FILE...
+//	policy lint FILE...
+//	policy fmt [-w | -l] FILE...
+//	policy test [-v] [-cover] [-coverprofile FILE] SUITE...
+//	policy schema [-o FILE]
+//
+// A policy file holds a policy or an array of policies, in YAML if its name ends with ".yaml" or ".yml",
+// in the policy DSL if it ends with ".policy", and in JSON otherwise. Files in the policy DSL are not formatted by fmt. "-" reads JSON from the standard input,
+// which eval reads for one of its files only.
+//
+// A suite is a test suite of the policytest package. schema prints the JSON Schema of policy files in JSON,
+// for editors and CI pipelines.
+//
+// Exit codes: 0 on success or an allowed access, 1 on a denied access, invalid policies, lint findings,
+// unformatted files or failed test cases, and 2 on a usage error or a file that cannot be read.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitError   = 2
+)
+
+const usage = `Usage:
+	policy eval -policies FILE -resource FILE [-user FILE]
+	policy validate FILE...
+	policy lint FILE...
+	policy fmt [-w | -l] FILE...
+	policy test [-v] [-cover] [-coverprofile FILE] SUITE...
+	policy schema [-o FILE]
+`
+
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	cmd := &command{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(cmd.run(os.Args[1:]))
+}
+
+func (c *command) run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(c.stderr, usage)
+		return exitError
+	}
+
+	switch args[0] {
+	case "eval":
+		return c.eval(args[1:])
+	case "validate":
+		return c.validate(args[1:])
+	case "lint":
+		return c.lint(args[1:])
+	case "fmt":
+		return c.fmt(args[1:])
+	case "test":
+		return c.test(args[1:])
+	case "schema":
+		return c.schema(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(c.stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(c.stderr, "unknown command %q\n%s", args[0], usage)
+		return exitError
+	}
+}
+
+// errorf prints the error and returns exitError.
+func (c *command) errorf(format string, a ...interface{}) int {
+	fmt.Fprintf(c.stderr, format+"\n", a...)
+	return exitError
+}
+
+func (c *command) readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(name)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicies = `[
+    {
+        "Version": 1,
+        "PolicyID": "p1",
+        "Statements": [
+            {
+                "Effect": "Allow",
+                "Resource": "res:::doc",
+                "Actions": [
+                    "act:::doc:read"
+                ]
+            },
+            {
+                "Effect": "Allow",
+                "Resource": "res:::doc",
+                "Actions": [
+                    "act:::doc:write"
+                ],
+                "Conditions": {
+                    "MustHaveAll": {
+                        "prop:::doc:owner": {
+                            "UserPropEqual": "user:::id"
+                        }
+                    }
+                }
+            }
+        ]
+    }
+]
+`
+
+// runCommand runs the command with the standard input, and returns the exit code and the outputs.
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	c := &command{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	code := c.run(args)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{"no command", nil, exitError},
+		{"unknown command", []string{"unknown"}, exitError},
+		{"help", []string{"help"}, exitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			code, _, _ := runCommand("", tt.args...)
+
+			// Assert
+			if code != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, code)
+			}
+		})
+	}
+}
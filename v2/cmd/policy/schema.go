@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/golfz/policy/v2"
+)
+
+// schema prints the JSON Schema of the policy files, or writes it to the file of -o.
+func (c *command) schema(args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	output := flags.String("o", "", "write the schema to the file")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() > 0 {
+		return c.errorf("schema: unexpected arguments %v", flags.Args())
+	}
+
+	if *output != "" {
+		if err := os.WriteFile(*output, policy.PolicySchema(), 0o644); err != nil {
+			return c.errorf("schema: %v", err)
+		}
+		return exitOK
+	}
+	if _, err := c.stdout.Write(policy.PolicySchema()); err != nil {
+		return c.errorf("schema: %v", err)
+	}
+	return exitOK
+}
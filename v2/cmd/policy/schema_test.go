@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golfz/policy/v2"
+)
+
+func TestSchema(t *testing.T) {
+	// Act
+	code, stdout, _ := runCommand("", "schema")
+
+	// Assert
+	if code != exitOK {
+		t.Errorf("want %v, but got %v", exitOK, code)
+	}
+	if stdout != string(policy.PolicySchema()) {
+		t.Errorf("want the policy schema, but got %s", stdout)
+	}
+}
+
+func TestSchema_Output(t *testing.T) {
+	// Arrange
+	name := filepath.Join(t.TempDir(), "policy.schema.json")
+
+	// Act
+	code, stdout, _ := runCommand("", "schema", "-o", name)
+
+	// Assert
+	if code != exitOK {
+		t.Errorf("want %v, but got %v", exitOK, code)
+	}
+	if stdout != "" {
+		t.Errorf("want no output, but got %s", stdout)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, policy.PolicySchema()) {
+		t.Errorf("want the policy schema, but got %s", got)
+	}
+}
+
+func TestSchema_UnexpectedArguments(t *testing.T) {
+	// Act
+	code, _, stderr := runCommand("", "schema", "policy.json")
+
+	// Assert
+	if code != exitError {
+		t.Errorf("want %v, but got %v", exitError, code)
+	}
+	if stderr == "" {
+		t.Errorf("want an error, but got none")
+	}
+}
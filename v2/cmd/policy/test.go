@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/golfz/policy/v2"
+	"github.com/golfz/policy/v2/policytest"
+)
+
+// test runs the suites of policytest, and prints the failed cases with their explanation.
+func (c *command) test(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	verbose := flags.Bool("v", false, "print the passed cases too")
+	cover := flags.Bool("cover", false, "print the coverage of the policies by the cases")
+	coverProfile := flags.String("coverprofile", "", "write the coverage to the file, as JSON for .json, HTML for .html and text otherwise")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		return c.errorf("test: no suites")
+	}
+
+	var coverage *policy.Coverage
+	if *cover || *coverProfile != "" {
+		coverage = policy.NewCoverage()
+	}
+
+	code := exitOK
+	for _, name := range flags.Args() {
+		results, err := policytest.RunFileWithCoverage(name, coverage)
+		if err != nil {
+			return c.errorf("test: %v", err)
+		}
+
+		failed := 0
+		for _, result := range results {
+			if !result.Passed() {
+				failed++
+			}
+			if *verbose || !result.Passed() {
+				fmt.Fprintf(c.stdout, "    %s\n", result)
+			}
+		}
+		if failed > 0 {
+			fmt.Fprintf(c.stdout, "FAIL %s: %d of %d cases failed\n", name, failed, len(results))
+			code = exitFailure
+		} else {
+			fmt.Fprintf(c.stdout, "ok   %s: %d cases\n", name, len(results))
+		}
+	}
+
+	if coverage == nil {
+		return code
+	}
+	report := coverage.Report()
+	if *cover {
+		if err := report.WriteText(c.stdout); err != nil {
+			return c.errorf("test: %v", err)
+		}
+	}
+	if *coverProfile != "" {
+		if err := writeCoverProfile(*coverProfile, report); err != nil {
+			return c.errorf("test: %v", err)
+		}
+	}
+	return code
+}
+
+func writeCoverProfile(name string, report policy.CoverageReport) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(name) {
+	case ".json":
+		err = report.WriteJSON(f)
+	case ".html":
+		err = report.WriteHTML(f)
+	default:
+		err = report.WriteText(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
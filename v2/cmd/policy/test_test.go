@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTest(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+	}{
+		{"passed", []string{"../../policytest/test_data/suite.yaml"}, exitOK, []string{"ok   ../../policytest/test_data/suite.yaml: 5 cases"}},
+		{"passed verbose", []string{"-v", "../../policytest/test_data/suite.yaml"}, exitOK, []string{"    PASS owner can write\n"}},
+		{"failed", []string{"../../policytest/test_data/suite.yaml", "../../policytest/test_data/suite.json"}, exitFailure, []string{
+			"    FAIL wrong expectation: want ALLOW, but got denied: no statement matched\n",
+			"FAIL ../../policytest/test_data/suite.json: 1 of 2 cases failed\n",
+		}},
+		{"missing suite", []string{"does-not-exist.yaml"}, exitError, nil},
+		{"no suites", nil, exitError, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			code, stdout, _ := runCommand("", append([]string{"test"}, tt.args...)...)
+
+			// Assert
+			if code != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, code)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("want %q, but got %q", want, stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestTest_Coverage(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     string
+		wantProfile string
+	}{
+		{"text", "coverage.txt", "statements: 3 of 3 covered (100.0%)"},
+		{"json", "coverage.json", `"Statements": {`},
+		{"html", "coverage.html", "<h1>Policy coverage</h1>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			profile := filepath.Join(t.TempDir(), tt.profile)
+
+			// Act
+			code, stdout, _ := runCommand("", "test", "-cover", "-coverprofile", profile, "../../policytest/test_data/suite.yaml")
+
+			// Assert
+			if code != exitOK {
+				t.Errorf("want %v, but got %v", exitOK, code)
+			}
+			if want := "comparator outcomes: 6 of 6 covered (100.0%)"; !strings.Contains(stdout, want) {
+				t.Errorf("want %q, but got %q", want, stdout)
+			}
+			b, err := os.ReadFile(profile)
+			if err != nil || !strings.Contains(string(b), tt.wantProfile) {
+				t.Errorf("want %q, but got %s, %v", tt.wantProfile, b, err)
+			}
+		})
+	}
+}
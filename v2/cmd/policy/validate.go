@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golfz/policy/v2"
+)
+
+// validate checks the files with policy.ValidatePolicies.
+func (c *command) validate(args []string) int {
+	if len(args) == 0 {
+		return c.errorf("validate: no files")
+	}
+
+	code := exitOK
+	for _, name := range args {
+		b, err := c.readFile(name)
+		if err != nil {
+			return c.errorf("validate: %v", err)
+		}
+		policies, err := policy.ParsePolicyFile(name, b)
+		if err == nil {
+			err = policy.ValidatePolicies(policies)
+		}
+		if err != nil {
+			for _, line := range errorLines(err) {
+				fmt.Fprintf(c.stdout, "%s: %s\n", name, line)
+			}
+			code = exitFailure
+		}
+	}
+	return code
+}
+
+// errorLines splits the errors joined by errors.Join, which are one per line.
+func errorLines(err error) []string {
+	return strings.Split(err.Error(), "\n")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := writeTestFile(t, "valid.json", testPolicies)
+	invalid := writeTestFile(t, "invalid.json", `[{"Version": 1, "PolicyID": "p1", "Statements": [{"Effect": "allow", "Resource": "doc", "Actions": ["act:::doc:read"]}]}]`)
+	malformed := writeTestFile(t, "malformed.json", `[{"Version": `)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+	}{
+		{"valid", []string{valid}, exitOK, nil},
+		{"invalid", []string{valid, invalid}, exitFailure, []string{invalid + `: policy "p1": statement 0: invalid effect`, invalid + `: policy "p1": statement 0: invalid resource`}},
+		{"malformed", []string{malformed}, exitFailure, []string{malformed + ": "}},
+		{"missing file", []string{"does-not-exist.json"}, exitError, nil},
+		{"no files", nil, exitError, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			code, stdout, _ := runCommand("", append([]string{"validate"}, tt.args...)...)
+
+			// Assert
+			if code != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, code)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("want %q, but got %q", want, stdout)
+				}
+			}
+			if tt.wantCode == exitOK && stdout != "" {
+				t.Errorf("want no output, but got %q", stdout)
+			}
+		})
+	}
+}
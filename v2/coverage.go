@@ -0,0 +1,347 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sync"
+)
+
+// Coverage records which statements, quantifiers and comparators of the policies are evaluated,
+// like code coverage for the test cases of the policies. It is safe for concurrent use.
+//
+// A statement is covered when it matches the resource and action of an evaluation.
+// A comparator has two outcomes, matched and not matched, that are covered separately.
+//
+// The evaluated statements are recognized by their conditions and actions, so the validator must use
+// the same Policy values that are added to the coverage, not copies made with e.g. a JSON round-trip.
+type Coverage struct {
+	mu          sync.Mutex
+	policies    []*PolicyCoverage
+	statements  map[statementIdentity][]*StatementCoverage
+	quantifiers map[quantifierIdentity][]*QuantifierCoverage
+}
+
+// statementIdentity tells the statements apart, copies of a statement share its conditions and actions.
+// The pointers keep the conditions and actions alive, so that their addresses are not reused by other policies.
+// A statement without actions matches no resource and action, so it needs no identity.
+type statementIdentity struct {
+	conditions *Condition
+	actions    *string
+	resource   string
+	effect     string
+}
+
+func newStatementIdentity(stmt Statement) (statementIdentity, bool) {
+	if len(stmt.Actions) == 0 {
+		return statementIdentity{}, false
+	}
+	return statementIdentity{conditions: stmt.Conditions, actions: &stmt.Actions[0], resource: stmt.Resource, effect: stmt.Effect}, true
+}
+
+// quantifierIdentity tells the quantifiers apart by the conditions of the statement, like statementIdentity.
+type quantifierIdentity struct {
+	conditions *Condition
+	// quantifier is AtLeastOne or MustHaveAll.
+	quantifier string
+}
+
+type PolicyCoverage struct {
+	PolicyID   string
+	Statements []StatementCoverage
+}
+
+type StatementCoverage struct {
+	Index    int
+	Effect   string
+	Resource string
+	// Hits is the number of evaluations in which the statement matched the resource and action.
+	Hits        int
+	Quantifiers []QuantifierCoverage
+}
+
+type QuantifierCoverage struct {
+	// Quantifier is AtLeastOne or MustHaveAll.
+	Quantifier  string
+	Hits        int
+	Comparators []ComparatorCoverage
+}
+
+type ComparatorCoverage struct {
+	Key        string
+	Matched    int
+	NotMatched int
+}
+
+// CoverageCount is the number of covered items out of all items.
+type CoverageCount struct {
+	Covered int
+	Total   int
+}
+
+// Percent returns the covered items in percent, 100 if there are no items.
+func (c CoverageCount) Percent() float64 {
+	if c.Total == 0 {
+		return 100
+	}
+	return float64(c.Covered) * 100 / float64(c.Total)
+}
+
+func (c CoverageCount) String() string {
+	return fmt.Sprintf("%d of %d covered (%.1f%%)", c.Covered, c.Total, c.Percent())
+}
+
+// CoverageReport is a snapshot of a Coverage.
+type CoverageReport struct {
+	Policies           []PolicyCoverage
+	Statements         CoverageCount
+	ComparatorOutcomes CoverageCount
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		statements:  make(map[statementIdentity][]*StatementCoverage),
+		quantifiers: make(map[quantifierIdentity][]*QuantifierCoverage),
+	}
+}
+
+// Add adds the policies to the coverage. A policy with the PolicyID and the number of statements of
+// a policy already added is recorded with it, e.g. the policies of a file loaded by several test suites.
+func (c *Coverage) Add(policies []Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range policies {
+		pc := c.findPolicy(p)
+		if pc == nil {
+			pc = &PolicyCoverage{PolicyID: p.PolicyID, Statements: make([]StatementCoverage, len(p.Statements))}
+			for i, stmt := range p.Statements {
+				pc.Statements[i] = newStatementCoverage(i, stmt)
+			}
+			c.policies = append(c.policies, pc)
+		}
+
+		for i, stmt := range p.Statements {
+			sc := &pc.Statements[i]
+			identity, ok := newStatementIdentity(stmt)
+			if !ok {
+				continue
+			}
+			c.statements[identity] = appendIfMissing(c.statements[identity], sc)
+			if stmt.Conditions == nil {
+				continue
+			}
+			for j := range sc.Quantifiers {
+				qc := &sc.Quantifiers[j]
+				quantifier := quantifierIdentity{conditions: stmt.Conditions, quantifier: qc.Quantifier}
+				c.quantifiers[quantifier] = appendIfMissing(c.quantifiers[quantifier], qc)
+			}
+		}
+	}
+}
+
+func (c *Coverage) findPolicy(p Policy) *PolicyCoverage {
+	for _, pc := range c.policies {
+		if pc.PolicyID == p.PolicyID && len(pc.Statements) == len(p.Statements) {
+			return pc
+		}
+	}
+	return nil
+}
+
+// appendIfMissing appends the item, unless the same policies were already added.
+func appendIfMissing[T any](items []*T, item *T) []*T {
+	for _, existing := range items {
+		if existing == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+func newStatementCoverage(index int, stmt Statement) StatementCoverage {
+	sc := StatementCoverage{Index: index, Effect: stmt.Effect, Resource: stmt.Resource}
+	if stmt.Conditions == nil {
+		return sc
+	}
+	for _, quantifier := range []struct {
+		name       string
+		conditions map[string]Comparator
+	}{
+		{"AtLeastOne", stmt.Conditions.AtLeastOne},
+		{"MustHaveAll", stmt.Conditions.MustHaveAll},
+	} {
+		if len(quantifier.conditions) == 0 {
+			continue
+		}
+		qc := QuantifierCoverage{Quantifier: quantifier.name}
+		for _, key := range sortedKeys(quantifier.conditions) {
+			qc.Comparators = append(qc.Comparators, ComparatorCoverage{Key: key})
+		}
+		sc.Quantifiers = append(sc.Quantifiers, qc)
+	}
+	return sc
+}
+
+func (c *Coverage) recordStatement(stmt Statement) {
+	if c == nil {
+		return
+	}
+	identity, ok := newStatementIdentity(stmt)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, sc := range c.statements[identity] {
+		sc.Hits++
+	}
+}
+
+func (c *Coverage) recordQuantifier(identity quantifierIdentity) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, qc := range c.quantifiers[identity] {
+		qc.Hits++
+	}
+}
+
+func (c *Coverage) recordComparator(identity quantifierIdentity, key string, isMatched bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, qc := range c.quantifiers[identity] {
+		for i := range qc.Comparators {
+			if qc.Comparators[i].Key != key {
+				continue
+			}
+			if isMatched {
+				qc.Comparators[i].Matched++
+			} else {
+				qc.Comparators[i].NotMatched++
+			}
+		}
+	}
+}
+
+// Report returns a snapshot of the coverage.
+func (c *Coverage) Report() CoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := CoverageReport{Policies: make([]PolicyCoverage, 0, len(c.policies))}
+	for _, pc := range c.policies {
+		policyCopy := PolicyCoverage{PolicyID: pc.PolicyID, Statements: make([]StatementCoverage, len(pc.Statements))}
+		for i, sc := range pc.Statements {
+			statementCopy := sc
+			statementCopy.Quantifiers = make([]QuantifierCoverage, len(sc.Quantifiers))
+			for j, qc := range sc.Quantifiers {
+				quantifierCopy := qc
+				quantifierCopy.Comparators = append([]ComparatorCoverage(nil), qc.Comparators...)
+				statementCopy.Quantifiers[j] = quantifierCopy
+
+				for _, cc := range qc.Comparators {
+					report.ComparatorOutcomes.Total += 2
+					if cc.Matched > 0 {
+						report.ComparatorOutcomes.Covered++
+					}
+					if cc.NotMatched > 0 {
+						report.ComparatorOutcomes.Covered++
+					}
+				}
+			}
+			policyCopy.Statements[i] = statementCopy
+
+			report.Statements.Total++
+			if sc.Hits > 0 {
+				report.Statements.Covered++
+			}
+		}
+		report.Policies = append(report.Policies, policyCopy)
+	}
+	return report
+}
+
+// WriteText writes the report for a person, the items that are not covered are marked with "!".
+func (r CoverageReport) WriteText(w io.Writer) error {
+	mark := func(covered bool) string {
+		if covered {
+			return " "
+		}
+		return "!"
+	}
+
+	for _, pc := range r.Policies {
+		if _, err := fmt.Fprintf(w, "policy %q\n", pc.PolicyID); err != nil {
+			return err
+		}
+		for _, sc := range pc.Statements {
+			fmt.Fprintf(w, "%s   statement %d %s %s: %d hits\n", mark(sc.Hits > 0), sc.Index, sc.Effect, sc.Resource, sc.Hits)
+			for _, qc := range sc.Quantifiers {
+				fmt.Fprintf(w, "%s       %s: %d hits\n", mark(qc.Hits > 0), qc.Quantifier, qc.Hits)
+				for _, cc := range qc.Comparators {
+					fmt.Fprintf(w, "%s           %s: %d matched, %d not matched\n", mark(cc.Matched > 0 && cc.NotMatched > 0), cc.Key, cc.Matched, cc.NotMatched)
+				}
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "statements: %s\ncomparator outcomes: %s\n", r.Statements, r.ComparatorOutcomes)
+	return err
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r CoverageReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(r)
+}
+
+var coverageHTMLTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Policy coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.covered { background: #dfd; }
+.partial { background: #ffd; }
+.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Policy coverage</h1>
+<p>Statements: {{.Statements}}<br>Comparator outcomes: {{.ComparatorOutcomes}}</p>
+{{range .Policies}}
+<h2>Policy {{.PolicyID}}</h2>
+<table>
+<tr><th>Statement</th><th>Quantifier</th><th>Comparator</th><th>Hits</th><th>Matched</th><th>Not matched</th></tr>
+{{range .Statements}}
+<tr class="{{if .Hits}}covered{{else}}uncovered{{end}}"><td>{{.Index}} {{.Effect}} {{.Resource}}</td><td></td><td></td><td>{{.Hits}}</td><td></td><td></td></tr>
+{{range .Quantifiers}}
+<tr class="{{if .Hits}}covered{{else}}uncovered{{end}}"><td></td><td>{{.Quantifier}}</td><td></td><td>{{.Hits}}</td><td></td><td></td></tr>
+{{range .Comparators}}
+<tr class="{{if and .Matched .NotMatched}}covered{{else if or .Matched .NotMatched}}partial{{else}}uncovered{{end}}"><td></td><td></td><td>{{.Key}}</td><td></td><td>{{.Matched}}</td><td>{{.NotMatched}}</td></tr>
+{{end}}
+{{end}}
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as an HTML page.
+func (r CoverageReport) WriteHTML(w io.Writer) error {
+	return coverageHTMLTemplate.Execute(w, r)
+}
@@ -0,0 +1,263 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func coveragePolicies() []Policy {
+	return []Policy{
+		{
+			Version:  1,
+			PolicyID: "p1",
+			Statements: []Statement{
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:write"}, Conditions: &Condition{
+					MustHaveAll: map[string]Comparator{"prop:::doc:owner": {UserPropEqual: ptr("user:::id")}},
+				}},
+				{Effect: "Deny", Resource: "res:::doc", Actions: []string{"act:::doc:delete"}},
+			},
+		},
+	}
+}
+
+func evaluateWithCoverage(t *testing.T, policies []Policy, coverage *Coverage, action, owner string) {
+	t.Helper()
+	pv := New()
+	pv.Policies = policies
+	pv.Coverage = coverage
+	pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+	pv.SetResource("res:::doc")
+	pv.SetAction(action)
+	pv.AddPropertyString("prop:::doc:owner", owner)
+	if _, err := pv.IsAccessAllowed(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoverage_Report(t *testing.T) {
+	// Arrange
+	policies := coveragePolicies()
+	coverage := NewCoverage()
+	coverage.Add(policies)
+
+	// Act
+	evaluateWithCoverage(t, policies, coverage, "act:::doc:read", "u1")
+	evaluateWithCoverage(t, policies, coverage, "act:::doc:write", "u1")
+	evaluateWithCoverage(t, policies, coverage, "act:::doc:write", "u2")
+	report := coverage.Report()
+
+	// Assert
+	want := CoverageReport{
+		Policies: []PolicyCoverage{{
+			PolicyID: "p1",
+			Statements: []StatementCoverage{
+				{Index: 0, Effect: "Allow", Resource: "res:::doc", Hits: 1, Quantifiers: []QuantifierCoverage{}},
+				{Index: 1, Effect: "Allow", Resource: "res:::doc", Hits: 2, Quantifiers: []QuantifierCoverage{
+					{Quantifier: "MustHaveAll", Hits: 2, Comparators: []ComparatorCoverage{{Key: "prop:::doc:owner", Matched: 1, NotMatched: 1}}},
+				}},
+				{Index: 2, Effect: "Deny", Resource: "res:::doc", Hits: 0, Quantifiers: []QuantifierCoverage{}},
+			},
+		}},
+		Statements:         CoverageCount{Covered: 2, Total: 3},
+		ComparatorOutcomes: CoverageCount{Covered: 2, Total: 2},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("want %+v, but got %+v", want, report)
+	}
+}
+
+func TestCoverage_ExplainAccess(t *testing.T) {
+	// Arrange
+	policies := coveragePolicies()
+	coverage := NewCoverage()
+	coverage.Add(policies)
+	pv := New()
+	pv.Policies = policies
+	pv.Coverage = coverage
+	pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:write")
+	pv.AddPropertyString("prop:::doc:owner", "u1")
+
+	// Act
+	if _, err := pv.ExplainAccess(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	stmt := coverage.Report().Policies[0].Statements[1]
+	if stmt.Hits != 1 || stmt.Quantifiers[0].Hits != 1 {
+		t.Errorf("want each recorded once, but got %+v", stmt)
+	}
+}
+
+func TestCoverage_AddSamePolicies(t *testing.T) {
+	// Arrange
+	first := coveragePolicies()
+	second := coveragePolicies()
+	coverage := NewCoverage()
+
+	// Act
+	coverage.Add(first)
+	coverage.Add(first)
+	coverage.Add(second)
+	evaluateWithCoverage(t, first, coverage, "act:::doc:read", "u1")
+	evaluateWithCoverage(t, second, coverage, "act:::doc:read", "u1")
+
+	// Assert
+	report := coverage.Report()
+	if len(report.Policies) != 1 {
+		t.Fatalf("want 1 policy, but got %d", len(report.Policies))
+	}
+	if hits := report.Policies[0].Statements[0].Hits; hits != 2 {
+		t.Errorf("want 2 hits, but got %d", hits)
+	}
+}
+
+func TestCoverage_NotAdded(t *testing.T) {
+	// Arrange
+	coverage := NewCoverage()
+
+	// Act
+	evaluateWithCoverage(t, coveragePolicies(), coverage, "act:::doc:read", "u1")
+
+	// Assert
+	if report := coverage.Report(); len(report.Policies) != 0 || report.Statements.Total != 0 {
+		t.Errorf("want an empty report, but got %+v", report)
+	}
+}
+
+func TestCoverage_CollectedPolicies(t *testing.T) {
+	// Arrange
+	coverage := NewCoverage()
+	for i := 0; i < 100; i++ {
+		policies := coveragePolicies()
+		policies[0].PolicyID = fmt.Sprintf("p%d", i)
+		coverage.Add(policies)
+		if i == 0 {
+			evaluateWithCoverage(t, policies, coverage, "act:::doc:write", "u1")
+		}
+		runtime.GC()
+	}
+
+	// Act
+	report := coverage.Report()
+
+	// Assert
+	for i, pc := range report.Policies {
+		want := 0
+		if i == 0 {
+			want = 1
+		}
+		if hits := pc.Statements[1].Quantifiers[0].Hits; hits != want {
+			t.Errorf("policy %s: want %d hits, but got %d", pc.PolicyID, want, hits)
+		}
+	}
+}
+
+func TestCoverage_StatementsWithoutActions(t *testing.T) {
+	// Arrange
+	policies := []Policy{{PolicyID: "p", Statements: []Statement{
+		{Effect: "Allow", Resource: "res:::doc"},
+		{Effect: "Allow", Resource: "res:::doc", Actions: []string{}},
+		{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+	}}}
+	coverage := NewCoverage()
+	coverage.Add(policies)
+
+	// Act
+	evaluateWithCoverage(t, policies, coverage, "act:::doc:read", "u1")
+
+	// Assert
+	var got []int
+	for _, sc := range coverage.Report().Policies[0].Statements {
+		got = append(got, sc.Hits)
+	}
+	if want := []int{0, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+}
+
+func TestCoverageCount_Percent(t *testing.T) {
+	tests := []struct {
+		name  string
+		count CoverageCount
+		want  float64
+	}{
+		{"none covered", CoverageCount{Covered: 0, Total: 4}, 0},
+		{"partially covered", CoverageCount{Covered: 1, Total: 4}, 25},
+		{"no items", CoverageCount{}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := tt.count.Percent()
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("want %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCoverageReport_Write(t *testing.T) {
+	// Arrange
+	policies := coveragePolicies()
+	coverage := NewCoverage()
+	coverage.Add(policies)
+	evaluateWithCoverage(t, policies, coverage, "act:::doc:write", "u1")
+	report := coverage.Report()
+
+	t.Run("text", func(t *testing.T) {
+		// Act
+		var buf bytes.Buffer
+		err := report.WriteText(&buf)
+
+		// Assert
+		want := `policy "p1"
+!   statement 0 Allow res:::doc: 0 hits
+    statement 1 Allow res:::doc: 1 hits
+        MustHaveAll: 1 hits
+!           prop:::doc:owner: 1 matched, 0 not matched
+!   statement 2 Deny res:::doc: 0 hits
+statements: 1 of 3 covered (33.3%)
+comparator outcomes: 1 of 2 covered (50.0%)
+`
+		if err != nil || buf.String() != want {
+			t.Errorf("want %s, but got %s, %v", want, buf.String(), err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		// Act
+		var buf bytes.Buffer
+		err := report.WriteJSON(&buf)
+
+		// Assert
+		var got CoverageReport
+		if err != nil || json.Unmarshal(buf.Bytes(), &got) != nil || !reflect.DeepEqual(got, report) {
+			t.Errorf("want %+v, but got %s, %v", report, buf.String(), err)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		// Act
+		var buf bytes.Buffer
+		err := report.WriteHTML(&buf)
+
+		// Assert
+		for _, want := range []string{"<h2>Policy p1</h2>", "1 of 3 covered (33.3%)", `<tr class="partial"><td></td><td></td><td>prop:::doc:owner</td>`} {
+			if err != nil || !strings.Contains(buf.String(), want) {
+				t.Errorf("want %q, but got %s, %v", want, buf.String(), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const defaultUserIDProperty = "user:::id"
+
+// RedactedValue replaces the values of the redacted fields of RedactDecisions.
+const RedactedValue = "[REDACTED]"
+
+// DecisionLogger records the decisions of a validator, e.g. for an audit log.
+//
+// A decision that cannot be recorded is denied, with the error of LogDecision,
+// so that no access is allowed without a record.
+type DecisionLogger interface {
+	LogDecision(record DecisionRecord) error
+}
+
+// DecisionLoggerFunc is a function that can be used as a DecisionLogger.
+type DecisionLoggerFunc func(record DecisionRecord) error
+
+func (f DecisionLoggerFunc) LogDecision(record DecisionRecord) error {
+	return f(record)
+}
+
+// DecisionRecord is a decision of IsAccessAllowed, ExplainAccess, IsAccessAllowedBatch or Authorize.
+type DecisionRecord struct {
+	Time time.Time
+	// UserID is the user property of the UserIDProperty of the validator.
+	UserID   string
+	Resource string
+	Action   string
+	// Properties are the properties of the resource, including those loaded by the ResourcePropertyGetter.
+	Properties map[string]interface{}
+	Allowed    bool
+	// PolicyIDs are the policies of the matched statements, each once, in the order of the policies.
+	PolicyIDs []string
+	// Reason is the rule applied.
+	Reason Reason
+	// Error is the error of the decision, empty if there is none.
+	Error string
+}
+
+// logDecision records the decision with the DecisionLogger, if it is not nil.
+func (pv *policyValidator) logDecision(res Resource, explanation Explanation, err error) (Explanation, error) {
+	if pv.DecisionLogger == nil {
+		return explanation, err
+	}
+
+	record := DecisionRecord{
+		Time:       time.Now(),
+		Resource:   res.Resource,
+		Action:     res.Action,
+		Properties: propertyValues(res.Properties),
+		Allowed:    explanation.Allowed,
+		PolicyIDs:  []string{},
+		Reason:     explanation.Reason,
+	}
+	if pv.UserPropertyGetter != nil {
+		key := pv.UserIDProperty
+		if key == "" {
+			key = defaultUserIDProperty
+		}
+		record.UserID = pv.UserPropertyGetter.GetUserProperty(key)
+	}
+	for _, stmt := range explanation.Matched {
+		if !isContainsInList(record.PolicyIDs, stmt.PolicyID) {
+			record.PolicyIDs = append(record.PolicyIDs, stmt.PolicyID)
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if logErr := pv.DecisionLogger.LogDecision(record); logErr != nil {
+		explanation.Allowed = DENIED
+		return explanation, fmt.Errorf("cannot log the decision: %w", logErr)
+	}
+	return explanation, err
+}
+
+// propertyValues returns the properties of all types by their keys.
+func propertyValues(prop Property) map[string]interface{} {
+	values := make(map[string]interface{}, len(prop.String)+len(prop.Integer)+len(prop.Float)+len(prop.Boolean))
+	for key, value := range prop.String {
+		values[key] = value
+	}
+	for key, value := range prop.Integer {
+		values[key] = value
+	}
+	for key, value := range prop.Float {
+		values[key] = value
+	}
+	for key, value := range prop.Boolean {
+		values[key] = value
+	}
+	return values
+}
+
+// RedactDecisions returns a DecisionLogger that replaces the values of the fields with RedactedValue,
+// and passes the records to the logger. A field is a property key, e.g. "prop:::employee:salary",
+// or UserID or Error. The values are replaced only if they are not empty.
+func RedactDecisions(logger DecisionLogger, fields ...string) DecisionLogger {
+	return DecisionLoggerFunc(func(record DecisionRecord) error {
+		properties := make(map[string]interface{}, len(record.Properties))
+		for key, value := range record.Properties {
+			if isContainsInList(fields, key) {
+				value = RedactedValue
+			}
+			properties[key] = value
+		}
+		record.Properties = properties
+
+		if record.UserID != "" && isContainsInList(fields, "UserID") {
+			record.UserID = RedactedValue
+		}
+		if record.Error != "" && isContainsInList(fields, "Error") {
+			record.Error = RedactedValue
+		}
+		return logger.LogDecision(record)
+	})
+}
+
+// MemoryDecisionLogger keeps the decisions in memory, e.g. to check them in tests. It is safe for concurrent use.
+type MemoryDecisionLogger struct {
+	mu      sync.Mutex
+	records []DecisionRecord
+}
+
+func (l *MemoryDecisionLogger) LogDecision(record DecisionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, record)
+	return nil
+}
+
+// Records returns the decisions in the order they are recorded.
+func (l *MemoryDecisionLogger) Records() []DecisionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]DecisionRecord(nil), l.records...)
+}
+
+// Reset removes the decisions.
+func (l *MemoryDecisionLogger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = nil
+}
+
+// NewSlogDecisionLogger returns a DecisionLogger that writes each decision to the logger, with the fields of
+// DecisionRecord as attributes. A decision with an error is logged at the error level, the others at the info level.
+func NewSlogDecisionLogger(logger *slog.Logger) DecisionLogger {
+	return DecisionLoggerFunc(func(record DecisionRecord) error {
+		level := slog.LevelInfo
+		if record.Error != "" {
+			level = slog.LevelError
+		}
+		ctx := context.Background()
+		if !logger.Enabled(ctx, level) {
+			return nil
+		}
+
+		properties := make([]interface{}, 0, len(record.Properties))
+		for _, key := range sortedKeys(record.Properties) {
+			properties = append(properties, slog.Any(key, record.Properties[key]))
+		}
+		r := slog.NewRecord(record.Time, level, "authorization decision", 0)
+		r.AddAttrs(
+			slog.String("UserID", record.UserID),
+			slog.String("Resource", record.Resource),
+			slog.String("Action", record.Action),
+			slog.Group("Properties", properties...),
+			slog.Bool("Allowed", record.Allowed),
+			slog.Any("PolicyIDs", record.PolicyIDs),
+			slog.String("Reason", string(record.Reason)),
+			slog.String("Error", record.Error),
+		)
+		return logger.Handler().Handle(ctx, r)
+	})
+}
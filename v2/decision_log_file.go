@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// FileDecisionLogger writes the decisions to a file as JSON lines, one DecisionRecord a line.
+// It is safe for concurrent use.
+//
+// The file is rotated before a line would make it larger than the maximum size: the file is renamed
+// to path.1, a previous path.1 to path.2 and so on, and the files after the maximum number of backups are removed.
+// Without a maximum number of backups, every backup is kept, since the decisions are often kept for an audit.
+// If the file cannot be rotated, e.g. for a lack of permission, the decisions are still appended to it,
+// and the rotation is tried again before the next decision, so that no decision is denied for it.
+type FileDecisionLogger struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// NewFileDecisionLogger opens the file at the path to append the decisions, it is created if it does not exist.
+// The file is not rotated if maxSize is 0 or less, and all backups are kept if maxBackups is 0 or less.
+func NewFileDecisionLogger(path string, maxSize int64, maxBackups int) (*FileDecisionLogger, error) {
+	l := &FileDecisionLogger{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *FileDecisionLogger) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *FileDecisionLogger) LogDecision(record DecisionRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return errors.New("decision log is closed")
+	}
+	if l.file != nil && l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		// the file is opened again below, whether it is rotated or not
+		_ = l.rotate()
+	}
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return fmt.Errorf("cannot open the decision log: %w", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// rotate closes the file, and renames it to the first backup. The file is opened again by LogDecision,
+// a new one if the file is renamed.
+func (l *FileDecisionLogger) rotate() error {
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return err
+	}
+
+	last := l.maxBackups - 1
+	if l.maxBackups <= 0 {
+		// keep all backups, the last one is moved to a new one
+		last = 0
+		for {
+			if _, err := os.Lstat(l.backupPath(last + 1)); err != nil {
+				break
+			}
+			last++
+		}
+	} else if err := os.Remove(l.backupPath(l.maxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := last; i > 0; i-- {
+		if err := os.Rename(l.backupPath(i), l.backupPath(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(l.path, l.backupPath(1))
+}
+
+func (l *FileDecisionLogger) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Close closes the file, the decisions logged after are errors.
+func (l *FileDecisionLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readDecisionLog(t *testing.T, path string) []DecisionRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []DecisionRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record DecisionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestFileDecisionLogger(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "decisions.log")
+	logger, err := NewFileDecisionLogger(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DecisionRecord{
+		{Resource: "res:::doc", Action: "act:::doc:read", Allowed: true, PolicyIDs: []string{"readers"}, Reason: ReasonAllowed},
+		{Resource: "res:::doc", Action: "act:::doc:write", PolicyIDs: []string{}, Reason: ReasonNoMatch},
+	}
+
+	// Act
+	for _, record := range want {
+		if err := logger.LogDecision(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeErr := logger.Close()
+
+	// Assert
+	if closeErr != nil {
+		t.Fatalf("want nil, but got %v", closeErr)
+	}
+	if got := readDecisionLog(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+	if err := logger.LogDecision(want[0]); err == nil {
+		t.Errorf("want an error after Close, but got nil")
+	}
+}
+
+func TestFileDecisionLogger_Rotate(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBackups  int
+		wantFiles   []string
+		wantRecords map[string][]string
+	}{
+		{
+			name:        "backups",
+			maxBackups:  2,
+			wantFiles:   []string{"decisions.log", "decisions.log.1", "decisions.log.2"},
+			wantRecords: map[string][]string{"decisions.log": {"res:::4"}, "decisions.log.1": {"res:::3"}, "decisions.log.2": {"res:::2"}},
+		},
+		{
+			name:        "all backups",
+			maxBackups:  0,
+			wantFiles:   []string{"decisions.log", "decisions.log.1", "decisions.log.2", "decisions.log.3", "decisions.log.4"},
+			wantRecords: map[string][]string{"decisions.log": {"res:::4"}, "decisions.log.1": {"res:::3"}, "decisions.log.4": {"res:::0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			path := filepath.Join(dir, "decisions.log")
+			line, _ := json.Marshal(DecisionRecord{Resource: "res:::0"})
+			logger, err := NewFileDecisionLogger(path, int64(len(line)+1), tt.maxBackups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logger.Close()
+
+			// Act
+			for _, resource := range []string{"res:::0", "res:::1", "res:::2", "res:::3", "res:::4"} {
+				if err := logger.LogDecision(DecisionRecord{Resource: resource}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Assert
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("want %v, but got %v", tt.wantFiles, files)
+			}
+			for name, want := range tt.wantRecords {
+				var got []string
+				for _, record := range readDecisionLog(t, filepath.Join(dir, name)) {
+					got = append(got, record.Resource)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: want %v, but got %v", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFileDecisionLogger_Append(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "decisions.log")
+	for _, resource := range []string{"res:::0", "res:::1"} {
+		logger, err := NewFileDecisionLogger(path, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Act
+		_ = logger.LogDecision(DecisionRecord{Resource: resource})
+		_ = logger.Close()
+	}
+
+	// Assert
+	if got := readDecisionLog(t, path); len(got) != 2 {
+		t.Errorf("want 2 records, but got %+v", got)
+	}
+}
+
+func TestFileDecisionLogger_RotateError(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	path := filepath.Join(dir, "decisions.log")
+	// a directory that is not empty cannot be removed to make room for the backup
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	line, _ := json.Marshal(DecisionRecord{Resource: "res:::0"})
+	logger, err := NewFileDecisionLogger(path, int64(len(line)+1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	// Act
+	var errs []error
+	for _, resource := range []string{"res:::0", "res:::1", "res:::2"} {
+		errs = append(errs, logger.LogDecision(DecisionRecord{Resource: resource}))
+	}
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	errs = append(errs, logger.LogDecision(DecisionRecord{Resource: "res:::3"}))
+
+	// Assert
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("decision %d: want nil, but got %v", i, err)
+		}
+	}
+	for name, want := range map[string][]string{
+		"decisions.log":   {"res:::3"},
+		"decisions.log.1": {"res:::0", "res:::1", "res:::2"},
+	} {
+		var got []string
+		for _, record := range readDecisionLog(t, filepath.Join(dir, name)) {
+			got = append(got, record.Resource)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: want %v, but got %v", name, want, got)
+		}
+	}
+}
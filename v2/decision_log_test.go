@@ -0,0 +1,362 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecisionLogger_IsAccessAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		prop    Property
+		want    DecisionRecord
+		wantErr bool
+	}{
+		{
+			name:   "allowed",
+			action: "act:::doc:read",
+			prop:   Property{Boolean: map[string]bool{"prop:::doc:public": true}},
+			want: DecisionRecord{
+				UserID: "u1", Resource: "res:::doc", Action: "act:::doc:read",
+				Properties: map[string]interface{}{"prop:::doc:public": true},
+				Allowed:    true, PolicyIDs: []string{"readers"}, Reason: ReasonAllowed,
+			},
+		},
+		{
+			name:   "denied by statement",
+			action: "act:::doc:read",
+			prop:   Property{Boolean: map[string]bool{"prop:::doc:locked": true}, String: map[string]string{"prop:::doc:owner": "u2"}},
+			want: DecisionRecord{
+				UserID: "u1", Resource: "res:::doc", Action: "act:::doc:read",
+				Properties: map[string]interface{}{"prop:::doc:locked": true, "prop:::doc:owner": "u2"},
+				PolicyIDs:  []string{"readers", "locked"}, Reason: ReasonDenyStatement,
+			},
+		},
+		{
+			name:   "no match",
+			action: "act:::doc:write",
+			want: DecisionRecord{
+				UserID: "u1", Resource: "res:::doc", Action: "act:::doc:write",
+				Properties: map[string]interface{}{}, PolicyIDs: []string{}, Reason: ReasonNoMatch,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			logger := &MemoryDecisionLogger{}
+			pv := New()
+			pv.Policies = explanationPolicies()
+			pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+			pv.DecisionLogger = logger
+			pv.SetResource("res:::doc")
+			pv.SetAction(tt.action)
+			pv.AddProperties(tt.prop)
+
+			// Act
+			allowed, err := pv.IsAccessAllowed()
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if allowed != tt.want.Allowed {
+				t.Errorf("want %v, but got %v", tt.want.Allowed, allowed)
+			}
+			records := logger.Records()
+			if len(records) != 1 {
+				t.Fatalf("want 1 record, but got %d", len(records))
+			}
+			if records[0].Time.IsZero() {
+				t.Errorf("want the time of the decision, but got zero")
+			}
+			records[0].Time = time.Time{}
+			if !reflect.DeepEqual(records[0], tt.want) {
+				t.Errorf("want %+v, but got %+v", tt.want, records[0])
+			}
+		})
+	}
+}
+
+func TestDecisionLogger_UserIDProperty(t *testing.T) {
+	// Arrange
+	logger := &MemoryDecisionLogger{}
+	pv := New()
+	pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1", "user:::email": "u1@example.com"}}
+	pv.DecisionLogger = logger
+	pv.UserIDProperty = "user:::email"
+
+	// Act
+	_, _ = pv.ExplainAccess()
+
+	// Assert
+	if got := logger.Records()[0].UserID; got != "u1@example.com" {
+		t.Errorf("want u1@example.com, but got %v", got)
+	}
+}
+
+func TestDecisionLogger_Error(t *testing.T) {
+	// Arrange
+	logger := &MemoryDecisionLogger{}
+	pv := New()
+	pv.Policies = []Policy{{PolicyID: "p", Statements: []Statement{{Effect: "Maybe", Resource: "res:::doc", Actions: []string{"act:::doc:read"}}}}}
+	pv.DecisionLogger = logger
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	allowed, err := pv.IsAccessAllowed()
+
+	// Assert
+	if allowed != DENIED || err == nil {
+		t.Fatalf("want DENIED with an error, but got %v, %v", allowed, err)
+	}
+	if got := logger.Records()[0].Error; got != err.Error() {
+		t.Errorf("want %q, but got %q", err.Error(), got)
+	}
+}
+
+func TestDecisionLogger_LogError(t *testing.T) {
+	// Arrange
+	errLog := errors.New("disk full")
+	pv := New()
+	pv.Policies = explanationPolicies()
+	pv.DecisionLogger = DecisionLoggerFunc(func(DecisionRecord) error { return errLog })
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	allowed, err := pv.IsAccessAllowed()
+	explanation, explainErr := pv.ExplainAccess()
+
+	// Assert
+	if allowed != DENIED || !errors.Is(err, errLog) {
+		t.Errorf("want DENIED with %v, but got %v, %v", errLog, allowed, err)
+	}
+	if explanation.Allowed != DENIED || !errors.Is(explainErr, errLog) {
+		t.Errorf("want DENIED with %v, but got %v, %v", errLog, explanation.Allowed, explainErr)
+	}
+	if want := "cannot log the decision: disk full"; err.Error() != want {
+		t.Errorf("want %q, but got %q", want, err)
+	}
+}
+
+func TestDecisionLogger_Batch(t *testing.T) {
+	// Arrange
+	logger := &MemoryDecisionLogger{}
+	pv := New()
+	pv.Policies = explanationPolicies()
+	pv.DecisionLogger = logger
+	resources := []Resource{
+		{Resource: "res:::doc", Action: "act:::doc:read"},
+		{Resource: "res:::doc", Action: "act:::doc:read", Properties: Property{Boolean: map[string]bool{"prop:::doc:locked": true}}},
+		{Resource: "res:::doc", Action: "act:::doc:write"},
+	}
+
+	// Act
+	results, err := pv.IsAccessAllowedBatch(resources)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	records := logger.Records()
+	if len(records) != len(resources) {
+		t.Fatalf("want %d records, but got %d", len(resources), len(records))
+	}
+	wantReasons := []Reason{ReasonAllowed, ReasonDenyStatement, ReasonNoMatch}
+	for i, record := range records {
+		if record.Reason != wantReasons[i] {
+			t.Errorf("resource %d: want %v, but got %v", i, wantReasons[i], record.Reason)
+		}
+		if record.Allowed != results[i].Allowed {
+			t.Errorf("resource %d: want %v, but got %v", i, results[i].Allowed, record.Allowed)
+		}
+	}
+}
+
+func TestDecisionLogger_Authorize(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policies.json")
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	loader, err := NewPolicyLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &MemoryDecisionLogger{}
+	loader.DecisionLogger = logger
+	user := &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+
+	// Act
+	allowed, err := loader.Authorize(context.Background(), user, Resource{Resource: "res:::doc", Action: "act:::doc:read"})
+
+	// Assert
+	if !allowed || err != nil {
+		t.Fatalf("want true, but got %v, %v", allowed, err)
+	}
+	records := logger.Records()
+	if len(records) != 1 || records[0].UserID != "u1" || !reflect.DeepEqual(records[0].PolicyIDs, []string{"p1"}) {
+		t.Errorf("want a record of u1 allowed by p1, but got %+v", records)
+	}
+}
+
+func TestRedactDecisions(t *testing.T) {
+	// Arrange
+	logger := &MemoryDecisionLogger{}
+	redacted := RedactDecisions(logger, "prop:::employee:salary", "UserID", "Error")
+	record := DecisionRecord{
+		UserID:     "u1",
+		Properties: map[string]interface{}{"prop:::employee:salary": 1000, "prop:::employee:name": "alice"},
+	}
+
+	// Act
+	err := redacted.LogDecision(record)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	want := DecisionRecord{
+		UserID:     RedactedValue,
+		Properties: map[string]interface{}{"prop:::employee:salary": RedactedValue, "prop:::employee:name": "alice"},
+	}
+	if got := logger.Records()[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+	if record.Properties["prop:::employee:salary"] != 1000 {
+		t.Errorf("want the record unchanged, but got %+v", record.Properties)
+	}
+}
+
+func TestMemoryDecisionLogger_Reset(t *testing.T) {
+	// Arrange
+	logger := &MemoryDecisionLogger{}
+	_ = logger.LogDecision(DecisionRecord{Resource: "res:::doc"})
+
+	// Act
+	logger.Reset()
+
+	// Assert
+	if got := logger.Records(); len(got) != 0 {
+		t.Errorf("want no records, but got %+v", got)
+	}
+}
+
+func TestSlogDecisionLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    DecisionRecord
+		level     slog.Level
+		wantLevel string
+	}{
+		{
+			name:      "decision",
+			record:    DecisionRecord{UserID: "u1", Resource: "res:::doc", Action: "act:::doc:read", Allowed: true, PolicyIDs: []string{"readers"}, Reason: ReasonAllowed},
+			wantLevel: "INFO",
+		},
+		{
+			name:      "error",
+			record:    DecisionRecord{Resource: "res:::doc", PolicyIDs: []string{}, Error: "invalid effect: Maybe"},
+			wantLevel: "ERROR",
+		},
+		{
+			name:   "level not enabled",
+			record: DecisionRecord{Resource: "res:::doc", PolicyIDs: []string{}},
+			level:  slog.LevelWarn,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var buf bytes.Buffer
+			logger := NewSlogDecisionLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: tt.level})))
+			tt.record.Time = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			tt.record.Properties = map[string]interface{}{"prop:::doc:public": true}
+
+			// Act
+			err := logger.LogDecision(tt.record)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if tt.wantLevel == "" {
+				if buf.Len() != 0 {
+					t.Errorf("want nothing logged, but got %s", buf.String())
+				}
+				return
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got["level"] != tt.wantLevel || got["msg"] != "authorization decision" || got["time"] != "2024-01-02T03:04:05Z" {
+				t.Errorf("want a %s decision at the time of the record, but got %v", tt.wantLevel, got)
+			}
+			if got["Resource"] != tt.record.Resource || got["Allowed"] != tt.record.Allowed || got["Error"] != tt.record.Error {
+				t.Errorf("want the fields of %+v, but got %v", tt.record, got)
+			}
+			if want := map[string]interface{}{"prop:::doc:public": true}; !reflect.DeepEqual(got["Properties"], want) {
+				t.Errorf("want %v, but got %v", want, got["Properties"])
+			}
+		})
+	}
+}
+
+func TestDecisionLogger_SameDecisions(t *testing.T) {
+	tests := []struct {
+		name          string
+		action        string
+		prop          Property
+		postValidator *MockPostValidator
+		want          bool
+	}{
+		{name: "rule 1: no statement matched", action: "act:::doc:write"},
+		{name: "rule 2: a Deny statement matched", action: "act:::doc:read", prop: Property{Boolean: map[string]bool{"prop:::doc:locked": true}}},
+		{name: "rule 3: only Allow statements matched", action: "act:::doc:read", prop: Property{Boolean: map[string]bool{"prop:::doc:public": true}}, want: true},
+		{name: "rule 4: a statement without conditions matched", action: "act:::doc:read", prop: Property{Boolean: map[string]bool{"prop:::doc:locked": false}}, want: true},
+		{name: "denied by post validator", action: "act:::doc:read", postValidator: &MockPostValidator{Result: false}},
+		{name: "allowed by post validator", action: "act:::doc:read", postValidator: &MockPostValidator{Result: true}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			newValidator := func(logger DecisionLogger) *policyValidator {
+				pv := New()
+				pv.Policies = explanationPolicies()
+				pv.DecisionLogger = logger
+				pv.SetResource("res:::doc")
+				pv.SetAction(tt.action)
+				pv.AddProperties(tt.prop)
+				if tt.postValidator != nil {
+					pv.AddPostExecutor(tt.postValidator)
+				}
+				return pv
+			}
+
+			// Act
+			withoutLogger, err := newValidator(nil).IsAccessAllowed()
+			withLogger, loggedErr := newValidator(&MemoryDecisionLogger{}).IsAccessAllowed()
+			explanation, explainErr := newValidator(nil).ExplainAccess()
+
+			// Assert
+			if err != nil || loggedErr != nil || explainErr != nil {
+				t.Fatalf("want nil, but got %v, %v, %v", err, loggedErr, explainErr)
+			}
+			if withoutLogger != tt.want || withLogger != tt.want || explanation.Allowed != tt.want {
+				t.Errorf("want %v, but got %v without a logger, %v with a logger and %v explained", tt.want, withoutLogger, withLogger, explanation.Allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const attachmentsFileName = "attachments.json"
+
+type directoryPolicyStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewDirectoryPolicyStore creates a PolicyStore that keeps each policy in a JSON file of the directory.
+//
+// Every "*.json" file in the directory holds one policy, except "attachments.json" which holds
+// the policies attached to each principal, e.g. {"role:::admin": ["policy-1"]}.
+// A new policy is written to a file named after its PolicyID, an existing policy is written to its file.
+// The files are validated like the policies of Put, an invalid file is an error of every method that reads the policies.
+// The version is a hash of the files, so that it also changes when the files are edited by hand.
+func NewDirectoryPolicyStore(dir string) (PolicyStore, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &directoryPolicyStore{dir: dir}, nil
+}
+
+func (s *directoryPolicyStore) List(ctx context.Context) ([]Policy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies, _, err := s.readPolicies()
+	return policies, err
+}
+
+func (s *directoryPolicyStore) Get(ctx context.Context, policyID string) (Policy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies, _, err := s.readPolicies()
+	if err != nil {
+		return Policy{}, err
+	}
+	for _, p := range policies {
+		if p.PolicyID == policyID {
+			return p, nil
+		}
+	}
+	return Policy{}, ErrPolicyNotFound
+}
+
+func (s *directoryPolicyStore) Put(ctx context.Context, policy Policy) error {
+	if err := checkStoredPolicy(policy); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(policy, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, files, err := s.readPolicies()
+	if err != nil {
+		return err
+	}
+	file, ok := files[policy.PolicyID]
+	if !ok {
+		file = url.PathEscape(policy.PolicyID) + ".json"
+		if file == attachmentsFileName || strings.HasPrefix(file, ".") {
+			return fmt.Errorf("%w: PolicyID %q cannot be used as a file name", ErrInvalidPolicy, policy.PolicyID)
+		}
+	}
+	return writeFileAtomic(filepath.Join(s.dir, file), b)
+}
+
+func (s *directoryPolicyStore) Delete(ctx context.Context, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, files, err := s.readPolicies()
+	if err != nil {
+		return err
+	}
+	file, ok := files[policyID]
+	if !ok {
+		return ErrPolicyNotFound
+	}
+
+	attachments, err := s.readAttachments()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for principal, ids := range attachments {
+		if isContainsInList(ids, policyID) {
+			attachments[principal] = removeFromList(ids, policyID)
+			changed = true
+		}
+	}
+	if changed {
+		if err := s.writeAttachments(attachments); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(filepath.Join(s.dir, file))
+}
+
+func (s *directoryPolicyStore) Version(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	var files []policyFile
+	for _, entry := range entries {
+		if !isPolicyStoreFile(entry) && entry.Name() != attachmentsFileName {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, policyFile{name: entry.Name(), content: content})
+	}
+	return int64(checksumFiles(files)), nil
+}
+
+func (s *directoryPolicyStore) Attach(ctx context.Context, principal, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, files, err := s.readPolicies()
+	if err != nil {
+		return err
+	}
+	if _, ok := files[policyID]; !ok {
+		return ErrPolicyNotFound
+	}
+
+	attachments, err := s.readAttachments()
+	if err != nil {
+		return err
+	}
+	if isContainsInList(attachments[principal], policyID) {
+		return nil
+	}
+	attachments[principal] = append(attachments[principal], policyID)
+	sort.Strings(attachments[principal])
+	return s.writeAttachments(attachments)
+}
+
+func (s *directoryPolicyStore) Detach(ctx context.Context, principal, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachments, err := s.readAttachments()
+	if err != nil {
+		return err
+	}
+	if !isContainsInList(attachments[principal], policyID) {
+		return nil
+	}
+	attachments[principal] = removeFromList(attachments[principal], policyID)
+	return s.writeAttachments(attachments)
+}
+
+func (s *directoryPolicyStore) Attached(ctx context.Context, principal string) ([]Policy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attachments, err := s.readAttachments()
+	if err != nil {
+		return nil, err
+	}
+	ids := attachments[principal]
+	if len(ids) == 0 {
+		return []Policy{}, nil
+	}
+
+	policies, _, err := s.readPolicies()
+	if err != nil {
+		return nil, err
+	}
+	attached := make([]Policy, 0, len(ids))
+	for _, p := range policies {
+		if isContainsInList(ids, p.PolicyID) {
+			attached = append(attached, p)
+		}
+	}
+	return attached, nil
+}
+
+// readPolicies reads all policies ordered by PolicyID, and the file of each PolicyID.
+func (s *directoryPolicyStore) readPolicies() ([]Policy, map[string]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policies := make([]Policy, 0, len(entries))
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if !isPolicyStoreFile(entry) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		p, err := ParsePolicy(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot parse %s: %w", entry.Name(), err)
+		}
+		if err := checkStoredPolicy(p); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if other, ok := files[p.PolicyID]; ok {
+			return nil, nil, fmt.Errorf("policy %q is in both %s and %s", p.PolicyID, other, entry.Name())
+		}
+		files[p.PolicyID] = entry.Name()
+		policies = append(policies, p)
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].PolicyID < policies[j].PolicyID
+	})
+	return policies, files, nil
+}
+
+func (s *directoryPolicyStore) readAttachments() (map[string][]string, error) {
+	attachments := make(map[string][]string)
+	b, err := os.ReadFile(filepath.Join(s.dir, attachmentsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return attachments, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &attachments); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", attachmentsFileName, err)
+	}
+	return attachments, nil
+}
+
+func (s *directoryPolicyStore) writeAttachments(attachments map[string][]string) error {
+	for principal, ids := range attachments {
+		if len(ids) == 0 {
+			delete(attachments, principal)
+		}
+	}
+	b, err := json.MarshalIndent(attachments, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, attachmentsFileName), b)
+}
+
+func isPolicyStoreFile(entry fs.DirEntry) bool {
+	name := entry.Name()
+	return entry.Type().IsRegular() &&
+		strings.HasSuffix(name, ".json") &&
+		!strings.HasPrefix(name, ".") &&
+		name != attachmentsFileName
+}
+
+// writeFileAtomic writes to a temporary file that replaces the file, so that a reader never sees a partial file.
+// The file keeps its mode, a new file is readable by everyone like one written by os.WriteFile.
+func writeFileAtomic(name string, b []byte) error {
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectoryPolicyStore(t *testing.T) {
+	testPolicyStore(t, func(t *testing.T) PolicyStore {
+		store, err := NewDirectoryPolicyStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
+
+func TestDirectoryPolicyStore_ExistingFiles(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dir := t.TempDir()
+	files := map[string]string{
+		"read.json":        `{"Version": 1, "PolicyID": "read", "Statements": [{"Effect": "Allow", "Resource": "res:::doc", "Actions": ["act:::doc:read"]}]}`,
+		"attachments.json": `{"role:::viewer": ["read"]}`,
+		"notes.txt":        `not a policy`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, _ := NewDirectoryPolicyStore(dir)
+
+	// Act
+	list, listErr := store.List(ctx)
+	attached, attachedErr := store.Attached(ctx, "role:::viewer")
+
+	// Assert
+	if listErr != nil || len(list) != 1 || list[0].PolicyID != "read" {
+		t.Errorf("want [read], but got %v, %v", policyIDs(list), listErr)
+	}
+	if attachedErr != nil || len(attached) != 1 || attached[0].PolicyID != "read" {
+		t.Errorf("want [read], but got %v, %v", policyIDs(attached), attachedErr)
+	}
+}
+
+func TestDirectoryPolicyStore_PolicyIDAsFileName(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, _ := NewDirectoryPolicyStore(dir)
+
+	// Act
+	err := store.Put(ctx, storedPolicy("team/a", "res:::doc"))
+	reservedErr := store.Put(ctx, storedPolicy("attachments", "res:::doc"))
+	hiddenErr := store.Put(ctx, storedPolicy("../a", "res:::doc"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "team%2Fa.json")); err != nil {
+		t.Errorf("want the policy in the directory, but got %v", err)
+	}
+	if !errors.Is(reservedErr, ErrInvalidPolicy) {
+		t.Errorf("want ErrInvalidPolicy, but got %v", reservedErr)
+	}
+	if !errors.Is(hiddenErr, ErrInvalidPolicy) {
+		t.Errorf("want ErrInvalidPolicy, but got %v", hiddenErr)
+	}
+}
+
+func TestDirectoryPolicyStore_InvalidFile(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, _ := NewDirectoryPolicyStore(dir)
+
+	// Act
+	_, err := store.List(context.Background())
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+}
+
+func TestDirectoryPolicyStore_InvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid effect", `{"Version": 1, "PolicyID": "read", "Statements": [{"Effect": "allow", "Resource": "res:::doc", "Actions": ["act:::doc:read"]}]}`},
+		{"no PolicyID", `{"Version": 1, "Statements": [{"Effect": "Allow", "Resource": "res:::doc", "Actions": ["act:::doc:read"]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "read.json"), []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			store, _ := NewDirectoryPolicyStore(dir)
+
+			// Act
+			_, err := store.List(context.Background())
+
+			// Assert
+			if !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("want ErrInvalidPolicy, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestDirectoryPolicyStore_FileMode(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, _ := NewDirectoryPolicyStore(dir)
+	_ = store.Put(ctx, storedPolicy("p1", "res:::p1"))
+	_ = store.Put(ctx, storedPolicy("p2", "res:::p2"))
+	if err := os.Chmod(filepath.Join(dir, "p2.json"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	err := store.Put(ctx, storedPolicy("p2", "res:::doc"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	for name, want := range map[string]os.FileMode{"p1.json": 0o644, "p2.json": 0o640} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: want %v, but got %v", name, want, got)
+		}
+	}
+}
+
+func TestNewDirectoryPolicyStore_NotADirectory(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policy.json")
+	_ = os.WriteFile(file, []byte(`{}`), 0o644)
+
+	// Act
+	_, err := NewDirectoryPolicyStore(file)
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+}
@@ -0,0 +1,319 @@
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	dslKeywordPolicy  = "policy"
+	dslKeywordVersion = "version"
+	dslKeywordAllow   = "allow"
+	dslKeywordDeny    = "deny"
+	dslKeywordOn      = "on"
+	dslKeywordWhen    = "when"
+	dslKeywordAnd     = "and"
+	dslKeywordOr      = "or"
+	dslKeywordAny     = "any"
+	dslKeywordIn      = "in"
+	dslKeywordPrefix  = "prefix"
+	dslKeywordExpr    = "expr"
+	dslKeywordFn      = "fn"
+)
+
+var dslKeywords = []string{
+	dslKeywordPolicy, dslKeywordVersion, dslKeywordAllow, dslKeywordDeny, dslKeywordOn, dslKeywordWhen,
+	dslKeywordAnd, dslKeywordOr, dslKeywordAny, dslKeywordIn, dslKeywordPrefix, dslKeywordExpr, dslKeywordFn, "true", "false",
+}
+
+// IsDSLFile reports whether the name ends with ".policy", the extension of files in the policy DSL.
+func IsDSLFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".policy")
+}
+
+func isDSLKeyword(word string) bool {
+	return isContainsInList(dslKeywords, word)
+}
+
+// DSLError is an error of ParsePolicyDSL at a line and a column, both starting at 1.
+type DSLError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *DSLError) Error() string {
+	return fmt.Sprintf("invalid policy DSL at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ParsePolicyDSL parses policies written in the policy DSL, a concise syntax of the same policies:
+//
+//	# documents of the organization
+//	policy "documents" version 1 {
+//	    allow act:::doc:read on res:::doc
+//	    allow act:::doc:write, act:::doc:delete on res:::doc
+//	        when prop.doc.owner == user.id
+//	    deny act:::doc:write on res:::doc
+//	        when prop.doc.locked == true and any(prop.doc.pages expr "prop.doc.pages > 1000", prop.doc.tag in ["legal"])
+//	}
+//
+// A statement is the effect, the actions and the resource, with its conditions after "when".
+// The comparisons joined with "and" are MustHaveAll, and those of any(...) are AtLeastOne.
+// A property such as prop.doc.owner is the key "prop:::doc:owner", and user.id is "user:::id";
+// a key that is not a property is a word or a string, e.g. "my key".
+//
+// The comparisons are:
+//
+//	key == "text" | 1 | 1.5 | true | user.id    StringEqual, IntegerEqual, FloatEqual, BooleanEqual or UserPropEqual
+//	key in ["a", "b"] | [1, 2] | [1.5, 2]         StringIn, IntegerIn or FloatIn
+//	key prefix "text"                            StringPrefix, from version 2
+//	key expr "expression"                        Expression
+//	key fn name("text" | user.id | prop.other)   ValidationFunc with StringArg, UserArg or PropArg
+//
+// Several comparisons of a key are one Comparator, so all of them must hold. Comments start with "#".
+// An error is a *DSLError.
+func ParsePolicyDSL(b []byte) ([]Policy, error) {
+	tokens, err := tokenizeDSL(string(b))
+	if err != nil {
+		return nil, err
+	}
+	p := &dslParser{tokens: tokens}
+	return p.parseFile()
+}
+
+// MarshalPolicyDSL returns the policies in the policy DSL, which ParsePolicyDSL parses to the same policies.
+// Conditions without comparisons are left out, since they match like no conditions.
+// The error is returned for a policy that cannot be written, e.g. with a Comparator without comparisons.
+func MarshalPolicyDSL(policies []Policy) ([]byte, error) {
+	var b strings.Builder
+	for i, p := range policies {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if err := writePolicyDSL(&b, p); err != nil {
+			return nil, fmt.Errorf("policy %q: %w", p.PolicyID, err)
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+func writePolicyDSL(b *strings.Builder, p Policy) error {
+	fmt.Fprintf(b, "%s %s %s %d {\n", dslKeywordPolicy, strconv.Quote(p.PolicyID), dslKeywordVersion, p.Version)
+	for i, stmt := range p.Statements {
+		if err := writeStatementDSL(b, stmt); err != nil {
+			return fmt.Errorf("statement %d: %w", i, err)
+		}
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+func writeStatementDSL(b *strings.Builder, stmt Statement) error {
+	var effect string
+	switch stmt.Effect {
+	case statementEffectAllow:
+		effect = dslKeywordAllow
+	case statementEffectDeny:
+		effect = dslKeywordDeny
+	default:
+		return fmt.Errorf("invalid effect: %s", stmt.Effect)
+	}
+	if len(stmt.Actions) == 0 {
+		return fmt.Errorf("no actions")
+	}
+
+	actions := make([]string, len(stmt.Actions))
+	for i, action := range stmt.Actions {
+		actions[i] = dslName(action)
+	}
+	fmt.Fprintf(b, "    %s %s %s %s", effect, strings.Join(actions, ", "), dslKeywordOn, dslName(stmt.Resource))
+
+	if stmt.Conditions != nil {
+		mustHaveAll, err := dslComparisons(stmt.Conditions.MustHaveAll)
+		if err != nil {
+			return err
+		}
+		atLeastOne, err := dslComparisons(stmt.Conditions.AtLeastOne)
+		if err != nil {
+			return err
+		}
+		switch len(atLeastOne) {
+		case 0:
+		case 1:
+			mustHaveAll = append(mustHaveAll, fmt.Sprintf("%s(%s)", dslKeywordAny, atLeastOne[0]))
+		default:
+			mustHaveAll = append(mustHaveAll, fmt.Sprintf("%s(\n            %s\n        )", dslKeywordAny, strings.Join(atLeastOne, ",\n            ")))
+		}
+		// one comparison a line
+		for i, comparison := range mustHaveAll {
+			keyword := dslKeywordAnd
+			if i == 0 {
+				keyword = dslKeywordWhen
+			}
+			fmt.Fprintf(b, "\n        %s %s", keyword, comparison)
+		}
+	}
+	b.WriteString("\n")
+	return nil
+}
+
+// dslComparisons returns the comparisons of the conditions, in the order of the keys.
+func dslComparisons(conditions map[string]Comparator) ([]string, error) {
+	var comparisons []string
+	for _, key := range sortedKeys(conditions) {
+		c := conditions[key]
+		ref := dslKey(key)
+		before := len(comparisons)
+		add := func(format string, args ...interface{}) {
+			comparisons = append(comparisons, ref+" "+fmt.Sprintf(format, args...))
+		}
+
+		if c.StringIn != nil {
+			add("%s %s", dslKeywordIn, dslList(*c.StringIn, strconv.Quote))
+		}
+		if c.StringEqual != nil {
+			add("== %s", strconv.Quote(*c.StringEqual))
+		}
+		if c.StringPrefix != nil {
+			add("%s %s", dslKeywordPrefix, strconv.Quote(*c.StringPrefix))
+		}
+		if c.IntegerIn != nil {
+			add("%s %s", dslKeywordIn, dslList(*c.IntegerIn, strconv.Itoa))
+		}
+		if c.IntegerEqual != nil {
+			add("== %d", *c.IntegerEqual)
+		}
+		if c.FloatIn != nil {
+			add("%s %s", dslKeywordIn, dslList(*c.FloatIn, dslFloat))
+		}
+		if c.FloatEqual != nil {
+			add("== %s", dslFloat(*c.FloatEqual))
+		}
+		if c.BooleanEqual != nil {
+			add("== %t", *c.BooleanEqual)
+		}
+		if c.UserPropEqual != nil {
+			user, err := dslUserRef(*c.UserPropEqual)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			add("== %s", user)
+		}
+		if c.Expression != nil {
+			add("%s %s", dslKeywordExpr, strconv.Quote(c.Expression.String()))
+		}
+		if c.ValidationFunc != nil {
+			arg, err := dslValidationFuncArg(*c.ValidationFunc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			add("%s %s(%s)", dslKeywordFn, c.ValidationFunc.Function, arg)
+		}
+
+		if len(comparisons) == before {
+			return nil, fmt.Errorf("%s: no comparison", key)
+		}
+	}
+	return comparisons, nil
+}
+
+func dslValidationFuncArg(fn ValidationFunc) (string, error) {
+	if !isDSLWord(fn.Function) {
+		return "", fmt.Errorf("invalid function name %q", fn.Function)
+	}
+	switch {
+	case !fn.IsValid():
+		return "", fmt.Errorf("ValidationFunc must have exactly one argument")
+	case fn.StringArg != nil:
+		return strconv.Quote(*fn.StringArg), nil
+	case fn.UserArg != nil:
+		return dslUserRef(*fn.UserArg)
+	default:
+		ref := dslKey(*fn.PropArg)
+		if !isDSLWord(ref) || !strings.HasPrefix(*fn.PropArg, propertyPrefix) {
+			return "", fmt.Errorf("cannot write PropArg %q", *fn.PropArg)
+		}
+		return ref, nil
+	}
+}
+
+// dslKey returns the word or string of a key, e.g. prop.doc.owner of "prop:::doc:owner".
+func dslKey(key string) string {
+	if strings.HasPrefix(key, propertyPrefix) {
+		if ref, ok := dslDottedRef("prop.", strings.TrimPrefix(key, propertyPrefix)); ok {
+			return ref
+		}
+		if isDSLWord(key) {
+			return key
+		}
+		return strconv.Quote(key)
+	}
+	if isDSLWord(key) && !strings.HasPrefix(key, "prop.") && !isDSLKeyword(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// dslUserRef returns the word of a user property, e.g. user.id of "user:::id".
+func dslUserRef(key string) (string, error) {
+	if !strings.HasPrefix(key, userPropertyPrefix) {
+		return "", fmt.Errorf("user property %q must start with %q", key, userPropertyPrefix)
+	}
+	if ref, ok := dslDottedRef("user.", strings.TrimPrefix(key, userPropertyPrefix)); ok {
+		return ref, nil
+	}
+	if !isDSLWord(key) {
+		return "", fmt.Errorf("cannot write user property %q", key)
+	}
+	return key, nil
+}
+
+// dslDottedRef joins the parts of a key with dots, if the parts do not hold dots themselves.
+func dslDottedRef(prefix, key string) (string, bool) {
+	parts := strings.Split(key, ":")
+	for _, part := range parts {
+		if part == "" || !isDSLWord(part) || strings.Contains(part, ".") {
+			return "", false
+		}
+	}
+	return prefix + strings.Join(parts, "."), true
+}
+
+// dslName returns the word of an action or a resource, or a string if it is not a word.
+func dslName(name string) string {
+	if isDSLWord(name) && !isDSLKeyword(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+func isDSLWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !isDSLWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func dslList[T any](values []T, format func(T) string) string {
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = format(value)
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// dslFloat returns the float with a decimal point, so that it is parsed as a float again.
+func dslFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,537 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type dslTokenKind int
+
+const (
+	dslTokenEOF dslTokenKind = iota
+	dslTokenWord
+	dslTokenString
+	dslTokenPunct
+)
+
+type dslToken struct {
+	kind   dslTokenKind
+	text   string
+	line   int
+	column int
+}
+
+func (t dslToken) String() string {
+	switch t.kind {
+	case dslTokenEOF:
+		return "end of file"
+	case dslTokenString:
+		return t.text
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+// isDSLWordRune reports whether the rune is part of a word, e.g. act:::doc:read, prop.doc.owner or 1.5.
+func isDSLWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_:.-*/@", r)
+}
+
+func tokenizeDSL(source string) ([]dslToken, error) {
+	var tokens []dslToken
+	line, column := 1, 1
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := dslToken{line: line, column: column}
+		switch {
+		case r == '\n':
+			i++
+			line, column = line+1, 1
+			continue
+
+		case unicode.IsSpace(r):
+			i++
+			column++
+			continue
+
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			continue
+
+		case isDSLWordRune(r):
+			j := i
+			for j < len(runes) && isDSLWordRune(runes[j]) {
+				j++
+			}
+			start.kind, start.text = dslTokenWord, string(runes[i:j])
+
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' && runes[j] != '\n' {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) || runes[j] != '"' {
+				return nil, &DSLError{Line: line, Column: column, Message: "unterminated string"}
+			}
+			j++
+			start.kind, start.text = dslTokenString, string(runes[i:j])
+			if _, err := strconv.Unquote(start.text); err != nil {
+				return nil, &DSLError{Line: line, Column: column, Message: "malformed string"}
+			}
+
+		case r == '=' && i+1 < len(runes) && runes[i+1] == '=':
+			start.kind, start.text = dslTokenPunct, "=="
+
+		case strings.ContainsRune("{}()[],", r):
+			start.kind, start.text = dslTokenPunct, string(r)
+
+		default:
+			return nil, &DSLError{Line: line, Column: column, Message: fmt.Sprintf("unexpected character %q", r)}
+		}
+
+		n := len([]rune(start.text))
+		i += n
+		column += n
+		tokens = append(tokens, start)
+	}
+	return append(tokens, dslToken{kind: dslTokenEOF, line: line, column: column}), nil
+}
+
+type dslParser struct {
+	tokens []dslToken
+	pos    int
+}
+
+func (p *dslParser) peek() dslToken {
+	return p.tokens[p.pos]
+}
+
+func (p *dslParser) next() dslToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != dslTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isNext reports whether the next token is the punctuation or the keyword.
+func (p *dslParser) isNext(text string) bool {
+	tok := p.peek()
+	return (tok.kind == dslTokenPunct || tok.kind == dslTokenWord) && tok.text == text
+}
+
+func (p *dslParser) expect(text string) (dslToken, error) {
+	if !p.isNext(text) {
+		return dslToken{}, p.errorf(p.peek(), "expected %q, but got %s", text, p.peek())
+	}
+	return p.next(), nil
+}
+
+func (p *dslParser) errorf(tok dslToken, format string, args ...interface{}) error {
+	return &DSLError{Line: tok.line, Column: tok.column, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *dslParser) parseFile() ([]Policy, error) {
+	policies := make([]Policy, 0)
+	for p.peek().kind != dslTokenEOF {
+		policy, err := p.parsePolicy()
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// parsePolicy parses `policy "id" [version N] { statement... }`.
+func (p *dslParser) parsePolicy() (Policy, error) {
+	var policy Policy
+	if _, err := p.expect(dslKeywordPolicy); err != nil {
+		return policy, err
+	}
+	id, err := p.parseName("PolicyID")
+	if err != nil {
+		return policy, err
+	}
+	policy.PolicyID = id
+
+	if p.isNext(dslKeywordVersion) {
+		p.next()
+		tok := p.next()
+		version, err := strconv.Atoi(tok.text)
+		if tok.kind != dslTokenWord || err != nil {
+			return policy, p.errorf(tok, "expected a version number, but got %s", tok)
+		}
+		policy.Version = version
+	}
+
+	if _, err := p.expect("{"); err != nil {
+		return policy, err
+	}
+	policy.Statements = make([]Statement, 0)
+	for !p.isNext("}") {
+		if p.peek().kind == dslTokenEOF {
+			return policy, p.errorf(p.peek(), "expected %q, but got %s", "}", p.peek())
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return policy, err
+		}
+		policy.Statements = append(policy.Statements, stmt)
+	}
+	p.next()
+	return policy, nil
+}
+
+// parseStatement parses `allow|deny action, ... on resource [when conditions]`.
+func (p *dslParser) parseStatement() (Statement, error) {
+	var stmt Statement
+	tok := p.next()
+	switch {
+	case tok.kind == dslTokenWord && tok.text == dslKeywordAllow:
+		stmt.Effect = statementEffectAllow
+	case tok.kind == dslTokenWord && tok.text == dslKeywordDeny:
+		stmt.Effect = statementEffectDeny
+	default:
+		return stmt, p.errorf(tok, "expected %q or %q, but got %s", dslKeywordAllow, dslKeywordDeny, tok)
+	}
+
+	for {
+		action, err := p.parseName("action")
+		if err != nil {
+			return stmt, err
+		}
+		stmt.Actions = append(stmt.Actions, action)
+		if !p.isNext(",") {
+			break
+		}
+		p.next()
+	}
+
+	if _, err := p.expect(dslKeywordOn); err != nil {
+		return stmt, err
+	}
+	resource, err := p.parseName("resource")
+	if err != nil {
+		return stmt, err
+	}
+	stmt.Resource = resource
+
+	if p.isNext(dslKeywordWhen) {
+		p.next()
+		if stmt.Conditions, err = p.parseConditions(); err != nil {
+			return stmt, err
+		}
+	}
+	return stmt, nil
+}
+
+// parseName parses a word or a string that is not a keyword.
+func (p *dslParser) parseName(what string) (string, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == dslTokenString:
+		s, _ := strconv.Unquote(tok.text)
+		return s, nil
+	case tok.kind == dslTokenWord && !isDSLKeyword(tok.text):
+		return tok.text, nil
+	default:
+		return "", p.errorf(tok, "expected %s, but got %s", what, tok)
+	}
+}
+
+// parseConditions parses `comparison and ... and any(comparison, ...)`, the comparisons are MustHaveAll
+// and the comparisons of any(...) are AtLeastOne.
+func (p *dslParser) parseConditions() (*Condition, error) {
+	condition := &Condition{}
+	for {
+		if p.isNext(dslKeywordAny) {
+			tok := p.next()
+			if condition.AtLeastOne != nil {
+				return nil, p.errorf(tok, "only one any(...) is allowed in a statement")
+			}
+			if _, err := p.expect("("); err != nil {
+				return nil, err
+			}
+			condition.AtLeastOne = make(map[string]Comparator)
+			for {
+				if err := p.parseComparison(condition.AtLeastOne); err != nil {
+					return nil, err
+				}
+				if !p.isNext(",") {
+					break
+				}
+				p.next()
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		} else {
+			if condition.MustHaveAll == nil {
+				condition.MustHaveAll = make(map[string]Comparator)
+			}
+			if err := p.parseComparison(condition.MustHaveAll); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.isNext(dslKeywordOr) {
+			return nil, p.errorf(p.peek(), "%q is not supported, use any(...)", dslKeywordOr)
+		}
+		if !p.isNext(dslKeywordAnd) {
+			return condition, nil
+		}
+		p.next()
+	}
+}
+
+// parseComparison parses `key == value`, `key in [value, ...]`, `key expr "expression"` or `key fn name(argument)`,
+// and adds it to the comparator of the key.
+func (p *dslParser) parseComparison(conditions map[string]Comparator) error {
+	keyTok := p.next()
+	var key string
+	switch {
+	case keyTok.kind == dslTokenString:
+		key, _ = strconv.Unquote(keyTok.text)
+	case keyTok.kind == dslTokenWord && !isDSLKeyword(keyTok.text):
+		key = dslPropertyKey(keyTok.text)
+	default:
+		return p.errorf(keyTok, "expected a property, but got %s", keyTok)
+	}
+
+	comparator := conditions[key]
+	opTok := p.next()
+	duplicate := false
+	switch {
+	case opTok.kind == dslTokenPunct && opTok.text == "==":
+		valueTok := p.next()
+		switch value := p.value(valueTok).(type) {
+		case string:
+			duplicate = comparator.StringEqual != nil
+			comparator.StringEqual = &value
+		case int:
+			duplicate = comparator.IntegerEqual != nil
+			comparator.IntegerEqual = &value
+		case float64:
+			duplicate = comparator.FloatEqual != nil
+			comparator.FloatEqual = &value
+		case bool:
+			duplicate = comparator.BooleanEqual != nil
+			comparator.BooleanEqual = &value
+		case dslUserProperty:
+			duplicate = comparator.UserPropEqual != nil
+			s := string(value)
+			comparator.UserPropEqual = &s
+		default:
+			return p.errorf(valueTok, "expected a string, a number, a boolean or a user property, but got %s", valueTok)
+		}
+
+	case opTok.kind == dslTokenWord && opTok.text == dslKeywordIn:
+		var err error
+		if duplicate, err = p.parseList(&comparator); err != nil {
+			return err
+		}
+
+	case opTok.kind == dslTokenWord && opTok.text == dslKeywordPrefix:
+		valueTok := p.next()
+		if valueTok.kind != dslTokenString {
+			return p.errorf(valueTok, "expected a string, but got %s", valueTok)
+		}
+		value, _ := strconv.Unquote(valueTok.text)
+		duplicate = comparator.StringPrefix != nil
+		comparator.StringPrefix = &value
+
+	case opTok.kind == dslTokenWord && opTok.text == dslKeywordExpr:
+		sourceTok := p.next()
+		if sourceTok.kind != dslTokenString {
+			return p.errorf(sourceTok, "expected an expression string, but got %s", sourceTok)
+		}
+		source, _ := strconv.Unquote(sourceTok.text)
+		expression, err := CompileExpression(source)
+		if err != nil {
+			return p.errorf(sourceTok, "%v", err)
+		}
+		duplicate = comparator.Expression != nil
+		comparator.Expression = expression
+
+	case opTok.kind == dslTokenWord && opTok.text == dslKeywordFn:
+		fn, err := p.parseValidationFunc()
+		if err != nil {
+			return err
+		}
+		duplicate = comparator.ValidationFunc != nil
+		comparator.ValidationFunc = fn
+
+	default:
+		return p.errorf(opTok, "expected \"==\", %q, %q, %q or %q, but got %s", dslKeywordIn, dslKeywordPrefix, dslKeywordExpr, dslKeywordFn, opTok)
+	}
+
+	if duplicate {
+		return p.errorf(opTok, "%s already has a %s comparison", keyTok, opTok.text)
+	}
+	conditions[key] = comparator
+	return nil
+}
+
+// parseList parses `[value, ...]` into StringIn, IntegerIn or FloatIn by the type of its values.
+func (p *dslParser) parseList(comparator *Comparator) (bool, error) {
+	open, err := p.expect("[")
+	if err != nil {
+		return false, err
+	}
+
+	var values []interface{}
+	for !p.isNext("]") {
+		if len(values) > 0 {
+			if _, err := p.expect(","); err != nil {
+				return false, err
+			}
+		}
+		tok := p.next()
+		switch value := p.value(tok).(type) {
+		case string, int, float64:
+			values = append(values, value)
+		default:
+			return false, p.errorf(tok, "expected a string or a number, but got %s", tok)
+		}
+	}
+	p.next()
+
+	var hasString, hasInteger, hasFloat bool
+	for _, value := range values {
+		switch value.(type) {
+		case string:
+			hasString = true
+		case int:
+			hasInteger = true
+		case float64:
+			hasFloat = true
+		}
+	}
+	if hasString && (hasInteger || hasFloat) {
+		return false, p.errorf(open, "a list cannot mix strings and numbers")
+	}
+	kind := "string"
+	if hasFloat {
+		kind = "float"
+	} else if hasInteger {
+		kind = "integer"
+	}
+
+	switch kind {
+	case "integer":
+		list := make([]int, len(values))
+		for i, value := range values {
+			list[i] = value.(int)
+		}
+		duplicate := comparator.IntegerIn != nil
+		comparator.IntegerIn = &list
+		return duplicate, nil
+	case "float":
+		list := make([]float64, len(values))
+		for i, value := range values {
+			if n, ok := value.(int); ok {
+				list[i] = float64(n)
+			} else {
+				list[i] = value.(float64)
+			}
+		}
+		duplicate := comparator.FloatIn != nil
+		comparator.FloatIn = &list
+		return duplicate, nil
+	default:
+		list := make([]string, len(values))
+		for i, value := range values {
+			list[i] = value.(string)
+		}
+		duplicate := comparator.StringIn != nil
+		comparator.StringIn = &list
+		return duplicate, nil
+	}
+}
+
+// parseValidationFunc parses `name(argument)`, the argument is a string, a user property or a resource property.
+func (p *dslParser) parseValidationFunc() (*ValidationFunc, error) {
+	nameTok := p.next()
+	if nameTok.kind != dslTokenWord || isDSLKeyword(nameTok.text) {
+		return nil, p.errorf(nameTok, "expected a function name, but got %s", nameTok)
+	}
+	fn := &ValidationFunc{Function: nameTok.text}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	argTok := p.next()
+	switch value := p.value(argTok).(type) {
+	case string:
+		fn.StringArg = &value
+	case dslUserProperty:
+		s := string(value)
+		fn.UserArg = &s
+	default:
+		if argTok.kind != dslTokenWord || isDSLKeyword(argTok.text) {
+			return nil, p.errorf(argTok, "expected a string, a user property or a property, but got %s", argTok)
+		}
+		key := dslPropertyKey(argTok.text)
+		fn.PropArg = &key
+	}
+
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// dslUserProperty is a user property value, e.g. "user:::id" of user.id.
+type dslUserProperty string
+
+// value returns the string, int, float64, bool or dslUserProperty of the token, or nil if it is not a value.
+func (p *dslParser) value(tok dslToken) interface{} {
+	if tok.kind == dslTokenString {
+		s, _ := strconv.Unquote(tok.text)
+		return s
+	}
+	if tok.kind != dslTokenWord {
+		return nil
+	}
+	switch {
+	case tok.text == "true":
+		return true
+	case tok.text == "false":
+		return false
+	case strings.HasPrefix(tok.text, userPropertyPrefix):
+		return dslUserProperty(tok.text)
+	case strings.HasPrefix(tok.text, "user."):
+		return dslUserProperty(userPropertyPrefix + strings.ReplaceAll(strings.TrimPrefix(tok.text, "user."), ".", ":"))
+	case isDSLNumber(tok.text):
+		if n, err := strconv.Atoi(tok.text); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// dslPropertyKey returns the key of a property word, e.g. "prop:::doc:owner" of prop.doc.owner.
+func dslPropertyKey(word string) string {
+	if strings.HasPrefix(word, "prop.") {
+		return propertyPrefix + strings.ReplaceAll(strings.TrimPrefix(word, "prop."), ".", ":")
+	}
+	return word
+}
+
+func isDSLNumber(word string) bool {
+	s := strings.TrimPrefix(word, "-")
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
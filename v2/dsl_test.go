@@ -0,0 +1,314 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicyDSL(t *testing.T) {
+	// Arrange
+	source := `# documents of the organization
+policy "documents" version 1 {
+    allow act:::doc:read on res:::doc
+    allow act:::doc:write, act:::doc:delete on res:::doc
+        when prop.doc.owner == user.id
+        and "my key" in [1, 2]
+    deny act:::doc:write on "res:::doc"
+        when prop.doc.locked == true
+        and any(prop.doc.pages expr "prop.doc.pages > 1000", prop.doc.tag in ["legal"], prop.doc.org fn isMember(user.org))
+}
+`
+	want := []Policy{{
+		Version:  1,
+		PolicyID: "documents",
+		Statements: []Statement{
+			{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+			{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:write", "act:::doc:delete"}, Conditions: &Condition{
+				MustHaveAll: map[string]Comparator{
+					"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+					"my key":           {IntegerIn: &[]int{1, 2}},
+				},
+			}},
+			{Effect: "Deny", Resource: "res:::doc", Actions: []string{"act:::doc:write"}, Conditions: &Condition{
+				MustHaveAll: map[string]Comparator{
+					"prop:::doc:locked": {BooleanEqual: ptr(true)},
+				},
+				AtLeastOne: map[string]Comparator{
+					"prop:::doc:pages": {Expression: mustCompileExpression(t, "prop.doc.pages > 1000")},
+					"prop:::doc:tag":   {StringIn: &[]string{"legal"}},
+					"prop:::doc:org":   {ValidationFunc: &ValidationFunc{Function: "isMember", UserArg: ptr("user:::org")}},
+				},
+			}},
+		},
+	}}
+
+	// Act
+	got, err := ParsePolicyDSL([]byte(source))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+}
+
+func TestParsePolicyDSL_Comparisons(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparison string
+		want       Comparator
+	}{
+		{"string equal", `key == "a"`, Comparator{StringEqual: ptr("a")}},
+		{"integer equal", `key == -1`, Comparator{IntegerEqual: ptr(-1)}},
+		{"float equal", `key == 1.5`, Comparator{FloatEqual: ptr(1.5)}},
+		{"boolean equal", `key == false`, Comparator{BooleanEqual: ptr(false)}},
+		{"user property equal", `key == user.org.id`, Comparator{UserPropEqual: ptr("user:::org:id")}},
+		{"string in", `key in ["a", "b"]`, Comparator{StringIn: &[]string{"a", "b"}}},
+		{"string prefix", `key prefix "/docs/"`, Comparator{StringPrefix: ptr("/docs/")}},
+		{"integer in", `key in [1, 2]`, Comparator{IntegerIn: &[]int{1, 2}}},
+		{"float in", `key in [1.5, 2]`, Comparator{FloatIn: &[]float64{1.5, 2}}},
+		{"function with string", `key fn isValid("a")`, Comparator{ValidationFunc: &ValidationFunc{Function: "isValid", StringArg: ptr("a")}}},
+		{"function with property", `key fn isValid(prop.other)`, Comparator{ValidationFunc: &ValidationFunc{Function: "isValid", PropArg: ptr("prop:::other")}}},
+		{"several comparisons", `key == "a" and key in [1, 2]`, Comparator{StringEqual: ptr("a"), IntegerIn: &[]int{1, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			source := "policy \"p\" {\n    allow act on res when " + tt.comparison + "\n}\n"
+
+			// Act
+			got, err := ParsePolicyDSL([]byte(source))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if c := got[0].Statements[0].Conditions.MustHaveAll["key"]; !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("want %+v, but got %+v", tt.want, c)
+			}
+		})
+	}
+}
+
+func TestParsePolicyDSL_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		wantLine   int
+		wantColumn int
+		wantErr    string
+	}{
+		{
+			name:       "unknown effect",
+			source:     "policy \"p\" {\n    permit act on res\n}\n",
+			wantLine:   2,
+			wantColumn: 5,
+			wantErr:    `expected "allow" or "deny", but got "permit"`,
+		},
+		{
+			name:       "missing resource",
+			source:     "policy \"p\" {\n    allow act on\n}\n",
+			wantLine:   3,
+			wantColumn: 1,
+			wantErr:    `expected resource, but got "}"`,
+		},
+		{
+			name:       "or",
+			source:     "policy \"p\" {\n    allow act on res when a == 1 or b == 2\n}\n",
+			wantLine:   2,
+			wantColumn: 34,
+			wantErr:    `"or" is not supported, use any(...)`,
+		},
+		{
+			name:       "duplicate comparison",
+			source:     "policy \"p\" {\n    allow act on res when a == 1 and a == 2\n}\n",
+			wantLine:   2,
+			wantColumn: 40,
+			wantErr:    `"a" already has a == comparison`,
+		},
+		{
+			name:       "unterminated string",
+			source:     "policy \"p {\n}\n",
+			wantLine:   1,
+			wantColumn: 8,
+			wantErr:    "unterminated string",
+		},
+		{
+			name:       "unclosed policy",
+			source:     "policy \"p\" {\n    allow act on res\n",
+			wantLine:   3,
+			wantColumn: 1,
+			wantErr:    `expected "}", but got end of file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var dslErr *DSLError
+
+			// Act
+			_, err := ParsePolicyDSL([]byte(tt.source))
+
+			// Assert
+			if !errors.As(err, &dslErr) {
+				t.Fatalf("want *DSLError, but got %v", err)
+			}
+			if dslErr.Line != tt.wantLine || dslErr.Column != tt.wantColumn {
+				t.Errorf("want line %d, column %d, but got line %d, column %d", tt.wantLine, tt.wantColumn, dslErr.Line, dslErr.Column)
+			}
+			if !strings.Contains(dslErr.Message, tt.wantErr) {
+				t.Errorf("want %q, but got %q", tt.wantErr, dslErr.Message)
+			}
+		})
+	}
+}
+
+func TestMarshalPolicyDSL(t *testing.T) {
+	// Arrange
+	policies := []Policy{{
+		Version:  1,
+		PolicyID: "documents",
+		Statements: []Statement{
+			{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read", "act:::doc:write"}, Conditions: &Condition{
+				MustHaveAll: map[string]Comparator{
+					"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+					"prop:::doc:size":  {FloatEqual: ptr(2.0)},
+				},
+				AtLeastOne: map[string]Comparator{
+					"prop:::doc:tag":  {StringIn: &[]string{"a", "b"}},
+					"prop:::doc:open": {BooleanEqual: ptr(true)},
+				},
+			}},
+			{Effect: "Deny", Resource: "on", Actions: []string{"act:::doc:delete"}},
+		},
+	}}
+	want := `policy "documents" version 1 {
+    allow act:::doc:read, act:::doc:write on res:::doc
+        when prop.doc.owner == user.id
+        and prop.doc.size == 2.0
+        and any(
+            prop.doc.open == true,
+            prop.doc.tag in ["a", "b"]
+        )
+    deny act:::doc:delete on "on"
+}
+`
+
+	// Act
+	got, err := MarshalPolicyDSL(policies)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("want %s, but got %s", want, got)
+	}
+}
+
+func TestMarshalPolicyDSL_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"policy", "test_data/parse_policy/policy_full.json"},
+		{"array", "test_data/parse_policy/policy_array_full.json"},
+		{"expression", "test_data/parse_policy/policy_Expression.json"},
+		{"validation function", "test_data/parse_policy/policy_ValidationFunc.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			want, err := ParsePolicyFile(tt.file, mustReadFile(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Act
+			b, err := MarshalPolicyDSL(want)
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			got, err := ParsePolicyDSL(b)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v\n%s", err, b)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("want %+v, but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestMarshalPolicyDSL_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		stmt    Statement
+		wantErr string
+	}{
+		{
+			name:    "invalid effect",
+			stmt:    Statement{Effect: "Maybe", Resource: "res", Actions: []string{"act"}},
+			wantErr: "invalid effect: Maybe",
+		},
+		{
+			name:    "no actions",
+			stmt:    Statement{Effect: "Allow", Resource: "res"},
+			wantErr: "no actions",
+		},
+		{
+			name: "no comparison",
+			stmt: Statement{Effect: "Allow", Resource: "res", Actions: []string{"act"}, Conditions: &Condition{
+				MustHaveAll: map[string]Comparator{"prop:::a": {}},
+			}},
+			wantErr: "prop:::a: no comparison",
+		},
+		{
+			name: "user property without prefix",
+			stmt: Statement{Effect: "Allow", Resource: "res", Actions: []string{"act"}, Conditions: &Condition{
+				MustHaveAll: map[string]Comparator{"prop:::a": {UserPropEqual: ptr("id")}},
+			}},
+			wantErr: `user property "id" must start with "user:::"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			policies := []Policy{{Version: 1, PolicyID: "p", Statements: []Statement{tt.stmt}}}
+
+			// Act
+			_, err := MarshalPolicyDSL(policies)
+
+			// Assert
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("want %q, but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParsePolicyFile_DSL(t *testing.T) {
+	// Arrange
+	source := "policy \"p\" version 1 {\n    allow act on res\n}\n"
+
+	// Act
+	got, err := ParsePolicyFile("p.policy", []byte(source))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if len(got) != 1 || got[0].PolicyID != "p" {
+		t.Errorf("want policy p, but got %+v", got)
+	}
+}
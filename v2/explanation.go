@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reason is why access is allowed or denied.
+type Reason string
+
+const (
+	ReasonAllowed       Reason = "allowed by statements"
+	ReasonNoMatch       Reason = "no statement matched"
+	ReasonDenyStatement Reason = "denied by statement"
+	ReasonPostValidator Reason = "denied by post validator"
+	ReasonOverridden    Reason = "decided by validation overrider"
+)
+
+// Explanation is the decision of IsAccessAllowed and the statements it is based on.
+type Explanation struct {
+	Allowed bool
+	Reason  Reason
+	// Matched are the statements that match the resource, the action and their conditions.
+	Matched []MatchedStatement
+}
+
+// MatchedStatement is a statement at an index of the statements of a policy.
+type MatchedStatement struct {
+	PolicyID string
+	Index    int
+	Effect   string
+}
+
+// String returns the explanation for a person, e.g. `denied: denied by statement (policy "p1" statement 0)`.
+func (e Explanation) String() string {
+	decision := "denied"
+	if e.Allowed {
+		decision = "allowed"
+	}
+
+	var statements []MatchedStatement
+	for _, stmt := range e.Matched {
+		// only the statements that decide a denial
+		if e.Reason != ReasonDenyStatement || stmt.Effect == statementEffectDeny {
+			statements = append(statements, stmt)
+		}
+	}
+	if len(statements) == 0 {
+		return fmt.Sprintf("%s: %s", decision, e.Reason)
+	}
+
+	refs := make([]string, 0, len(statements))
+	for _, stmt := range statements {
+		refs = append(refs, fmt.Sprintf("policy %q statement %d", stmt.PolicyID, stmt.Index))
+	}
+	return fmt.Sprintf("%s: %s (%s)", decision, e.Reason, strings.Join(refs, ", "))
+}
+
+// Explainer returns the decision with its explanation.
+type Explainer interface {
+	Explain(ctx context.Context, user UserPropertyGetter, res Resource) (Explanation, error)
+}
+
+// ExplainAccess checks the access like IsAccessAllowed, and explains the decision.
+// The decision is recorded by the DecisionLogger and the Metrics, if they are not nil.
+func (pv *policyValidator) ExplainAccess() (Explanation, error) {
+	start := time.Now()
+	var explanation Explanation
+	allowed, err := pv.traceDecision(pv.resource, func(pv *policyValidator) (bool, error) {
+		var err error
+		explanation, err = pv.explainAndLog()
+		return explanation.Allowed, err
+	})
+	pv.observeDecision(pv.resource, allowed, err, start)
+	return explanation, err
+}
+
+// explainAndLog explains the decision, and records it with the DecisionLogger.
+func (pv *policyValidator) explainAndLog() (Explanation, error) {
+	explanation, res, err := pv.evaluate(true)
+	return pv.logDecision(res, explanation, err)
+}
+
+// Explain explains the decision of Authorize.
+func (pv *policyValidator) Explain(ctx context.Context, user UserPropertyGetter, res Resource) (Explanation, error) {
+	validator := *pv
+	validator.UserPropertyGetter = user
+	validator.resource = res
+	validator.ctx = ctx
+	return validator.ExplainAccess()
+}
+
+// Explain explains the decision of Authorize with the current policies.
+func (l *PolicyLoader) Explain(ctx context.Context, user UserPropertyGetter, res Resource) (Explanation, error) {
+	return l.New().Explain(ctx, user, res)
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func explanationPolicies() []Policy {
+	return []Policy{
+		{
+			PolicyID: "readers",
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{"prop:::doc:public": {BooleanEqual: ptr(true)}},
+					},
+				},
+			},
+		},
+		{
+			PolicyID: "locked",
+			Statements: []Statement{
+				{
+					Effect:   statementEffectDeny,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{"prop:::doc:locked": {BooleanEqual: ptr(true)}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExplainAccess(t *testing.T) {
+	tests := []struct {
+		name          string
+		action        string
+		prop          Property
+		postValidator *MockPostValidator
+		want          Explanation
+		wantString    string
+	}{
+		{
+			name:       "allowed",
+			action:     "act:::doc:read",
+			prop:       Property{Boolean: map[string]bool{"prop:::doc:public": true}},
+			want:       Explanation{Allowed: true, Reason: ReasonAllowed, Matched: []MatchedStatement{{"readers", 0, "Allow"}, {"readers", 1, "Allow"}}},
+			wantString: `allowed: allowed by statements (policy "readers" statement 0, policy "readers" statement 1)`,
+		},
+		{
+			name:       "denied by statement",
+			action:     "act:::doc:read",
+			prop:       Property{Boolean: map[string]bool{"prop:::doc:locked": true}},
+			want:       Explanation{Reason: ReasonDenyStatement, Matched: []MatchedStatement{{"readers", 0, "Allow"}, {"locked", 0, "Deny"}}},
+			wantString: `denied: denied by statement (policy "locked" statement 0)`,
+		},
+		{
+			name:       "no match",
+			action:     "act:::doc:write",
+			want:       Explanation{Reason: ReasonNoMatch},
+			wantString: `denied: no statement matched`,
+		},
+		{
+			name:          "denied by post validator",
+			action:        "act:::doc:read",
+			postValidator: &MockPostValidator{Result: false},
+			want:          Explanation{Reason: ReasonPostValidator, Matched: []MatchedStatement{{"readers", 0, "Allow"}}},
+			wantString:    `denied: denied by post validator (policy "readers" statement 0)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = explanationPolicies()
+			pv.SetResource("res:::doc")
+			pv.SetAction(tt.action)
+			pv.AddProperties(tt.prop)
+			if tt.postValidator != nil {
+				pv.AddPostExecutor(tt.postValidator)
+			}
+
+			// Act
+			got, err := pv.ExplainAccess()
+			allowed, _ := pv.IsAccessAllowed()
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %+v, but got %+v", tt.want, got)
+			}
+			if got.String() != tt.wantString {
+				t.Errorf("want %s, but got %s", tt.wantString, got)
+			}
+			if got.Allowed != allowed {
+				t.Errorf("want the decision of IsAccessAllowed %v, but got %v", allowed, got.Allowed)
+			}
+		})
+	}
+}
+
+func TestExplainAccess_Errors(t *testing.T) {
+	errPost := errors.New("post validator error")
+
+	tests := []struct {
+		name  string
+		setup func(pv *policyValidator)
+	}{
+		{"validator with Error", func(pv *policyValidator) { pv.SetError(errors.New("error")) }},
+		{"invalid effect", func(pv *policyValidator) { pv.Policies[0].Statements[0].Effect = "Maybe" }},
+		{"post validator error", func(pv *policyValidator) { pv.AddPostExecutor(&MockPostValidator{Error: errPost}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = explanationPolicies()
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+			tt.setup(pv)
+
+			// Act
+			_, err := pv.ExplainAccess()
+
+			// Assert
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
+
+func TestExplainAccess_Overridden(t *testing.T) {
+	// Arrange
+	pv := New()
+	pv.ValidationOverrider = &MockValidationOverrider{Result: true}
+
+	// Act
+	got, err := pv.Explain(context.Background(), nil, Resource{Resource: "res:::doc", Action: "act:::doc:read"})
+
+	// Assert
+	if err != nil || !got.Allowed || got.Reason != ReasonOverridden {
+		t.Errorf("want allowed by the overrider, but got %+v, %v", got, err)
+	}
+}
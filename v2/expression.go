@@ -0,0 +1,696 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	maxExpressionLength = 4096
+	maxExpressionNodes  = 512
+	maxExpressionDepth  = 32
+	maxExpressionCost   = 10000
+)
+
+const (
+	namespaceProp = "prop"
+	namespaceUser = "user"
+	namespaceSys  = "sys"
+)
+
+// timeNow is replaced in tests to get a deterministic "sys" namespace.
+var timeNow = time.Now
+
+// Expression is a compiled condition expression.
+//
+// An expression is side-effect free and is evaluated against three namespaces:
+// "prop" (resource properties), "user" (user properties) and "sys" (system values).
+// A reference such as prop.employee.organization_uuid is looked up with the key
+// "prop:::employee:organization_uuid", the same key used by the other comparators.
+//
+// Expressions are type-checked when they are compiled, which happens when a policy is parsed.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// CompileExpression parses and type-checks the source of an expression.
+func CompileExpression(source string) (*Expression, error) {
+	if len(source) > maxExpressionLength {
+		return nil, fmt.Errorf("invalid expression: longer than %d characters", maxExpressionLength)
+	}
+
+	p, err := newExprParser(source)
+	if err != nil {
+		return nil, err
+	}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := checkExprNode(root)
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != typeBool && t.kind != typeAny {
+		return nil, fmt.Errorf("invalid expression: result must be boolean, but got %s", t)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+func (e *Expression) MarshalText() ([]byte, error) {
+	return []byte(e.source), nil
+}
+
+func (e *Expression) UnmarshalText(b []byte) error {
+	compiled, err := CompileExpression(string(b))
+	if err != nil {
+		return err
+	}
+	*e = *compiled
+	return nil
+}
+
+// evaluate runs the expression and reports whether it evaluates to true.
+func (e *Expression) evaluate(env *exprEnv) (bool, error) {
+	env.budget = maxExpressionCost
+	v, err := env.eval(e.root)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression result must be boolean, but got %s", valueTypeName(v))
+	}
+	return b, nil
+}
+
+// references returns the keys of all references in the expression, e.g. "prop:::employee:uuid".
+func (e *Expression) references() []string {
+	var keys []string
+	walkExprNode(e.root, func(node exprNode) {
+		if ref, ok := node.(*refNode); ok {
+			keys = append(keys, ref.key())
+		}
+	})
+	return keys
+}
+
+// ----------------------------------------------
+// Syntax tree
+// ----------------------------------------------
+
+type exprNode interface {
+	position() int
+}
+
+type literalNode struct {
+	pos   int
+	value interface{}
+}
+
+type listNode struct {
+	pos   int
+	elems []exprNode
+}
+
+type refNode struct {
+	pos       int
+	namespace string
+	path      []string
+}
+
+type unaryNode struct {
+	pos int
+	op  string
+	x   exprNode
+}
+
+type binaryNode struct {
+	pos int
+	op  string
+	x   exprNode
+	y   exprNode
+}
+
+type callNode struct {
+	pos  int
+	fn   string
+	args []exprNode
+}
+
+func (n *literalNode) position() int { return n.pos }
+func (n *listNode) position() int    { return n.pos }
+func (n *refNode) position() int     { return n.pos }
+func (n *unaryNode) position() int   { return n.pos }
+func (n *binaryNode) position() int  { return n.pos }
+func (n *callNode) position() int    { return n.pos }
+
+// key returns the value ref key of the reference, e.g. "prop:::employee:uuid".
+func (n *refNode) key() string {
+	return n.namespace + ":::" + strings.Join(n.path, ":")
+}
+
+func walkExprNode(node exprNode, fn func(exprNode)) {
+	fn(node)
+	switch n := node.(type) {
+	case *listNode:
+		for _, e := range n.elems {
+			walkExprNode(e, fn)
+		}
+	case *unaryNode:
+		walkExprNode(n.x, fn)
+	case *binaryNode:
+		walkExprNode(n.x, fn)
+		walkExprNode(n.y, fn)
+	case *callNode:
+		for _, arg := range n.args {
+			walkExprNode(arg, fn)
+		}
+	}
+}
+
+// ----------------------------------------------
+// Types
+// ----------------------------------------------
+
+type typeKind int
+
+const (
+	typeAny typeKind = iota
+	typeString
+	typeInt
+	typeFloat
+	typeBool
+	typeList
+)
+
+type exprType struct {
+	kind typeKind
+	elem typeKind
+}
+
+func (t exprType) String() string {
+	switch t.kind {
+	case typeString:
+		return "string"
+	case typeInt:
+		return "integer"
+	case typeFloat:
+		return "float"
+	case typeBool:
+		return "boolean"
+	case typeList:
+		return "list of " + exprType{kind: t.elem}.String()
+	default:
+		return "any"
+	}
+}
+
+func (t exprType) isNumber() bool {
+	return t.kind == typeInt || t.kind == typeFloat
+}
+
+// sysVariables are the values available in the "sys" namespace.
+var sysVariables = map[string]typeKind{
+	"sys:::time:now":  typeString,
+	"sys:::time:unix": typeInt,
+	"sys:::resource":  typeString,
+	"sys:::action":    typeString,
+}
+
+type exprFunction struct {
+	args   []typeKind
+	result typeKind
+	call   func(args []interface{}) interface{}
+}
+
+var exprFunctions = map[string]exprFunction{
+	"startsWith": {
+		args:   []typeKind{typeString, typeString},
+		result: typeBool,
+		call: func(args []interface{}) interface{} {
+			return strings.HasPrefix(args[0].(string), args[1].(string))
+		},
+	},
+	"endsWith": {
+		args:   []typeKind{typeString, typeString},
+		result: typeBool,
+		call: func(args []interface{}) interface{} {
+			return strings.HasSuffix(args[0].(string), args[1].(string))
+		},
+	},
+	"contains": {
+		args:   []typeKind{typeString, typeString},
+		result: typeBool,
+		call: func(args []interface{}) interface{} {
+			return strings.Contains(args[0].(string), args[1].(string))
+		},
+	},
+	"lower": {
+		args:   []typeKind{typeString},
+		result: typeString,
+		call: func(args []interface{}) interface{} {
+			return strings.ToLower(args[0].(string))
+		},
+	},
+	"upper": {
+		args:   []typeKind{typeString},
+		result: typeString,
+		call: func(args []interface{}) interface{} {
+			return strings.ToUpper(args[0].(string))
+		},
+	},
+	"trim": {
+		args:   []typeKind{typeString},
+		result: typeString,
+		call: func(args []interface{}) interface{} {
+			return strings.TrimSpace(args[0].(string))
+		},
+	},
+	"len": {
+		args:   []typeKind{typeString},
+		result: typeInt,
+		call: func(args []interface{}) interface{} {
+			return len(args[0].(string))
+		},
+	},
+}
+
+func checkExprNode(node exprNode) (exprType, error) {
+	switch n := node.(type) {
+	case *literalNode:
+		return exprType{kind: valueTypeKind(n.value)}, nil
+
+	case *listNode:
+		elem := typeAny
+		for _, e := range n.elems {
+			t, err := checkExprNode(e)
+			if err != nil {
+				return exprType{}, err
+			}
+			switch {
+			case elem == typeAny:
+				elem = t.kind
+			case elem == t.kind:
+			case exprType{kind: elem}.isNumber() && t.isNumber():
+				elem = typeFloat
+			default:
+				return exprType{}, exprErrorf(e, "list elements must have the same type, got %s and %s", exprType{kind: elem}, t)
+			}
+		}
+		return exprType{kind: typeList, elem: elem}, nil
+
+	case *refNode:
+		switch n.namespace {
+		case namespaceProp:
+			return exprType{kind: typeAny}, nil
+		case namespaceUser:
+			return exprType{kind: typeString}, nil
+		default:
+			kind, ok := sysVariables[n.key()]
+			if !ok {
+				return exprType{}, exprErrorf(n, "unknown system value %s", strings.Join(append([]string{n.namespace}, n.path...), "."))
+			}
+			return exprType{kind: kind}, nil
+		}
+
+	case *unaryNode:
+		t, err := checkExprNode(n.x)
+		if err != nil {
+			return exprType{}, err
+		}
+		if n.op == "-" {
+			if !t.isNumber() && t.kind != typeAny {
+				return exprType{}, exprErrorf(n, "operator - requires a number, but got %s", t)
+			}
+			return t, nil
+		}
+		if t.kind != typeBool && t.kind != typeAny {
+			return exprType{}, exprErrorf(n, "operator ! requires a boolean, but got %s", t)
+		}
+		return exprType{kind: typeBool}, nil
+
+	case *binaryNode:
+		x, err := checkExprNode(n.x)
+		if err != nil {
+			return exprType{}, err
+		}
+		y, err := checkExprNode(n.y)
+		if err != nil {
+			return exprType{}, err
+		}
+		return checkBinary(n, x, y)
+
+	case *callNode:
+		fn, ok := exprFunctions[n.fn]
+		if !ok {
+			return exprType{}, exprErrorf(n, "unknown function %s", n.fn)
+		}
+		if len(n.args) != len(fn.args) {
+			return exprType{}, exprErrorf(n, "function %s expects %d arguments, but got %d", n.fn, len(fn.args), len(n.args))
+		}
+		for i, arg := range n.args {
+			t, err := checkExprNode(arg)
+			if err != nil {
+				return exprType{}, err
+			}
+			if t.kind != fn.args[i] && t.kind != typeAny {
+				return exprType{}, exprErrorf(arg, "argument %d of %s must be %s, but got %s", i+1, n.fn, exprType{kind: fn.args[i]}, t)
+			}
+		}
+		return exprType{kind: fn.result}, nil
+	}
+
+	return exprType{}, exprErrorf(node, "unsupported expression")
+}
+
+func checkBinary(n *binaryNode, x, y exprType) (exprType, error) {
+	switch n.op {
+	case "&&", "||":
+		for _, t := range []exprType{x, y} {
+			if t.kind != typeBool && t.kind != typeAny {
+				return exprType{}, exprErrorf(n, "operator %s requires booleans, but got %s", n.op, t)
+			}
+		}
+	case "==", "!=":
+		if !isComparableTypes(x, y) {
+			return exprType{}, exprErrorf(n, "cannot compare %s with %s", x, y)
+		}
+	case "<", "<=", ">", ">=":
+		if x.kind == typeBool || x.kind == typeList || y.kind == typeBool || y.kind == typeList || !isComparableTypes(x, y) {
+			return exprType{}, exprErrorf(n, "operator %s is not defined on %s and %s", n.op, x, y)
+		}
+	case "in":
+		if y.kind != typeList {
+			return exprType{}, exprErrorf(n, "right side of in must be a list, but got %s", y)
+		}
+		if y.elem != typeAny && !isComparableTypes(x, exprType{kind: y.elem}) {
+			return exprType{}, exprErrorf(n, "cannot look for %s in %s", x, y)
+		}
+	}
+	return exprType{kind: typeBool}, nil
+}
+
+func isComparableTypes(x, y exprType) bool {
+	if x.kind == typeAny || y.kind == typeAny {
+		return x.kind != typeList && y.kind != typeList
+	}
+	if x.isNumber() && y.isNumber() {
+		return true
+	}
+	return x.kind == y.kind && x.kind != typeList
+}
+
+func exprErrorf(node exprNode, format string, args ...interface{}) error {
+	return fmt.Errorf("invalid expression: %s at column %d", fmt.Sprintf(format, args...), node.position()+1)
+}
+
+// ----------------------------------------------
+// Evaluation
+// ----------------------------------------------
+
+// exprEnv holds the values an expression is evaluated against.
+type exprEnv struct {
+	prop   Property
+	user   UserPropertyGetter
+	res    Resource
+	now    time.Time
+	budget int
+}
+
+func (env *exprEnv) eval(node exprNode) (interface{}, error) {
+	env.budget--
+	if env.budget < 0 {
+		return nil, fmt.Errorf("expression exceeded the evaluation cost limit of %d", maxExpressionCost)
+	}
+
+	switch n := node.(type) {
+	case *literalNode:
+		return n.value, nil
+
+	case *listNode:
+		list := make([]interface{}, 0, len(n.elems))
+		for _, e := range n.elems {
+			v, err := env.eval(e)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+
+	case *refNode:
+		return env.lookup(n)
+
+	case *unaryNode:
+		v, err := env.eval(n.x)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "-" {
+			switch x := v.(type) {
+			case int:
+				return -x, nil
+			case float64:
+				return -x, nil
+			}
+			return nil, fmt.Errorf("operator - requires a number, but got %s", valueTypeName(v))
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! requires a boolean, but got %s", valueTypeName(v))
+		}
+		return !b, nil
+
+	case *binaryNode:
+		return env.evalBinary(n)
+
+	case *callNode:
+		fn := exprFunctions[n.fn]
+		args := make([]interface{}, len(n.args))
+		for i, arg := range n.args {
+			v, err := env.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			if valueTypeKind(v) != fn.args[i] {
+				return nil, fmt.Errorf("argument %d of %s must be %s, but got %s", i+1, n.fn, exprType{kind: fn.args[i]}, valueTypeName(v))
+			}
+			args[i] = v
+		}
+		return fn.call(args), nil
+	}
+
+	return nil, fmt.Errorf("unsupported expression")
+}
+
+func (env *exprEnv) evalBinary(n *binaryNode) (interface{}, error) {
+	x, err := env.eval(n.x)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || are short-circuited.
+	if n.op == "&&" || n.op == "||" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s requires booleans, but got %s", n.op, valueTypeName(x))
+		}
+		if (n.op == "&&" && !b) || (n.op == "||" && b) {
+			return b, nil
+		}
+		y, err := env.eval(n.y)
+		if err != nil {
+			return nil, err
+		}
+		b, ok = y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s requires booleans, but got %s", n.op, valueTypeName(y))
+		}
+		return b, nil
+	}
+
+	y, err := env.eval(n.y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==", "!=":
+		cmp, err := compareValues(x, y)
+		if err != nil {
+			return nil, err
+		}
+		return (cmp == 0) == (n.op == "=="), nil
+
+	case "<", "<=", ">", ">=":
+		if _, ok := x.(bool); ok {
+			return nil, fmt.Errorf("operator %s is not defined on boolean", n.op)
+		}
+		cmp, err := compareValues(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+
+	case "in":
+		list, ok := y.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("right side of in must be a list, but got %s", valueTypeName(y))
+		}
+		env.budget -= len(list)
+		if env.budget < 0 {
+			return nil, fmt.Errorf("expression exceeded the evaluation cost limit of %d", maxExpressionCost)
+		}
+		for _, elem := range list {
+			cmp, err := compareValues(x, elem)
+			if err != nil {
+				return nil, err
+			}
+			if cmp == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %s", n.op)
+}
+
+func (env *exprEnv) lookup(n *refNode) (interface{}, error) {
+	key := n.key()
+
+	switch n.namespace {
+	case namespaceProp:
+		if v, ok := lookupProperty(env.prop, key); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("property %s not found", key)
+
+	case namespaceUser:
+		if env.user == nil {
+			return nil, fmt.Errorf("cannot read %s, no user property getter", key)
+		}
+		return env.user.GetUserProperty(key), nil
+
+	default:
+		now := env.now
+		if now.IsZero() {
+			now = timeNow()
+		}
+		switch key {
+		case "sys:::time:now":
+			return now.UTC().Format(time.RFC3339), nil
+		case "sys:::time:unix":
+			return int(now.Unix()), nil
+		case "sys:::resource":
+			return env.res.Resource, nil
+		case "sys:::action":
+			return env.res.Action, nil
+		}
+		return nil, fmt.Errorf("unknown system value %s", key)
+	}
+}
+
+// lookupProperty finds the value of the key in any of the typed property maps.
+func lookupProperty(prop Property, key string) (interface{}, bool) {
+	if v, ok := prop.String[key]; ok {
+		return v, true
+	}
+	if v, ok := prop.Integer[key]; ok {
+		return v, true
+	}
+	if v, ok := prop.Float[key]; ok {
+		return v, true
+	}
+	if v, ok := prop.Boolean[key]; ok {
+		return v, true
+	}
+	return nil, false
+}
+
+// compareValues returns -1, 0 or 1 when a is less than, equal to or greater than b.
+// Integers and floats can be compared with each other, any other mix of types is an error.
+func compareValues(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0, nil
+			}
+			return 1, nil
+		}
+	case int:
+		switch y := b.(type) {
+		case int:
+			return compareOrdered(x, y), nil
+		case float64:
+			return compareOrdered(float64(x), y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int:
+			return compareOrdered(x, float64(y)), nil
+		case float64:
+			return compareOrdered(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", valueTypeName(a), valueTypeName(b))
+}
+
+func compareOrdered[T int | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func valueTypeKind(v interface{}) typeKind {
+	switch v.(type) {
+	case string:
+		return typeString
+	case int:
+		return typeInt
+	case float64:
+		return typeFloat
+	case bool:
+		return typeBool
+	case []interface{}:
+		return typeList
+	default:
+		return typeAny
+	}
+}
+
+func valueTypeName(v interface{}) string {
+	if v == nil {
+		return "nothing"
+	}
+	return exprType{kind: valueTypeKind(v)}.String()
+}
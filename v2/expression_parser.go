@@ -0,0 +1,375 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenInt
+	tokenFloat
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are ordered so that the longest operator is matched first.
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "-", "(", ")", "[", "]", ",", "."}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		case unicode.IsDigit(r):
+			start := i
+			kind := tokenInt
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				if runes[i] == '.' {
+					if kind == tokenFloat {
+						return nil, fmt.Errorf("invalid expression: malformed number at column %d", start+1)
+					}
+					kind = tokenFloat
+				}
+				i++
+			}
+			tokens = append(tokens, token{kind: kind, text: string(runes[start:i]), pos: start})
+
+		case r == '"':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("invalid expression: unterminated string at column %d", start+1)
+			}
+			i++
+			s, err := strconv.Unquote(string(runes[start:i]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid expression: malformed string at column %d", start+1)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s, pos: start})
+
+		default:
+			op := matchOperator(string(runes[i:]))
+			if op == "" {
+				return nil, fmt.Errorf("invalid expression: unexpected character %q at column %d", r, i+1)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+func matchOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// exprParser is a recursive descent parser for expressions:
+//
+//	or      = and { "||" and }
+//	and     = compare { "&&" compare }
+//	compare = unary [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) unary ]
+//	unary   = ( "!" | "-" ) unary | primary
+//	primary = literal | list | reference | call | "(" or ")"
+type exprParser struct {
+	tokens []token
+	next   int
+	depth  int
+	nodes  int
+}
+
+func newExprParser(source string) (*exprParser, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	return &exprParser{tokens: tokens}, nil
+}
+
+func (p *exprParser) parse() (exprNode, error) {
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *exprParser) isOperator(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOperator && tok.text == op
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOperator(op) {
+		tok := p.peek()
+		return p.errorf(tok, "expected %q, but got %s", op, tok)
+	}
+	p.advance()
+	return nil
+}
+
+func (p *exprParser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("invalid expression: %s at column %d", fmt.Sprintf(format, args...), tok.pos+1)
+}
+
+// node counts the nodes of the tree, so that a compiled expression has a bounded size.
+func (p *exprParser) node(tok token) error {
+	p.nodes++
+	if p.nodes > maxExpressionNodes {
+		return p.errorf(tok, "more than %d terms", maxExpressionNodes)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return nil, p.errorf(p.peek(), "nested deeper than %d levels", maxExpressionDepth)
+	}
+
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("||") {
+		tok := p.advance()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.node(tok); err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: tok.pos, op: tok.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	x, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("&&") {
+		tok := p.advance()
+		y, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.node(tok); err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: tok.pos, op: tok.text, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	isCompare := tok.kind == tokenOperator && isContainsInList([]string{"==", "!=", "<", "<=", ">", ">="}, tok.text)
+	isIn := tok.kind == tokenIdent && tok.text == "in"
+	if !isCompare && !isIn {
+		return x, nil
+	}
+
+	p.advance()
+	y, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.node(tok); err != nil {
+		return nil, err
+	}
+	return &binaryNode{pos: tok.pos, op: tok.text, x: x, y: y}, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOperator("!") || p.isOperator("-") {
+		p.depth++
+		defer func() { p.depth-- }()
+		tok := p.advance()
+		if p.depth > maxExpressionDepth {
+			return nil, p.errorf(tok, "nested deeper than %d levels", maxExpressionDepth)
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.node(tok); err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos: tok.pos, op: tok.text, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.advance()
+	if err := p.node(tok); err != nil {
+		return nil, err
+	}
+
+	switch tok.kind {
+	case tokenString:
+		return &literalNode{pos: tok.pos, value: tok.text}, nil
+
+	case tokenInt:
+		v, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return nil, p.errorf(tok, "malformed integer %s", tok)
+		}
+		return &literalNode{pos: tok.pos, value: v}, nil
+
+	case tokenFloat:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "malformed float %s", tok)
+		}
+		return &literalNode{pos: tok.pos, value: v}, nil
+
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{pos: tok.pos, value: tok.text == "true"}, nil
+		case namespaceProp, namespaceUser, namespaceSys:
+			return p.parseReference(tok)
+		}
+		if p.isOperator("(") {
+			return p.parseCall(tok)
+		}
+		return nil, p.errorf(tok, "unknown name %s, references must start with prop, user or sys", tok)
+
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			return p.parseList(tok)
+		}
+	}
+
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+func (p *exprParser) parseReference(namespace token) (exprNode, error) {
+	ref := &refNode{pos: namespace.pos, namespace: namespace.text}
+	for p.isOperator(".") {
+		p.advance()
+		tok := p.advance()
+		if tok.kind != tokenIdent {
+			return nil, p.errorf(tok, "expected a name after \".\", but got %s", tok)
+		}
+		ref.path = append(ref.path, tok.text)
+	}
+	if len(ref.path) == 0 {
+		return nil, p.errorf(namespace, "%s must be followed by a property name", namespace.text)
+	}
+	return ref, nil
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	call := &callNode{pos: name.pos, fn: name.text}
+	p.advance()
+	for !p.isOperator(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.advance()
+	return call, nil
+}
+
+func (p *exprParser) parseList(open token) (exprNode, error) {
+	list := &listNode{pos: open.pos}
+	for !p.isOperator("]") {
+		if len(list.elems) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		elem, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		list.elems = append(list.elems, elem)
+	}
+	p.advance()
+	return list, nil
+}
@@ -0,0 +1,250 @@
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr bool
+	}{
+		{"comparison", `prop.doc.owner == user.id`, false},
+		{"boolean logic", `prop.doc.public || (prop.doc.owner == user.id && !prop.doc.locked)`, false},
+		{"list membership", `prop.doc.status in ["draft", "review"]`, false},
+		{"number list", `prop.doc.level in [1, 2.5, -3]`, false},
+		{"string function", `startsWith(lower(prop.doc.path), "/public/")`, false},
+		{"system value", `sys.time.unix > 1700000000 && sys.action == "act:::doc:read"`, false},
+		{"bare property is allowed", `prop.doc.public`, false},
+		{"empty", ``, true},
+		{"result is not boolean", `prop.doc.owner`, false},
+		{"result is a string literal", `"hello"`, true},
+		{"compare string with number", `user.id == 1`, true},
+		{"order booleans", `true < false`, true},
+		{"mixed list", `prop.doc.status in ["draft", 1]`, true},
+		{"in without list", `prop.doc.status in "draft"`, true},
+		{"not on string", `!user.id`, true},
+		{"minus on property", `-prop.doc.level < 0`, false},
+		{"minus on string", `-"abc" == 1`, true},
+		{"unknown function", `exec(user.id)`, true},
+		{"wrong argument count", `startsWith(user.id)`, true},
+		{"wrong argument type", `startsWith(user.id, 1)`, true},
+		{"unknown namespace", `env.home == "/"`, true},
+		{"unknown system value", `sys.hostname == "a"`, true},
+		{"reference without name", `prop == 1`, true},
+		{"unterminated string", `user.id == "abc`, true},
+		{"unexpected character", `user.id = "abc"`, true},
+		{"missing parenthesis", `(prop.doc.public`, true},
+		{"trailing token", `prop.doc.public prop.doc.public`, true},
+		{"too deep", strings.Repeat("(", 40) + "true" + strings.Repeat(")", 40), true},
+		{"too many terms", strings.Repeat("true&&", 520) + "true", true},
+		{"too long", `user.id == "` + strings.Repeat("a", maxExpressionLength) + `"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileExpression(tt.source)
+			if tt.wantErr && err == nil {
+				t.Error("want error, but got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestCompileExpression_ErrorHasColumn(t *testing.T) {
+	_, err := CompileExpression(`user.id == 1`)
+	if err == nil {
+		t.Fatal("want error, but got nil")
+	}
+	if !strings.Contains(err.Error(), "column 9") {
+		t.Errorf("want error at column 9, but got %v", err)
+	}
+}
+
+func TestExpressionEvaluate(t *testing.T) {
+	timeNow = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() { timeNow = time.Now }()
+
+	env := &exprEnv{
+		prop: Property{
+			String:  map[string]string{"prop:::doc:owner": "u1", "prop:::doc:status": "Draft"},
+			Integer: map[string]int{"prop:::doc:level": 3},
+			Float:   map[string]float64{"prop:::doc:amount": 99.5},
+			Boolean: map[string]bool{"prop:::doc:public": false},
+		},
+		user: &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}},
+		res:  Resource{Resource: "res:::doc", Action: "act:::doc:read"},
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		want    bool
+		wantErr bool
+	}{
+		{"string equal", `prop.doc.owner == user.id`, true, false},
+		{"string not equal", `prop.doc.owner != user.id`, false, false},
+		{"integer compare", `prop.doc.level >= 3`, true, false},
+		{"integer and float compare", `prop.doc.level < 3.5`, true, false},
+		{"float compare", `prop.doc.amount > 100`, false, false},
+		{"negative number", `prop.doc.level > -1`, true, false},
+		{"boolean", `!prop.doc.public`, true, false},
+		{"negative property", `-prop.doc.level == -3 && -prop.doc.amount < -99`, true, false},
+		{"negative string property", `-prop.doc.owner == 1`, false, true},
+		{"or short circuit", `prop.doc.owner == user.id || prop.doc.missing == 1`, true, false},
+		{"and short circuit", `prop.doc.public && prop.doc.missing == 1`, false, false},
+		{"in list", `lower(prop.doc.status) in ["draft", "review"]`, true, false},
+		{"not in list", `!(prop.doc.level in [1, 2])`, true, false},
+		{"string functions", `startsWith(user.id, "u") && endsWith(upper(user.id), "1") && contains(trim(" abc "), "b")`, true, false},
+		{"len", `len(prop.doc.status) == 5`, true, false},
+		{"system time", `sys.time.now == "2024-01-02T03:04:05Z" && sys.time.unix == 1704164645`, true, false},
+		{"system resource", `sys.resource == "res:::doc" && sys.action == "act:::doc:read"`, true, false},
+		{"missing property", `prop.doc.missing == 1`, false, true},
+		{"runtime type mismatch", `prop.doc.owner == 1.5`, false, true},
+		{"non boolean result", `prop.doc.owner`, false, true},
+		{"function with wrong runtime type", `startsWith(prop.doc.level, "1")`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := CompileExpression(tt.source)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+
+			got, err := expr.evaluate(env)
+
+			if tt.wantErr && err == nil {
+				t.Error("want error, but got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, but want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpressionEvaluate_CostLimit(t *testing.T) {
+	expr, err := CompileExpression(`prop.a.b in [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	env := &exprEnv{prop: Property{Integer: map[string]int{"prop:::a:b": 11}}, budget: 10}
+
+	_, err = env.eval(expr.root)
+
+	if err == nil {
+		t.Error("want cost limit error, but got nil")
+	}
+}
+
+func TestExpressionEvaluate_NoUserPropertyGetter(t *testing.T) {
+	expr, _ := CompileExpression(`user.id == "u1"`)
+
+	_, err := expr.evaluate(&exprEnv{})
+
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+}
+
+func TestExpression_JSON(t *testing.T) {
+	comparator := Comparator{}
+	err := json.Unmarshal([]byte(`{"Expression": "prop.doc.public == true"}`), &comparator)
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+
+	b, err := json.Marshal(comparator)
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+
+	if !strings.Contains(string(b), `"Expression":"prop.doc.public == true"`) {
+		t.Errorf("got %s", b)
+	}
+}
+
+func TestIsMatchedComparator_Expression(t *testing.T) {
+	expr, _ := CompileExpression(`prop.doc.owner == user.id`)
+	pv := New()
+	pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+
+	tests := []struct {
+		name  string
+		owner string
+		want  bool
+	}{
+		{"matched", "u1", true},
+		{"not matched", "u2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prop := Property{String: map[string]string{"prop:::doc:owner": tt.owner}}
+
+			got := pv.isMatchedComparator(Comparator{Expression: expr}, prop, "prop:::doc:owner")
+
+			if got != tt.want {
+				t.Errorf("got %v, but want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAccessAllowed_Expression(t *testing.T) {
+	tests := []struct {
+		name     string
+		owner    string
+		amount   float64
+		status   string
+		expected bool
+	}{
+		{"owner, expect ALLOWED", "u1", 5000, "Active", ALLOWED},
+		{"small amount, expect ALLOWED", "u2", 10, "active", ALLOWED},
+		{"neither owner nor small amount, expect DENIED", "u2", 5000, "active", DENIED},
+		{"archived, expect DENIED", "u1", 10, "Archived", DENIED},
+	}
+
+	b, _ := os.ReadFile("test_data/is_access_allowed/1policy_expression_conditions.json")
+	p, err := ParsePolicyArray(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = p
+			pv.UserPropertyGetter = NewDefaultUserPropertyGetter(`{"user_1": {"id": "u1"}}`)
+			pv.SetResource("res:::resource_1")
+			pv.SetAction("act:::resource_1:action_1")
+			pv.AddPropertyString("prop:::resource_1:owner", tt.owner)
+			pv.AddPropertyString("prop:::resource_1:status", tt.status)
+			pv.AddPropertyFloat("prop:::resource_1:amount", tt.amount)
+
+			// Act
+			result, err := pv.IsAccessAllowed()
+
+			// Assert
+			if err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("got %v, but want %v", result, tt.expected)
+			}
+		})
+	}
+}
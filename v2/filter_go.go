@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"fmt"
+	"go/ast"
+	gotoken "go/token"
+	"strconv"
+)
+
+// ToGoExpr renders the filter as a Go expression, e.g. to generate code or to build a query with a library
+// that takes Go expressions. The expression can be printed with go/format.
+//
+// Field returns the expression of a resource property key, e.g. "prop:::doc:owner" to doc.OwnerID.
+// An error is returned for a property that has no field.
+// Strings are matched with strings.HasPrefix, strings.HasSuffix and strings.Contains, the same as the evaluator.
+func (f *Filter) ToGoExpr(field func(key string) (ast.Expr, error)) (ast.Expr, error) {
+	if field == nil {
+		return nil, fmt.Errorf("no field mapping for the filter")
+	}
+	return goFilterExpr(f, field)
+}
+
+func goFilterExpr(f *Filter, field func(key string) (ast.Expr, error)) (ast.Expr, error) {
+	switch f.Op {
+	case FilterTrue:
+		return ast.NewIdent("true"), nil
+
+	case FilterFalse:
+		return ast.NewIdent("false"), nil
+
+	case FilterAnd, FilterOr:
+		op := gotoken.LAND
+		if f.Op == FilterOr {
+			op = gotoken.LOR
+		}
+		var expr ast.Expr
+		for _, operand := range f.Operands {
+			x, err := goFilterExpr(operand, field)
+			if err != nil {
+				return nil, err
+			}
+			if expr == nil {
+				expr = goParen(x, op)
+				continue
+			}
+			expr = &ast.BinaryExpr{X: expr, Op: op, Y: goParen(x, op)}
+		}
+		if expr == nil {
+			return ast.NewIdent(strconv.FormatBool(f.Op == FilterAnd)), nil
+		}
+		return expr, nil
+
+	case FilterNot:
+		x, err := goFilterExpr(f.Operands[0], field)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := x.(*ast.BinaryExpr); ok {
+			x = &ast.ParenExpr{X: x}
+		}
+		return &ast.UnaryExpr{Op: gotoken.NOT, X: x}, nil
+	}
+
+	x, err := field(f.Property)
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.Op {
+	case FilterEqual, FilterNotEqual, FilterLess, FilterLessOrEqual, FilterGreater, FilterGreaterOrEqual:
+		y, err := goLiteral(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.BinaryExpr{X: x, Op: goComparisonTokens[f.Op], Y: y}, nil
+
+	case FilterIn:
+		var expr ast.Expr
+		for _, v := range f.Values {
+			y, err := goLiteral(v)
+			if err != nil {
+				return nil, err
+			}
+			equal := &ast.BinaryExpr{X: x, Op: gotoken.EQL, Y: y}
+			if expr == nil {
+				expr = equal
+				continue
+			}
+			expr = &ast.BinaryExpr{X: expr, Op: gotoken.LOR, Y: equal}
+		}
+		if expr == nil {
+			return ast.NewIdent("false"), nil
+		}
+		return expr, nil
+
+	case FilterStartsWith, FilterEndsWith, FilterContains:
+		s, ok := f.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a string, but got %T", f.Op, f.Value)
+		}
+		return &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent("strings"), Sel: ast.NewIdent(goStringFunctions[f.Op])},
+			Args: []ast.Expr{x, &ast.BasicLit{Kind: gotoken.STRING, Value: strconv.Quote(s)}},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported filter operator %q", f.Op)
+}
+
+var goComparisonTokens = map[FilterOp]gotoken.Token{
+	FilterEqual:          gotoken.EQL,
+	FilterNotEqual:       gotoken.NEQ,
+	FilterLess:           gotoken.LSS,
+	FilterLessOrEqual:    gotoken.LEQ,
+	FilterGreater:        gotoken.GTR,
+	FilterGreaterOrEqual: gotoken.GEQ,
+}
+
+var goStringFunctions = map[FilterOp]string{
+	FilterStartsWith: "HasPrefix",
+	FilterEndsWith:   "HasSuffix",
+	FilterContains:   "Contains",
+}
+
+// goParen puts the operand of && or || in parentheses when it is the other one of them.
+func goParen(x ast.Expr, op gotoken.Token) ast.Expr {
+	if b, ok := x.(*ast.BinaryExpr); ok && (b.Op == gotoken.LAND || b.Op == gotoken.LOR) && b.Op != op {
+		return &ast.ParenExpr{X: x}
+	}
+	return x
+}
+
+func goLiteral(v interface{}) (ast.Expr, error) {
+	switch v := v.(type) {
+	case string:
+		return &ast.BasicLit{Kind: gotoken.STRING, Value: strconv.Quote(v)}, nil
+	case bool:
+		return ast.NewIdent(strconv.FormatBool(v)), nil
+	case int:
+		if v < 0 {
+			return &ast.UnaryExpr{Op: gotoken.SUB, X: &ast.BasicLit{Kind: gotoken.INT, Value: strconv.Itoa(-v)}}, nil
+		}
+		return &ast.BasicLit{Kind: gotoken.INT, Value: strconv.Itoa(v)}, nil
+	case float64:
+		if v < 0 {
+			return &ast.UnaryExpr{Op: gotoken.SUB, X: &ast.BasicLit{Kind: gotoken.FLOAT, Value: strconv.FormatFloat(-v, 'g', -1, 64)}}, nil
+		}
+		return &ast.BasicLit{Kind: gotoken.FLOAT, Value: strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	}
+	return nil, fmt.Errorf("unsupported filter value %T", v)
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	gotoken "go/token"
+	"strings"
+	"testing"
+)
+
+func testField(key string) (ast.Expr, error) {
+	if !strings.HasPrefix(key, "prop:::doc:") {
+		return nil, errors.New("unknown property " + key)
+	}
+	name := strings.TrimPrefix(key, "prop:::doc:")
+	return &ast.SelectorExpr{X: ast.NewIdent("doc"), Sel: ast.NewIdent(strings.ToUpper(name[:1]) + name[1:])}, nil
+}
+
+func TestFilterToGoExpr(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    *Filter
+		want      string
+		wantError bool
+	}{
+		{
+			name:   "true",
+			filter: newConstantFilter(true),
+			want:   "true",
+		},
+		{
+			name: "and, or, not",
+			filter: newAndFilter(
+				newOrFilter(
+					&Filter{Op: FilterEqual, Property: "prop:::doc:owner", Value: "u1"},
+					&Filter{Op: FilterEqual, Property: "prop:::doc:public", Value: true},
+				),
+				newNotFilter(&Filter{Op: FilterIn, Property: "prop:::doc:status", Values: []interface{}{"archived", "deleted"}}),
+				&Filter{Op: FilterGreaterOrEqual, Property: "prop:::doc:level", Value: -2},
+			),
+			want: `(doc.Owner == "u1" || doc.Public == true) && !(doc.Status == "archived" || doc.Status == "deleted") && doc.Level >= -2`,
+		},
+		{
+			name: "string matches",
+			filter: newOrFilter(
+				&Filter{Op: FilterStartsWith, Property: "prop:::doc:path", Value: `/a"b/`},
+				&Filter{Op: FilterEndsWith, Property: "prop:::doc:path", Value: ".md"},
+				newNotFilter(&Filter{Op: FilterContains, Property: "prop:::doc:path", Value: "tmp"}),
+			),
+			want: `strings.HasPrefix(doc.Path, "/a\"b/") || strings.HasSuffix(doc.Path, ".md") || !strings.Contains(doc.Path, "tmp")`,
+		},
+		{
+			name:   "empty in",
+			filter: &Filter{Op: FilterIn, Property: "prop:::doc:status"},
+			want:   "false",
+		},
+		{
+			name:   "float",
+			filter: &Filter{Op: FilterLess, Property: "prop:::doc:amount", Value: 10.5},
+			want:   "doc.Amount < 10.5",
+		},
+		{
+			name:      "unknown field",
+			filter:    &Filter{Op: FilterEqual, Property: "prop:::user:owner", Value: "u1"},
+			wantError: true,
+		},
+		{
+			name:      "string match with a number",
+			filter:    &Filter{Op: FilterContains, Property: "prop:::doc:path", Value: 1},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			expr, err := tt.filter.ToGoExpr(testField)
+
+			// Assert
+			if tt.wantError {
+				if err == nil {
+					t.Error("want error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			var buf bytes.Buffer
+			if err := format.Node(&buf, gotoken.NewFileSet(), expr); err != nil {
+				t.Fatalf("format: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %s, but want %s", got, tt.want)
+			}
+			if _, err := parser.ParseExpr(buf.String()); err != nil {
+				t.Errorf("want a valid Go expression, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestFilterToGoExpr_NoFieldMapping(t *testing.T) {
+	// Act
+	_, err := newConstantFilter(true).ToGoExpr(nil)
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+}
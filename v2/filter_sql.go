@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SQLOptions configures Filter.ToSQL.
+type SQLOptions struct {
+	// Column returns the column for a resource property key, e.g. "prop:::doc:owner" to "documents.owner_id".
+	// The column is written to the query as is, so it must not come from user input.
+	// An error is returned for a property that has no column.
+	Column func(key string) (string, error)
+
+	// Placeholder returns the placeholder of the n-th argument, starting at 1.
+	// The default is "?", use PostgresPlaceholder for "$1", "$2", ...
+	Placeholder func(n int) string
+
+	// Dialect is the database of the query. It is required for FilterStartsWith, FilterEndsWith and FilterContains,
+	// since LIKE is case-insensitive in SQLite and in the default collations of MySQL, but the evaluator is not.
+	Dialect SQLDialect
+}
+
+// SQLDialect selects the case-sensitive string match of a database.
+type SQLDialect string
+
+const (
+	// SQLDialectPostgres matches with LIKE, which is case-sensitive in PostgreSQL.
+	SQLDialectPostgres SQLDialect = "postgres"
+	// SQLDialectSQLite matches with GLOB, since LIKE is case-insensitive in SQLite.
+	SQLDialectSQLite SQLDialect = "sqlite"
+	// SQLDialectMySQL matches the binary string of the column with LIKE, since the default collations are case-insensitive.
+	SQLDialectMySQL SQLDialect = "mysql"
+)
+
+// PostgresPlaceholder returns numbered placeholders, e.g. "$1".
+func PostgresPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// ToSQL renders the filter as the condition of a WHERE clause with placeholders for the values.
+// The values are returned in the order of the placeholders.
+func (f *Filter) ToSQL(opts SQLOptions) (string, []interface{}, error) {
+	if opts.Column == nil {
+		return "", nil, fmt.Errorf("no column mapping for the filter")
+	}
+	if opts.Placeholder == nil {
+		opts.Placeholder = func(int) string { return "?" }
+	}
+
+	w := &sqlWriter{opts: opts}
+	if err := w.write(f); err != nil {
+		return "", nil, err
+	}
+	return w.sb.String(), w.args, nil
+}
+
+type sqlWriter struct {
+	opts SQLOptions
+	sb   strings.Builder
+	args []interface{}
+}
+
+func (w *sqlWriter) arg(v interface{}) string {
+	w.args = append(w.args, v)
+	return w.opts.Placeholder(len(w.args))
+}
+
+func (w *sqlWriter) write(f *Filter) error {
+	switch f.Op {
+	case FilterTrue:
+		w.sb.WriteString("1 = 1")
+		return nil
+
+	case FilterFalse:
+		w.sb.WriteString("1 = 0")
+		return nil
+
+	case FilterAnd, FilterOr:
+		keyword := " AND "
+		if f.Op == FilterOr {
+			keyword = " OR "
+		}
+		w.sb.WriteString("(")
+		for i, operand := range f.Operands {
+			if i > 0 {
+				w.sb.WriteString(keyword)
+			}
+			if err := w.write(operand); err != nil {
+				return err
+			}
+		}
+		w.sb.WriteString(")")
+		return nil
+
+	case FilterNot:
+		w.sb.WriteString("NOT (")
+		if err := w.write(f.Operands[0]); err != nil {
+			return err
+		}
+		w.sb.WriteString(")")
+		return nil
+	}
+
+	column, err := w.opts.Column(f.Property)
+	if err != nil {
+		return err
+	}
+
+	switch f.Op {
+	case FilterEqual:
+		fmt.Fprintf(&w.sb, "%s = %s", column, w.arg(f.Value))
+	case FilterNotEqual:
+		fmt.Fprintf(&w.sb, "%s <> %s", column, w.arg(f.Value))
+	case FilterLess, FilterLessOrEqual, FilterGreater, FilterGreaterOrEqual:
+		fmt.Fprintf(&w.sb, "%s %s %s", column, f.Op, w.arg(f.Value))
+	case FilterIn:
+		if len(f.Values) == 0 {
+			w.sb.WriteString("1 = 0")
+			return nil
+		}
+		placeholders := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			placeholders[i] = w.arg(v)
+		}
+		fmt.Fprintf(&w.sb, "%s IN (%s)", column, strings.Join(placeholders, ", "))
+	case FilterStartsWith, FilterEndsWith, FilterContains:
+		s, ok := f.Value.(string)
+		if !ok {
+			return fmt.Errorf("%s requires a string, but got %T", f.Op, f.Value)
+		}
+		return w.writeStringMatch(f.Op, column, s)
+	default:
+		return fmt.Errorf("unsupported filter operator %q", f.Op)
+	}
+	return nil
+}
+
+// writeStringMatch writes a case-sensitive match of the column, the same as strings.HasPrefix,
+// strings.HasSuffix and strings.Contains in the evaluator.
+func (w *sqlWriter) writeStringMatch(op FilterOp, column, s string) error {
+	switch w.opts.Dialect {
+	case SQLDialectPostgres:
+		fmt.Fprintf(&w.sb, "%s LIKE %s ESCAPE '%c'", column, w.arg(wildcardPattern(op, escapeLike(s), "%")), likeEscape)
+	case SQLDialectMySQL:
+		fmt.Fprintf(&w.sb, "CAST(%s AS BINARY) LIKE %s ESCAPE '%c'", column, w.arg(wildcardPattern(op, escapeLike(s), "%")), likeEscape)
+	case SQLDialectSQLite:
+		fmt.Fprintf(&w.sb, "%s GLOB %s", column, w.arg(wildcardPattern(op, escapeGlob(s), "*")))
+	case "":
+		return fmt.Errorf("%s requires an SQL dialect, since LIKE is case-insensitive in some databases", op)
+	default:
+		return fmt.Errorf("unsupported SQL dialect %q", w.opts.Dialect)
+	}
+	return nil
+}
+
+// wildcardPattern adds the wildcard to the escaped string for the operator.
+func wildcardPattern(op FilterOp, escaped, wildcard string) string {
+	switch op {
+	case FilterStartsWith:
+		return escaped + wildcard
+	case FilterEndsWith:
+		return wildcard + escaped
+	default:
+		return wildcard + escaped + wildcard
+	}
+}
+
+// likeEscape escapes the wildcards of a LIKE pattern. It is not a backslash, since MySQL reads a backslash
+// in a string literal as an escape by default, so ESCAPE '\' is a syntax error there.
+const likeEscape = '!'
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// globEscaper puts the wildcards of a GLOB pattern in brackets, GLOB has no escape character.
+var globEscaper = strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
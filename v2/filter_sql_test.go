@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func testColumn(key string) (string, error) {
+	if !strings.HasPrefix(key, "prop:::doc:") {
+		return "", errors.New("unknown property " + key)
+	}
+	return "d." + strings.TrimPrefix(key, "prop:::doc:"), nil
+}
+
+func TestFilterToSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    *Filter
+		opts      SQLOptions
+		wantSQL   string
+		wantArgs  []interface{}
+		wantError bool
+	}{
+		{
+			name:     "true",
+			filter:   &Filter{Op: FilterTrue},
+			opts:     SQLOptions{Column: testColumn},
+			wantSQL:  "1 = 1",
+			wantArgs: nil,
+		},
+		{
+			name:     "false",
+			filter:   &Filter{Op: FilterFalse},
+			opts:     SQLOptions{Column: testColumn},
+			wantSQL:  "1 = 0",
+			wantArgs: nil,
+		},
+		{
+			name: "and, or, not with question mark placeholders",
+			filter: newAndFilter(
+				newOrFilter(
+					&Filter{Op: FilterEqual, Property: "prop:::doc:owner", Value: "u1"},
+					&Filter{Op: FilterEqual, Property: "prop:::doc:public", Value: true},
+				),
+				newNotFilter(&Filter{Op: FilterIn, Property: "prop:::doc:status", Values: []interface{}{"archived", "deleted"}}),
+				&Filter{Op: FilterGreaterOrEqual, Property: "prop:::doc:level", Value: 2},
+			),
+			opts:     SQLOptions{Column: testColumn},
+			wantSQL:  "((d.owner = ? OR d.public = ?) AND NOT (d.status IN (?, ?)) AND d.level >= ?)",
+			wantArgs: []interface{}{"u1", true, "archived", "deleted", 2},
+		},
+		{
+			name: "postgres placeholders",
+			filter: newOrFilter(
+				&Filter{Op: FilterNotEqual, Property: "prop:::doc:owner", Value: "u1"},
+				&Filter{Op: FilterLess, Property: "prop:::doc:amount", Value: 10.5},
+			),
+			opts:     SQLOptions{Column: testColumn, Placeholder: PostgresPlaceholder},
+			wantSQL:  "(d.owner <> $1 OR d.amount < $2)",
+			wantArgs: []interface{}{"u1", 10.5},
+		},
+		{
+			name: "like with escaped pattern",
+			filter: newAndFilter(
+				&Filter{Op: FilterStartsWith, Property: "prop:::doc:path", Value: "/a_b%/"},
+				&Filter{Op: FilterEndsWith, Property: "prop:::doc:path", Value: ".md"},
+				&Filter{Op: FilterContains, Property: "prop:::doc:path", Value: `x\y!`},
+			),
+			opts:     SQLOptions{Column: testColumn, Dialect: SQLDialectPostgres},
+			wantSQL:  `(d.path LIKE ? ESCAPE '!' AND d.path LIKE ? ESCAPE '!' AND d.path LIKE ? ESCAPE '!')`,
+			wantArgs: []interface{}{`/a!_b!%/%`, "%.md", `%x\y!!%`},
+		},
+		{
+			name:     "binary like in mysql",
+			filter:   &Filter{Op: FilterStartsWith, Property: "prop:::doc:path", Value: "/a_b/"},
+			opts:     SQLOptions{Column: testColumn, Dialect: SQLDialectMySQL},
+			wantSQL:  `CAST(d.path AS BINARY) LIKE ? ESCAPE '!'`,
+			wantArgs: []interface{}{`/a!_b/%`},
+		},
+		{
+			name: "glob with escaped pattern in sqlite",
+			filter: newAndFilter(
+				&Filter{Op: FilterStartsWith, Property: "prop:::doc:path", Value: "/a*b?/"},
+				&Filter{Op: FilterEndsWith, Property: "prop:::doc:path", Value: "[1].md"},
+				&Filter{Op: FilterContains, Property: "prop:::doc:path", Value: "a_b%"},
+			),
+			opts:     SQLOptions{Column: testColumn, Dialect: SQLDialectSQLite},
+			wantSQL:  `(d.path GLOB ? AND d.path GLOB ? AND d.path GLOB ?)`,
+			wantArgs: []interface{}{`/a[*]b[?]/*`, "*[[]1].md", "*a_b%*"},
+		},
+		{
+			name:      "string match without dialect",
+			filter:    &Filter{Op: FilterContains, Property: "prop:::doc:path", Value: "a"},
+			opts:      SQLOptions{Column: testColumn},
+			wantError: true,
+		},
+		{
+			name:     "empty in",
+			filter:   &Filter{Op: FilterIn, Property: "prop:::doc:status"},
+			opts:     SQLOptions{Column: testColumn},
+			wantSQL:  "1 = 0",
+			wantArgs: nil,
+		},
+		{
+			name:      "unknown column",
+			filter:    &Filter{Op: FilterEqual, Property: "prop:::user:owner", Value: "u1"},
+			opts:      SQLOptions{Column: testColumn},
+			wantError: true,
+		},
+		{
+			name:      "no column mapping",
+			filter:    &Filter{Op: FilterTrue},
+			wantError: true,
+		},
+		{
+			name:      "like with a number",
+			filter:    &Filter{Op: FilterStartsWith, Property: "prop:::doc:path", Value: 1},
+			opts:      SQLOptions{Column: testColumn, Dialect: SQLDialectPostgres},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			sql, args, err := tt.filter.ToSQL(tt.opts)
+
+			// Assert
+			if tt.wantError {
+				if err == nil {
+					t.Error("want error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("got %s, but want %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got %v, but want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestFilterToSQL_SameAsEvaluator(t *testing.T) {
+	// Arrange
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "filter.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	paths := []string{"/public/a.md", "/PUBLIC/secret.md", "/public/B.MD", "/x/Public/y.md", "/a*b/c.md", "/a_b/c.md"}
+	if _, err := db.Exec(`CREATE TABLE d (path TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if _, err := db.Exec(`INSERT INTO d (path) VALUES (?)`, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		comparator Comparator
+	}{
+		{"string prefix", Comparator{StringPrefix: ptr("/public/")}},
+		{"starts with", Comparator{Expression: mustCompileExpression(t, `startsWith(prop.doc.path, "/a*")`)}},
+		{"ends with", Comparator{Expression: mustCompileExpression(t, `endsWith(prop.doc.path, ".md")`)}},
+		{"contains", Comparator{Expression: mustCompileExpression(t, `contains(prop.doc.path, "/Public/") || contains(prop.doc.path, "_")`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := New()
+			pv.Policies = []Policy{{Version: PolicyVersion2, Statements: []Statement{{
+				Effect:     statementEffectAllow,
+				Resource:   "res:::doc",
+				Actions:    []string{"act:::doc:read"},
+				Conditions: &Condition{MustHaveAll: map[string]Comparator{"prop:::doc:path": tt.comparator}},
+			}}}}
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+			var want []string
+			for _, path := range paths {
+				res := Resource{Resource: "res:::doc", Action: "act:::doc:read", Properties: Property{String: map[string]string{"prop:::doc:path": path}}}
+				if allowed, _ := pv.Authorize(context.Background(), nil, res); allowed {
+					want = append(want, path)
+				}
+			}
+
+			// Act
+			filter, err := pv.PartialEvaluate()
+			if err != nil {
+				t.Fatalf("partial evaluation: %v", err)
+			}
+			where, args, err := filter.ToSQL(SQLOptions{Column: testColumn, Dialect: SQLDialectSQLite})
+			if err != nil {
+				t.Fatalf("to SQL: %v", err)
+			}
+			got, err := queryPaths(db, `SELECT d.path FROM d WHERE `+where+` ORDER BY rowid`, args)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("query %s: %v", where, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: want %v, but got %v", where, want, got)
+			}
+		})
+	}
+}
+
+func queryPaths(db *sql.DB, query string, args []interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
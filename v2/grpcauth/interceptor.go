@@ -0,0 +1,160 @@
+// Package grpcauth checks the calls of a gRPC server with the policies.
+//
+//	auth := grpcauth.New(validator, grpcauth.MetadataUser(map[string]string{"user:::id": "x-user-id"}))
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(auth.Unary()),
+//		grpc.StreamInterceptor(auth.Stream()),
+//	)
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/golfz/policy/v2"
+)
+
+// ErrUnauthenticated is returned by a user extractor when the call has no valid user,
+// the call is rejected with codes.Unauthenticated.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Interceptor allows a call to the handler only if the Authorizer allows it.
+type Interceptor struct {
+	Authorizer policy.Authorizer
+
+	// User returns the user of the call, e.g. from the incoming metadata of the context.
+	User func(ctx context.Context) (policy.UserPropertyGetter, error)
+	// Resource returns the resource of the full method name, the default is MethodResource.
+	Resource func(fullMethod string) (string, error)
+	// Action returns the action of the full method name, the default is MethodAction.
+	Action func(fullMethod string) (string, error)
+	// Properties returns the resource properties of the call, none by default.
+	// The request is nil for a stream, since the messages are received by the handler.
+	Properties func(ctx context.Context, fullMethod string, req interface{}) (policy.Property, error)
+
+	// Explain adds the explanation of the decision to the message of a PermissionDenied status,
+	// if the Authorizer is also a policy.Explainer. Only enable it for clients that may see the policies.
+	Explain bool
+}
+
+// New creates an interceptor with the default resource and action.
+func New(authorizer policy.Authorizer, user func(ctx context.Context) (policy.UserPropertyGetter, error)) *Interceptor {
+	return &Interceptor{
+		Authorizer: authorizer,
+		User:       user,
+		Resource:   MethodResource,
+		Action:     MethodAction,
+	}
+}
+
+// Unary returns the unary server interceptor.
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := i.authorize(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the stream server interceptor, the stream is checked once when it is opened.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.authorize(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorize returns the status error of a denied call.
+func (i *Interceptor) authorize(ctx context.Context, fullMethod string, req interface{}) error {
+	user, res, err := i.extract(ctx, fullMethod, req)
+	if err != nil {
+		return toStatusError(err)
+	}
+
+	if explainer, ok := i.Authorizer.(policy.Explainer); ok && i.Explain {
+		explanation, err := explainer.Explain(ctx, user, res)
+		if err != nil {
+			return toStatusError(err)
+		}
+		if !explanation.Allowed {
+			return status.Error(codes.PermissionDenied, "access denied: "+explanation.String())
+		}
+		return nil
+	}
+
+	allowed, err := i.Authorizer.Authorize(ctx, user, res)
+	if err != nil {
+		return toStatusError(err)
+	}
+	if !allowed {
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+	return nil
+}
+
+func (i *Interceptor) extract(ctx context.Context, fullMethod string, req interface{}) (policy.UserPropertyGetter, policy.Resource, error) {
+	var user policy.UserPropertyGetter
+	var res policy.Resource
+	var err error
+	if i.User != nil {
+		if user, err = i.User(ctx); err != nil {
+			return nil, res, err
+		}
+	}
+
+	resource, action := MethodResource, MethodAction
+	if i.Resource != nil {
+		resource = i.Resource
+	}
+	if i.Action != nil {
+		action = i.Action
+	}
+	if res.Resource, err = resource(fullMethod); err != nil {
+		return nil, res, err
+	}
+	if res.Action, err = action(fullMethod); err != nil {
+		return nil, res, err
+	}
+	if i.Properties != nil {
+		if res.Properties, err = i.Properties(ctx, fullMethod, req); err != nil {
+			return nil, res, err
+		}
+	}
+	return user, res, nil
+}
+
+// toStatusError keeps a status error, and hides the message of other errors from the client.
+func toStatusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, ErrUnauthenticated) {
+		return status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return status.Error(codes.Internal, "cannot check access")
+}
+
+// MethodResource maps a full method name to the service, e.g. "/doc.v1.Documents/Get" to "res:::doc.v1.Documents".
+func MethodResource(fullMethod string) (string, error) {
+	service, _ := splitMethod(fullMethod)
+	return "res:::" + service, nil
+}
+
+// MethodAction maps a full method name to the method, e.g. "/doc.v1.Documents/Get" to "act:::doc.v1.Documents:Get".
+func MethodAction(fullMethod string) (string, error) {
+	service, method := splitMethod(fullMethod)
+	return "act:::" + service + ":" + method, nil
+}
+
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, _ = strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
@@ -0,0 +1,277 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/golfz/policy/v2"
+	"github.com/golfz/policy/v2/prommetrics"
+)
+
+const (
+	healthResource = "res:::grpc.health.v1.Health"
+	checkAction    = "act:::grpc.health.v1.Health:Check"
+	watchAction    = "act:::grpc.health.v1.Health:Watch"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testValidator() policy.Authorizer {
+	pv := policy.New()
+	pv.Policies = testPolicies()
+	return pv
+}
+
+func testPolicies() []policy.Policy {
+	return []policy.Policy{
+		{
+			PolicyID: "operators",
+			Statements: []policy.Statement{
+				{
+					Effect:   "Allow",
+					Resource: healthResource,
+					Actions:  []string{checkAction, watchAction},
+					Conditions: &policy.Condition{
+						MustHaveAll: map[string]policy.Comparator{
+							"prop:::health:service": {StringEqual: ptr("")},
+							"prop:::health:role":    {UserPropEqual: ptr("user:::role")},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// roleProperties makes the user role a resource property, so that only "operator" can call the service.
+func roleProperties(ctx context.Context, fullMethod string, req interface{}) (policy.Property, error) {
+	prop := policy.Property{String: map[string]string{"prop:::health:role": "operator"}}
+	if r, ok := req.(*healthpb.HealthCheckRequest); ok {
+		prop.String["prop:::health:service"] = r.GetService()
+	} else {
+		prop.String["prop:::health:service"] = ""
+	}
+	return prop, nil
+}
+
+func newTestClient(t *testing.T, i *Interceptor) healthpb.HealthClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(i.Unary()), grpc.StreamInterceptor(i.Stream()))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func withRole(role string) context.Context {
+	if role == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "x-user-role", role)
+}
+
+func TestInterceptor_Unary(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		explain  bool
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{"allowed", "operator", false, codes.OK, ""},
+		{"denied", "guest", false, codes.PermissionDenied, "access denied"},
+		{"denied with explanation", "guest", true, codes.PermissionDenied, "access denied: denied: no statement matched"},
+		{"no user", "", false, codes.Unauthenticated, "unauthenticated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			i := New(testValidator(), MetadataUser(map[string]string{"user:::role": "x-user-role"}))
+			i.Properties = roleProperties
+			i.Explain = tt.explain
+			client := newTestClient(t, i)
+
+			// Act
+			_, err := client.Check(withRole(tt.role), &healthpb.HealthCheckRequest{})
+
+			// Assert
+			st := status.Convert(err)
+			if st.Code() != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, st.Code())
+			}
+			if st.Message() != tt.wantMsg {
+				t.Errorf("want %q, but got %q", tt.wantMsg, st.Message())
+			}
+		})
+	}
+}
+
+func TestInterceptor_ExplainMetrics(t *testing.T) {
+	// Arrange
+	metrics := prommetrics.New()
+	pv := policy.New()
+	pv.Policies = testPolicies()
+	pv.Metrics = metrics
+	i := New(pv, MetadataUser(map[string]string{"user:::role": "x-user-role"}))
+	i.Properties = roleProperties
+	i.Explain = true
+	client := newTestClient(t, i)
+
+	// Act
+	_, _ = client.Check(withRole("operator"), &healthpb.HealthCheckRequest{})
+	_, _ = client.Check(withRole("guest"), &healthpb.HealthCheckRequest{})
+
+	// Assert
+	var buf strings.Builder
+	_, _ = metrics.WriteTo(&buf)
+	for _, want := range []string{
+		`policy_decisions_total{resource="res:::grpc.health.v1.Health",action="act:::grpc.health.v1.Health:Check",decision="allowed"} 1`,
+		`policy_decisions_total{resource="res:::grpc.health.v1.Health",action="act:::grpc.health.v1.Health:Check",decision="denied"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %s in\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestInterceptor_UnaryRequestProperties(t *testing.T) {
+	// Arrange
+	i := New(testValidator(), MetadataUser(map[string]string{"user:::role": "x-user-role"}))
+	i.Properties = roleProperties
+	client := newTestClient(t, i)
+
+	// Act
+	_, err := client.Check(withRole("operator"), &healthpb.HealthCheckRequest{Service: "other"})
+
+	// Assert
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("want %v, but got %v", codes.PermissionDenied, err)
+	}
+}
+
+func TestInterceptor_Stream(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		wantCode codes.Code
+	}{
+		{"allowed", "operator", codes.OK},
+		{"denied", "guest", codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			i := New(testValidator(), MetadataUser(map[string]string{"user:::role": "x-user-role"}))
+			i.Properties = roleProperties
+			client := newTestClient(t, i)
+			ctx, cancel := context.WithCancel(withRole(tt.role))
+			defer cancel()
+
+			// Act
+			stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+
+			// Assert
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestInterceptor_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer policy.Authorizer
+		setup      func(i *Interceptor)
+		wantCode   codes.Code
+	}{
+		{
+			name: "authorizer error",
+			authorizer: policy.AuthorizerFunc(func(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+				return false, errors.New("secret detail")
+			}),
+			wantCode: codes.Internal,
+		},
+		{
+			name: "status error of the authorizer",
+			authorizer: policy.AuthorizerFunc(func(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+				return false, status.Error(codes.Unavailable, "unavailable")
+			}),
+			wantCode: codes.Unavailable,
+		},
+		{
+			name:       "properties error",
+			authorizer: testValidator(),
+			setup: func(i *Interceptor) {
+				i.Properties = func(ctx context.Context, fullMethod string, req interface{}) (policy.Property, error) {
+					return policy.Property{}, errors.New("secret detail")
+				}
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			i := New(tt.authorizer, nil)
+			if tt.setup != nil {
+				tt.setup(i)
+			}
+			client := newTestClient(t, i)
+
+			// Act
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+			// Assert
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("want %v, but got %v", tt.wantCode, err)
+			}
+			if strings.Contains(err.Error(), "secret detail") {
+				t.Errorf("want the error hidden, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestMethodResourceAndAction(t *testing.T) {
+	// Act
+	resource, _ := MethodResource("/doc.v1.Documents/Get")
+	action, _ := MethodAction("/doc.v1.Documents/Get")
+
+	// Assert
+	if resource != "res:::doc.v1.Documents" {
+		t.Errorf("want res:::doc.v1.Documents, but got %s", resource)
+	}
+	if action != "act:::doc.v1.Documents:Get" {
+		t.Errorf("want act:::doc.v1.Documents:Get, but got %s", action)
+	}
+}
@@ -0,0 +1,34 @@
+package grpcauth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/golfz/policy/v2"
+)
+
+// MetadataUser returns the user properties from the incoming metadata, e.g. {"user:::id": "x-user-id"}.
+// It is meant for services behind a gateway that authenticates the user and sets the metadata.
+// The call is unauthenticated if none of the keys is set.
+func MetadataUser(keys map[string]string) func(ctx context.Context) (policy.UserPropertyGetter, error) {
+	return func(ctx context.Context) (policy.UserPropertyGetter, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		user := make(metadataUser, len(keys))
+		for key, name := range keys {
+			if values := md.Get(name); len(values) > 0 && values[0] != "" {
+				user[key] = values[0]
+			}
+		}
+		if len(user) == 0 {
+			return nil, ErrUnauthenticated
+		}
+		return user, nil
+	}
+}
+
+type metadataUser map[string]string
+
+func (u metadataUser) GetUserProperty(key string) string {
+	return u[key]
+}
@@ -0,0 +1,150 @@
+// Package httpauth checks the requests of a net/http server with the policies.
+//
+//	mw := httpauth.New(validator, httpauth.BearerClaimsUser(verifyJWT))
+//	mw.Resource = httpauth.Static("res:::document")
+//	mux.Handle("GET /documents/{id}", mw.Handler(documentHandler))
+package httpauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golfz/policy/v2"
+)
+
+var (
+	// ErrUnauthenticated is returned by a user extractor when the request has no valid user, it is denied with 401.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrAccessDenied is passed to Deny when the policies do not allow the request, it is denied with 403.
+	ErrAccessDenied = errors.New("access denied")
+)
+
+// Extractor returns a value of the request.
+type Extractor[T any] func(r *http.Request) (T, error)
+
+// Middleware allows a request to the next handler only if the Authorizer allows it.
+type Middleware struct {
+	Authorizer policy.Authorizer
+
+	// User returns the user of the request.
+	User Extractor[policy.UserPropertyGetter]
+	// Resource returns the resource of the request, the default is PathResource.
+	Resource Extractor[string]
+	// Action returns the action of the request, the default is MethodAction.
+	Action Extractor[string]
+	// Properties returns the resource properties of the request, none by default.
+	Properties Extractor[policy.Property]
+
+	// Deny writes the response of a denied request, the default is DefaultDeny.
+	// The error is ErrAccessDenied, an error of an extractor, or an error of the Authorizer.
+	Deny func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// New creates a middleware with the default resource, action and deny response.
+func New(authorizer policy.Authorizer, user Extractor[policy.UserPropertyGetter]) *Middleware {
+	return &Middleware{
+		Authorizer: authorizer,
+		User:       user,
+		Resource:   PathResource,
+		Action:     MethodAction,
+		Deny:       DefaultDeny,
+	}
+}
+
+// Handler returns a handler that checks each request before calling next.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, err := m.authorize(r)
+		if err == nil && !allowed {
+			err = ErrAccessDenied
+		}
+		if err != nil {
+			deny := m.Deny
+			if deny == nil {
+				deny = DefaultDeny
+			}
+			deny(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Middleware) authorize(r *http.Request) (bool, error) {
+	var user policy.UserPropertyGetter
+	if m.User != nil {
+		var err error
+		if user, err = m.User(r); err != nil {
+			return false, err
+		}
+	}
+
+	resource, action := PathResource, MethodAction
+	if m.Resource != nil {
+		resource = m.Resource
+	}
+	if m.Action != nil {
+		action = m.Action
+	}
+
+	res := policy.Resource{}
+	var err error
+	if res.Resource, err = resource(r); err != nil {
+		return false, err
+	}
+	if res.Action, err = action(r); err != nil {
+		return false, err
+	}
+	if m.Properties != nil {
+		if res.Properties, err = m.Properties(r); err != nil {
+			return false, err
+		}
+	}
+
+	return m.Authorizer.Authorize(r.Context(), user, res)
+}
+
+// DefaultDeny responds 401 to ErrUnauthenticated, 403 to ErrAccessDenied and 500 to other errors.
+func DefaultDeny(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		status = http.StatusUnauthorized
+	case errors.Is(err, ErrAccessDenied):
+		status = http.StatusForbidden
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// PathResource maps the path to a resource, e.g. "/documents/1" to "res:::documents:1".
+func PathResource(r *http.Request) (string, error) {
+	path := strings.Trim(r.URL.Path, "/")
+	return "res:::" + strings.ReplaceAll(path, "/", ":"), nil
+}
+
+// MethodAction maps the method to an action, e.g. "GET" to "act:::get".
+func MethodAction(r *http.Request) (string, error) {
+	return "act:::" + strings.ToLower(r.Method), nil
+}
+
+// Static returns an extractor of a fixed value, e.g. the resource or the action of a route.
+func Static(value string) Extractor[string] {
+	return func(r *http.Request) (string, error) {
+		return value, nil
+	}
+}
+
+// PathValueProperties maps the wildcards of the route pattern to string properties,
+// e.g. {"id": "prop:::document:id"} for the pattern "/documents/{id}".
+func PathValueProperties(keys map[string]string) Extractor[policy.Property] {
+	return func(r *http.Request) (policy.Property, error) {
+		prop := policy.Property{String: make(map[string]string)}
+		for name, key := range keys {
+			if value := r.PathValue(name); value != "" {
+				prop.String[key] = value
+			}
+		}
+		return prop, nil
+	}
+}
@@ -0,0 +1,269 @@
+package httpauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golfz/policy/v2"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testValidator() policy.Authorizer {
+	pv := policy.New()
+	pv.Policies = []policy.Policy{
+		{
+			Statements: []policy.Statement{
+				{
+					Effect:   "Allow",
+					Resource: "res:::documents",
+					Actions:  []string{"act:::get"},
+				},
+				{
+					Effect:   "Allow",
+					Resource: "res:::document",
+					Actions:  []string{"act:::document:edit"},
+					Conditions: &policy.Condition{
+						MustHaveAll: map[string]policy.Comparator{
+							"prop:::document:owner": {UserPropEqual: ptr("user:::id")},
+						},
+					},
+				},
+			},
+		},
+	}
+	return pv
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestMiddleware_DefaultMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		want   int
+	}{
+		{"allowed", http.MethodGet, "/documents/", "u1", http.StatusOK},
+		{"denied action", http.MethodDelete, "/documents", "u1", http.StatusForbidden},
+		{"denied resource", http.MethodGet, "/users", "u1", http.StatusForbidden},
+		{"no user", http.MethodGet, "/documents", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mw := New(testValidator(), HeaderUser(map[string]string{"user:::id": "X-User-Id"}))
+			handler := mw.Handler(okHandler)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("X-User-Id", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.want {
+				t.Errorf("want %d, but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestMiddleware_RouteWithProperties(t *testing.T) {
+	owners := map[string]string{"1": "u1", "2": "u2"}
+	tests := []struct {
+		name   string
+		path   string
+		userID string
+		want   int
+	}{
+		{"owner", "/documents/1", "u1", http.StatusOK},
+		{"not owner", "/documents/2", "u1", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mw := New(testValidator(), HeaderUser(map[string]string{"user:::id": "X-User-Id"}))
+			mw.Resource = Static("res:::document")
+			mw.Action = Static("act:::document:edit")
+			mw.Properties = func(r *http.Request) (policy.Property, error) {
+				return policy.Property{String: map[string]string{"prop:::document:owner": owners[r.PathValue("id")]}}, nil
+			}
+			mux := http.NewServeMux()
+			mux.Handle("PUT /documents/{id}", mw.Handler(okHandler))
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			req.Header.Set("X-User-Id", tt.userID)
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, req)
+
+			// Assert
+			if rec.Code != tt.want {
+				t.Errorf("want %d, but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestMiddleware_NilUser(t *testing.T) {
+	// Arrange
+	mw := New(testValidator(), nil)
+	mw.Resource = Static("res:::document")
+	mw.Action = Static("act:::document:edit")
+	mw.Properties = func(r *http.Request) (policy.Property, error) {
+		return policy.Property{String: map[string]string{"prop:::document:owner": ""}}, nil
+	}
+	rec := httptest.NewRecorder()
+
+	// Act
+	mw.Handler(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/documents/1", nil))
+
+	// Assert
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("want %d, but got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestMiddleware_Errors(t *testing.T) {
+	errExtractor := errors.New("extractor error")
+	errAuthorizer := errors.New("authorizer error")
+
+	tests := []struct {
+		name       string
+		authorizer policy.Authorizer
+		setup      func(mw *Middleware)
+		wantErr    error
+	}{
+		{
+			name:       "resource extractor error",
+			authorizer: testValidator(),
+			setup: func(mw *Middleware) {
+				mw.Resource = func(r *http.Request) (string, error) { return "", errExtractor }
+			},
+			wantErr: errExtractor,
+		},
+		{
+			name:       "properties extractor error",
+			authorizer: testValidator(),
+			setup: func(mw *Middleware) {
+				mw.Properties = func(r *http.Request) (policy.Property, error) { return policy.Property{}, errExtractor }
+			},
+			wantErr: errExtractor,
+		},
+		{
+			name: "authorizer error",
+			authorizer: policy.AuthorizerFunc(func(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+				return true, errAuthorizer
+			}),
+			wantErr: errAuthorizer,
+		},
+		{
+			name: "denied",
+			authorizer: policy.AuthorizerFunc(func(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+				return false, nil
+			}),
+			wantErr: ErrAccessDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var gotErr error
+			mw := New(tt.authorizer, func(r *http.Request) (policy.UserPropertyGetter, error) { return nil, nil })
+			mw.Deny = func(w http.ResponseWriter, r *http.Request, err error) {
+				gotErr = err
+				w.WriteHeader(http.StatusTeapot)
+			}
+			if tt.setup != nil {
+				tt.setup(mw)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			mw.Handler(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/documents", nil))
+
+			// Assert
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("want %v, but got %v", tt.wantErr, gotErr)
+			}
+			if rec.Code != http.StatusTeapot {
+				t.Errorf("want %d, but got %d", http.StatusTeapot, rec.Code)
+			}
+		})
+	}
+}
+
+func TestDefaultDeny(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{ErrAccessDenied, http.StatusForbidden},
+		{ErrUnauthenticated, http.StatusUnauthorized},
+		{errors.New("error"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			// Arrange
+			rec := httptest.NewRecorder()
+
+			// Act
+			DefaultDeny(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			// Assert
+			if rec.Code != tt.want {
+				t.Errorf("want %d, but got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestPathResourceAndMethodAction(t *testing.T) {
+	// Arrange
+	req := httptest.NewRequest(http.MethodPost, "/documents/1/comments/", nil)
+
+	// Act
+	resource, _ := PathResource(req)
+	action, _ := MethodAction(req)
+
+	// Assert
+	if resource != "res:::documents:1:comments" {
+		t.Errorf("want res:::documents:1:comments, but got %s", resource)
+	}
+	if action != "act:::post" {
+		t.Errorf("want act:::post, but got %s", action)
+	}
+}
+
+func TestPathValueProperties(t *testing.T) {
+	// Arrange
+	var got policy.Property
+	extract := PathValueProperties(map[string]string{"id": "prop:::document:id", "missing": "prop:::document:missing"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /documents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		got, _ = extract(r)
+	})
+
+	// Act
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/documents/42", nil))
+
+	// Assert
+	if len(got.String) != 1 || got.String["prop:::document:id"] != "42" {
+		t.Errorf("want prop:::document:id 42, but got %v", got.String)
+	}
+}
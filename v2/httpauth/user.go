@@ -0,0 +1,59 @@
+package httpauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golfz/policy/v2"
+)
+
+// HeaderUser returns the user properties from request headers, e.g. {"user:::id": "X-User-Id"}.
+// It is meant for services behind a gateway that authenticates the user and sets the headers.
+// The request is unauthenticated if none of the headers is set.
+func HeaderUser(headers map[string]string) Extractor[policy.UserPropertyGetter] {
+	return func(r *http.Request) (policy.UserPropertyGetter, error) {
+		values := make(headerUser, len(headers))
+		for key, header := range headers {
+			if value := r.Header.Get(header); value != "" {
+				values[key] = value
+			}
+		}
+		if len(values) == 0 {
+			return nil, ErrUnauthenticated
+		}
+		return values, nil
+	}
+}
+
+type headerUser map[string]string
+
+func (u headerUser) GetUserProperty(key string) string {
+	return u[key]
+}
+
+// ClaimsUser returns a UserPropertyGetter of a claim set, e.g. "user:::org:id" is the claim {"org": {"id": ...}}.
+func ClaimsUser(claims map[string]interface{}) (policy.UserPropertyGetter, error) {
+	b, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	return policy.NewDefaultUserPropertyGetter(string(b)), nil
+}
+
+// BearerClaimsUser returns the user of the bearer token in the Authorization header.
+// The parse function verifies the token, e.g. a JWT, and returns its claims.
+func BearerClaimsUser(parse func(token string) (map[string]interface{}, error)) Extractor[policy.UserPropertyGetter] {
+	return func(r *http.Request) (policy.UserPropertyGetter, error) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil, ErrUnauthenticated
+		}
+		claims, err := parse(token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+		}
+		return ClaimsUser(claims)
+	}
+}
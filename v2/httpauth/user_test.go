@@ -0,0 +1,81 @@
+package httpauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderUser(t *testing.T) {
+	// Arrange
+	extract := HeaderUser(map[string]string{"user:::id": "X-User-Id", "user:::role": "X-User-Role"})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-Id", "u1")
+
+	// Act
+	user, err := extract(req)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got := user.GetUserProperty("user:::id"); got != "u1" {
+		t.Errorf("want u1, but got %s", got)
+	}
+	if got := user.GetUserProperty("user:::role"); got != "" {
+		t.Errorf("want empty, but got %s", got)
+	}
+}
+
+func TestBearerClaimsUser(t *testing.T) {
+	errInvalidToken := errors.New("invalid token")
+	parse := func(token string) (map[string]interface{}, error) {
+		if token != "valid" {
+			return nil, errInvalidToken
+		}
+		return map[string]interface{}{"sub": "u1", "org": map[string]interface{}{"id": "o1"}}, nil
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantErr       error
+	}{
+		{"valid token", "Bearer valid", nil},
+		{"lowercase scheme", "bearer valid", nil},
+		{"no header", "", ErrUnauthenticated},
+		{"basic auth", "Basic dTE6cGFzcw==", ErrUnauthenticated},
+		{"invalid token", "Bearer invalid", errInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			// Act
+			user, err := BearerClaimsUser(parse)(req)
+
+			// Assert
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, ErrUnauthenticated) {
+					t.Errorf("want %v, but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if got := user.GetUserProperty("user:::sub"); got != "u1" {
+				t.Errorf("want u1, but got %s", got)
+			}
+			if got := user.GetUserProperty("user:::org:id"); got != "o1" {
+				t.Errorf("want o1, but got %s", got)
+			}
+		})
+	}
+}
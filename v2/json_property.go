@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// NewPropertyFromJSON reads the resource properties from a JSON object, any other JSON value or data after the object is an error.
+// Every value is added with the key of its path, e.g. {"invoice": {"amount": 10}} is read as
+// "prop:::invoice:amount", so that it can be compared without flattening the document by hand.
+//
+// Values keep their JSON type: strings are added to Property.String, booleans to Property.Boolean,
+// numbers to Property.Float and, when they are written as integers, also to Property.Integer.
+// Arrays and nulls are skipped.
+func NewPropertyFromJSON(jsonData string) (Property, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(jsonData)))
+	decoder.UseNumber()
+
+	var data map[string]interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return Property{}, fmt.Errorf("cannot parse resource properties: %w", err)
+	}
+	if data == nil {
+		return Property{}, errors.New("cannot parse resource properties: not an object")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return Property{}, errors.New("cannot parse resource properties: data after the object")
+	}
+	return NewPropertyFromMap(data)
+}
+
+// NewPropertyFromMap reads the resource properties from a map, the same way as NewPropertyFromJSON.
+// Nested maps must be map[string]interface{}, leaf values can be any Go string, integer, float
+// or boolean type, json.Number or time.Time. Any other type is an error.
+func NewPropertyFromMap(data map[string]interface{}) (Property, error) {
+	prop := Property{
+		String:  make(map[string]string),
+		Integer: make(map[string]int),
+		Float:   make(map[string]float64),
+		Boolean: make(map[string]bool),
+	}
+	err := flattenProperties(data, "", &prop)
+	return prop, err
+}
+
+func flattenProperties(data map[string]interface{}, parentKey string, prop *Property) error {
+	for name, value := range data {
+		key := propertyPrefix + name
+		if parentKey != "" {
+			key = parentKey + propertySeparator + name
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			if err := flattenProperties(nested, key, prop); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := addJSONPropertyValue(prop, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addJSONPropertyValue(prop *Property, key string, value interface{}) error {
+	switch v := value.(type) {
+	case nil, []interface{}:
+		return nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil && i >= math.MinInt && i <= math.MaxInt {
+			prop.Integer[key] = int(i)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("property %s: %w", key, err)
+		}
+		prop.Float[key] = f
+		return nil
+	case time.Time:
+		prop.String[key] = v.Format(time.RFC3339)
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		prop.String[key] = rv.String()
+	case reflect.Bool:
+		prop.Boolean[key] = rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		prop.Integer[key] = int(rv.Int())
+		prop.Float[key] = float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt {
+			return fmt.Errorf("property %s: value %d overflows int", key, rv.Uint())
+		}
+		prop.Integer[key] = int(rv.Uint())
+		prop.Float[key] = float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		prop.Float[key] = rv.Float()
+	default:
+		return fmt.Errorf("property %s: unsupported type %T", key, value)
+	}
+	return nil
+}
+
+// AddPropertiesFromJSON adds the properties read by NewPropertyFromJSON to the resource.
+// If the JSON cannot be read, the error is set to the validator and returned by IsAccessAllowed.
+func (pv *policyValidator) AddPropertiesFromJSON(jsonData string) {
+	prop, err := NewPropertyFromJSON(jsonData)
+	if err != nil {
+		pv.SetError(err)
+		return
+	}
+	pv.AddProperties(prop)
+}
@@ -0,0 +1,199 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewPropertyFromJSON(t *testing.T) {
+	// Arrange
+	jsonData := `
+		{
+			"invoice": {
+				"owner": "u1",
+				"amount": 99.5,
+				"quantity": 3,
+				"paid": false,
+				"tags": ["a", "b"],
+				"approver": null,
+				"customer": {
+					"uuid": "c1"
+				}
+			}
+		}`
+	want := Property{
+		String: map[string]string{
+			"prop:::invoice:owner":         "u1",
+			"prop:::invoice:customer:uuid": "c1",
+		},
+		Integer: map[string]int{
+			"prop:::invoice:quantity": 3,
+		},
+		Float: map[string]float64{
+			"prop:::invoice:amount":   99.5,
+			"prop:::invoice:quantity": 3,
+		},
+		Boolean: map[string]bool{
+			"prop:::invoice:paid": false,
+		},
+	}
+
+	// Act
+	got, err := NewPropertyFromJSON(jsonData)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, but want %v", got, want)
+	}
+}
+
+func TestNewPropertyFromJSON_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonData string
+	}{
+		{"empty", ""},
+		{"not an object", `["a"]`},
+		{"malformed", `{"a": `},
+		{"null", `null`},
+		{"trailing data", `{"a": 1} garbage`},
+		{"two objects", `{"a": 1} {"b": 2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPropertyFromJSON(tt.jsonData)
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
+
+func TestNewPropertyFromMap(t *testing.T) {
+	t.Run("go types", func(t *testing.T) {
+		// Arrange
+		data := map[string]interface{}{
+			"doc": map[string]interface{}{
+				"owner":      "u1",
+				"level":      int64(2),
+				"size":       uint8(7),
+				"score":      float32(0.5),
+				"public":     true,
+				"created_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			},
+		}
+		want := Property{
+			String: map[string]string{
+				"prop:::doc:owner":      "u1",
+				"prop:::doc:created_at": "2024-01-02T03:04:05Z",
+			},
+			Integer: map[string]int{
+				"prop:::doc:level": 2,
+				"prop:::doc:size":  7,
+			},
+			Float: map[string]float64{
+				"prop:::doc:level": 2,
+				"prop:::doc:size":  7,
+				"prop:::doc:score": 0.5,
+			},
+			Boolean: map[string]bool{
+				"prop:::doc:public": true,
+			},
+		}
+
+		// Act
+		got, err := NewPropertyFromMap(data)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("want nil, but got %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, but want %v", got, want)
+		}
+	})
+
+	t.Run("unsupported type, expect error", func(t *testing.T) {
+		_, err := NewPropertyFromMap(map[string]interface{}{
+			"doc": map[string]interface{}{"owner": struct{}{}},
+		})
+		if err == nil {
+			t.Error("want error, but got nil")
+		}
+	})
+}
+
+func TestAddPropertiesFromJSON(t *testing.T) {
+	policies := []Policy{
+		{
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::invoice",
+					Actions:  []string{"act:::invoice:pay"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::invoice:amount":   {FloatIn: &[]float64{99.5, 100}},
+							"prop:::invoice:quantity": {IntegerEqual: ptr(3)},
+							"prop:::invoice:paid":     {BooleanEqual: ptr(false)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		jsonData string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "matched, expect ALLOWED",
+			jsonData: `{"invoice": {"amount": 99.5, "quantity": 3, "paid": false}}`,
+			want:     ALLOWED,
+		},
+		{
+			name:     "not matched, expect DENIED",
+			jsonData: `{"invoice": {"amount": 99.5, "quantity": 3, "paid": true}}`,
+			want:     DENIED,
+		},
+		{
+			name:     "invalid JSON, expect error",
+			jsonData: `{"invoice": `,
+			want:     DENIED,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = policies
+			pv.SetResource("res:::invoice")
+			pv.SetAction("act:::invoice:pay")
+
+			// Act
+			pv.AddPropertiesFromJSON(tt.jsonData)
+			result, err := pv.IsAccessAllowed()
+
+			// Assert
+			if tt.wantErr && err == nil {
+				t.Error("want error, but got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %v, but want %v", result, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type memoryPolicyStore struct {
+	mu          sync.RWMutex
+	policies    map[string]Policy
+	attachments map[string]map[string]bool
+	version     int64
+}
+
+// NewMemoryPolicyStore creates a PolicyStore that keeps the policies in memory.
+func NewMemoryPolicyStore() PolicyStore {
+	return &memoryPolicyStore{
+		policies:    make(map[string]Policy),
+		attachments: make(map[string]map[string]bool),
+	}
+}
+
+func (s *memoryPolicyStore) List(ctx context.Context) ([]Policy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policies := make([]Policy, 0, len(s.policies))
+	for _, id := range sortedKeys(s.policies) {
+		p, err := clonePolicy(s.policies[id])
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (s *memoryPolicyStore) Get(ctx context.Context, policyID string) (Policy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.policies[policyID]
+	if !ok {
+		return Policy{}, ErrPolicyNotFound
+	}
+	return clonePolicy(p)
+}
+
+func (s *memoryPolicyStore) Put(ctx context.Context, policy Policy) error {
+	if err := checkStoredPolicy(policy); err != nil {
+		return err
+	}
+	p, err := clonePolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policies[p.PolicyID] = p
+	s.version++
+	return nil
+}
+
+func (s *memoryPolicyStore) Delete(ctx context.Context, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[policyID]; !ok {
+		return ErrPolicyNotFound
+	}
+	delete(s.policies, policyID)
+	for _, attached := range s.attachments {
+		delete(attached, policyID)
+	}
+	s.version++
+	return nil
+}
+
+func (s *memoryPolicyStore) Version(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.version, nil
+}
+
+func (s *memoryPolicyStore) Attach(ctx context.Context, principal, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[policyID]; !ok {
+		return ErrPolicyNotFound
+	}
+	if s.attachments[principal][policyID] {
+		return nil
+	}
+	if s.attachments[principal] == nil {
+		s.attachments[principal] = make(map[string]bool)
+	}
+	s.attachments[principal][policyID] = true
+	s.version++
+	return nil
+}
+
+func (s *memoryPolicyStore) Detach(ctx context.Context, principal, policyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.attachments[principal][policyID] {
+		return nil
+	}
+	delete(s.attachments[principal], policyID)
+	s.version++
+	return nil
+}
+
+func (s *memoryPolicyStore) Attached(ctx context.Context, principal string) ([]Policy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.attachments[principal]))
+	for id := range s.attachments[principal] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	policies := make([]Policy, 0, len(ids))
+	for _, id := range ids {
+		p, err := clonePolicy(s.policies[id])
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
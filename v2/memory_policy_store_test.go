@@ -0,0 +1,9 @@
+package policy
+
+import "testing"
+
+func TestMemoryPolicyStore(t *testing.T) {
+	testPolicyStore(t, func(t *testing.T) PolicyStore {
+		return NewMemoryPolicyStore()
+	})
+}
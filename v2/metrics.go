@@ -0,0 +1,26 @@
+package policy
+
+import "time"
+
+// Metrics receives the measurements of the validator, e.g. to export them to a monitoring system,
+// see package prommetrics for the Prometheus text format. The methods must be safe for concurrent use.
+type Metrics interface {
+	// ObserveDecision is called for each decision of IsAccessAllowed, Authorize, ExplainAccess, Explain and IsAccessAllowedBatch,
+	// with the error of the decision and the time it took.
+	// The resource and the action are those of the request, e.g. with the ID of an object from the path
+	// of httpauth.PathResource, so they may have an unbounded number of values. An implementation that
+	// uses them as labels must map them to a few values, or limit the number of series.
+	ObserveDecision(resource, action string, allowed bool, err error, duration time.Duration)
+	// ValidationFunctionError is called when a validation function returns an error or is not set,
+	// the comparator is not matched.
+	ValidationFunctionError(function string)
+	// PostValidatorDenied is called when a post validator denies an access that the statements allow.
+	PostValidatorDenied()
+}
+
+// observeDecision passes the decision to the Metrics, if it is not nil.
+func (pv *policyValidator) observeDecision(res Resource, allowed bool, err error, start time.Time) {
+	if pv.Metrics != nil {
+		pv.Metrics.ObserveDecision(res.Resource, res.Action, allowed, err, time.Since(start))
+	}
+}
@@ -0,0 +1,261 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockMetrics struct {
+	mu                       sync.Mutex
+	decisions                []string
+	durations                []time.Duration
+	validationFunctionErrors []string
+	postValidatorDenials     int
+}
+
+func (m *mockMetrics) ObserveDecision(resource, action string, allowed bool, err error, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	decision := "denied"
+	if err != nil {
+		decision = "error"
+	} else if allowed {
+		decision = "allowed"
+	}
+	m.decisions = append(m.decisions, resource+" "+action+" "+decision)
+	m.durations = append(m.durations, duration)
+}
+
+func (m *mockMetrics) ValidationFunctionError(function string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validationFunctionErrors = append(m.validationFunctionErrors, function)
+}
+
+func (m *mockMetrics) PostValidatorDenied() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.postValidatorDenials++
+}
+
+func TestMetrics_IsAccessAllowed(t *testing.T) {
+	tests := []struct {
+		name          string
+		action        string
+		prop          Property
+		postValidator *MockPostValidator
+		err           error
+		wantDecision  string
+		wantDenials   int
+	}{
+		{
+			name:          "allowed",
+			action:        "act:::doc:read",
+			postValidator: &MockPostValidator{Result: true},
+			wantDecision:  "res:::doc act:::doc:read allowed",
+		},
+		{
+			name:          "denied by statement",
+			action:        "act:::doc:read",
+			prop:          Property{Boolean: map[string]bool{"prop:::doc:locked": true}},
+			postValidator: &MockPostValidator{Result: true},
+			wantDecision:  "res:::doc act:::doc:read denied",
+		},
+		{
+			name:          "denied by post validator",
+			action:        "act:::doc:read",
+			postValidator: &MockPostValidator{Result: false},
+			wantDecision:  "res:::doc act:::doc:read denied",
+			wantDenials:   1,
+		},
+		{
+			name:          "post validator error",
+			action:        "act:::doc:read",
+			postValidator: &MockPostValidator{Error: errors.New("error")},
+			wantDecision:  "res:::doc act:::doc:read error",
+		},
+		{
+			name:          "no match",
+			action:        "act:::doc:write",
+			postValidator: &MockPostValidator{Result: false},
+			wantDecision:  "res:::doc act:::doc:write denied",
+		},
+		{
+			name:         "error",
+			action:       "act:::doc:read",
+			err:          errors.New("error"),
+			wantDecision: "res:::doc act:::doc:read error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			metrics := &mockMetrics{}
+			pv := New()
+			pv.Policies = explanationPolicies()
+			pv.Metrics = metrics
+			pv.SetResource("res:::doc")
+			pv.SetAction(tt.action)
+			pv.AddProperties(tt.prop)
+			pv.SetError(tt.err)
+			if tt.postValidator != nil {
+				pv.AddPostExecutor(tt.postValidator)
+			}
+
+			// Act
+			_, _ = pv.IsAccessAllowed()
+
+			// Assert
+			if want := []string{tt.wantDecision}; !reflect.DeepEqual(metrics.decisions, want) {
+				t.Errorf("want %v, but got %v", want, metrics.decisions)
+			}
+			if metrics.postValidatorDenials != tt.wantDenials {
+				t.Errorf("want %d post validator denials, but got %d", tt.wantDenials, metrics.postValidatorDenials)
+			}
+		})
+	}
+}
+
+func TestMetrics_ExplainAccess(t *testing.T) {
+	tests := []struct {
+		name         string
+		action       string
+		err          error
+		wantDecision string
+	}{
+		{"allowed", "act:::doc:read", nil, "res:::doc act:::doc:read allowed"},
+		{"denied", "act:::doc:write", nil, "res:::doc act:::doc:write denied"},
+		{"error", "act:::doc:read", errors.New("error"), "res:::doc act:::doc:read error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			metrics := &mockMetrics{}
+			pv := New()
+			pv.Policies = explanationPolicies()
+			pv.Metrics = metrics
+			pv.SetResource("res:::doc")
+			pv.SetAction(tt.action)
+			pv.SetError(tt.err)
+
+			// Act
+			_, _ = pv.ExplainAccess()
+
+			// Assert
+			if want := []string{tt.wantDecision}; !reflect.DeepEqual(metrics.decisions, want) {
+				t.Errorf("want %v, but got %v", want, metrics.decisions)
+			}
+		})
+	}
+}
+
+func TestMetrics_ValidationFunctionError(t *testing.T) {
+	// Arrange
+	metrics := &mockMetrics{}
+	pv := New()
+	pv.Policies = []Policy{{PolicyID: "p", Statements: []Statement{{
+		Effect:   statementEffectAllow,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{MustHaveAll: map[string]Comparator{
+			"prop:::doc:owner": {ValidationFunc: &ValidationFunc{Function: "isMember", StringArg: ptr("team")}},
+		}},
+	}}}}
+	pv.Metrics = metrics
+	pv.SetValidationFunction("isMember", func(a, b string) (bool, error) { return false, errors.New("directory unavailable") })
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+	pv.AddPropertyString("prop:::doc:owner", "u1")
+
+	// Act
+	allowed, err := pv.IsAccessAllowed()
+
+	// Assert
+	if allowed != DENIED || err != nil {
+		t.Errorf("want DENIED, but got %v, %v", allowed, err)
+	}
+	if want := []string{"isMember"}; !reflect.DeepEqual(metrics.validationFunctionErrors, want) {
+		t.Errorf("want %v, but got %v", want, metrics.validationFunctionErrors)
+	}
+}
+
+func TestMetrics_MissingValidationFunction(t *testing.T) {
+	// Arrange
+	metrics := &mockMetrics{}
+	pv := New()
+	pv.Policies = []Policy{{PolicyID: "p", Statements: []Statement{{
+		Effect:   statementEffectAllow,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{MustHaveAll: map[string]Comparator{
+			"prop:::doc:owner": {ValidationFunc: &ValidationFunc{Function: "isMember", StringArg: ptr("team")}},
+		}},
+	}}}}
+	pv.Metrics = metrics
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	allowed, err := pv.IsAccessAllowed()
+
+	// Assert
+	if allowed != DENIED || err != nil {
+		t.Errorf("want DENIED, but got %v, %v", allowed, err)
+	}
+	if want := []string{"isMember"}; !reflect.DeepEqual(metrics.validationFunctionErrors, want) {
+		t.Errorf("want %v, but got %v", want, metrics.validationFunctionErrors)
+	}
+}
+
+func TestMetrics_Batch(t *testing.T) {
+	// Arrange
+	metrics := &mockMetrics{}
+	pv := New()
+	pv.Policies = explanationPolicies()
+	pv.Metrics = metrics
+	resources := []Resource{
+		{Resource: "res:::doc", Action: "act:::doc:read"},
+		{Resource: "res:::doc", Action: "act:::doc:read", Properties: Property{Boolean: map[string]bool{"prop:::doc:locked": true}}},
+		{Resource: "res:::doc", Action: "act:::doc:write"},
+	}
+
+	// Act
+	_, err := pv.IsAccessAllowedBatch(resources)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	want := []string{"res:::doc act:::doc:read allowed", "res:::doc act:::doc:read denied", "res:::doc act:::doc:write denied"}
+	if !reflect.DeepEqual(metrics.decisions, want) {
+		t.Errorf("want %v, but got %v", want, metrics.decisions)
+	}
+}
+
+func TestMetrics_DecisionLogger(t *testing.T) {
+	// Arrange
+	metrics := &mockMetrics{}
+	logger := &MemoryDecisionLogger{}
+	pv := New()
+	pv.Policies = explanationPolicies()
+	pv.Metrics = metrics
+	pv.DecisionLogger = logger
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	_, _ = pv.IsAccessAllowed()
+
+	// Assert
+	if want := []string{"res:::doc act:::doc:read allowed"}; !reflect.DeepEqual(metrics.decisions, want) {
+		t.Errorf("want %v, but got %v", want, metrics.decisions)
+	}
+	if len(logger.Records()) != 1 {
+		t.Errorf("want 1 record, but got %d", len(logger.Records()))
+	}
+}
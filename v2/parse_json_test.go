@@ -98,6 +98,46 @@ func TestParsePolicy_ValidationFunc(t *testing.T) {
 	}
 }
 
+func TestParsePolicy_Expression(t *testing.T) {
+	// Arrange
+	b, err := os.ReadFile("test_data/parse_policy/policy_Expression.json")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Act
+	p, err := ParsePolicy(b)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected nil, but got %s", err)
+	}
+	expr := p.Statements[0].Conditions.MustHaveAll["prop:::resource_1:prop_1"].Expression
+	if expr == nil {
+		t.Fatal("Expected Expression in the first statement to be not nil")
+	}
+	want := "prop.resource_1.prop_1 == user.user_1.prop_1 && prop.resource_1.prop_2 in [1, 2, 3]"
+	if expr.String() != want {
+		t.Errorf("Expected %s, but got %s", want, expr.String())
+	}
+}
+
+func TestParsePolicy_ExpressionTypeError(t *testing.T) {
+	// Arrange
+	b, err := os.ReadFile("test_data/parse_policy/policy_Expression_type_error.json")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Act
+	_, err = ParsePolicy(b)
+
+	// Assert
+	if err == nil {
+		t.Error("Expected error, but got nil")
+	}
+}
+
 // ----------------------------------------------
 // PolicyArray
 // ----------------------------------------------
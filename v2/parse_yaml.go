@@ -0,0 +1,367 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const yamlIndent = 2
+
+// maxYAMLAliasNodes limits the nodes expanded from aliases, so that a small document cannot expand to a huge one.
+const maxYAMLAliasNodes = 10000
+
+// ParsePolicyYAML parses a policy in YAML. The keys are the field names, like in JSON:
+//
+//	Version: 1
+//	PolicyID: documents
+//	Statements:
+//	  - Effect: Allow
+//	    Resource: res:::doc
+//	    Actions: [act:::doc:read]
+//
+// The YAML is read like the same document in JSON, so the errors are the same as those of ParsePolicy.
+func ParsePolicyYAML(b []byte) (Policy, error) {
+	var policy Policy
+	data, err := yamlToJSON(b)
+	if err != nil {
+		return policy, err
+	}
+	err = json.Unmarshal(data, &policy)
+	return policy, err
+}
+
+// ParsePolicyArrayYAML parses policies in YAML. Each document of the YAML is an array of policies or a policy.
+func ParsePolicyArrayYAML(b []byte) ([]Policy, error) {
+	var policies []Policy
+	docs, err := decodeYAMLDocuments(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		data, err := yamlNodeToJSON(doc)
+		if err != nil {
+			return nil, err
+		}
+		if isYAMLSequence(doc) {
+			var parsed []Policy
+			if err := json.Unmarshal(data, &parsed); err != nil {
+				return nil, err
+			}
+			policies = append(policies, parsed...)
+			continue
+		}
+		var policy Policy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// MarshalPolicyYAML returns the policy in YAML, without the fields that are null.
+func MarshalPolicyYAML(policy Policy) ([]byte, error) {
+	return marshalYAML(policy)
+}
+
+// MarshalPolicyArrayYAML returns the policies in YAML, without the fields that are null.
+func MarshalPolicyArrayYAML(policies []Policy) ([]byte, error) {
+	if policies == nil {
+		policies = []Policy{}
+	}
+	return marshalYAML(policies)
+}
+
+// FormatPolicyYAML formats the policies in YAML like MarshalPolicyYAML, the fields are in the order of
+// the Go types and indented with 2 spaces. Comments are kept on the fields and items that are still there
+// after formatting. The policies are parsed like ParsePolicyArrayYAML, and the error is returned if they cannot be.
+func FormatPolicyYAML(b []byte) ([]byte, error) {
+	docs, err := decodeYAMLDocuments(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(yamlIndent)
+	for _, doc := range docs {
+		data, err := yamlNodeToJSON(doc)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if isYAMLSequence(doc) {
+			var policies []Policy
+			err = json.Unmarshal(data, &policies)
+			v = policies
+		} else {
+			var policy Policy
+			err = json.Unmarshal(data, &policy)
+			v = policy
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		formatted, err := yamlNodeOf(v)
+		if err != nil {
+			return nil, err
+		}
+		copyYAMLComments(formatted, doc)
+		if err := encoder.Encode(formatted); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// ParsePolicyFile parses the content of a policy file that holds a policy or an array of policies,
+// in YAML if the name ends with ".yaml" or ".yml", in the policy DSL if it ends with ".policy", and in JSON otherwise.
+func ParsePolicyFile(name string, b []byte) ([]Policy, error) {
+	if IsDSLFile(name) {
+		return ParsePolicyDSL(b)
+	}
+	if IsYAMLFile(name) {
+		return ParsePolicyArrayYAML(b)
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return ParsePolicyArray(b)
+	}
+	policy, err := ParsePolicy(b)
+	if err != nil {
+		return nil, err
+	}
+	return []Policy{policy}, nil
+}
+
+// IsYAMLFile reports whether the name ends with ".yaml" or ".yml".
+func IsYAMLFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func decodeYAMLDocuments(b []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &doc)
+	}
+}
+
+func isYAMLSequence(doc *yaml.Node) bool {
+	return len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode
+}
+
+// yamlToJSON converts the single YAML document to JSON.
+func yamlToJSON(b []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return yamlNodeToJSON(&doc)
+}
+
+func yamlNodeToJSON(node *yaml.Node) ([]byte, error) {
+	var c yamlConverter
+	v, err := c.value(node, false)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// yamlConverter converts the nodes of a YAML document and counts the nodes expanded from aliases.
+type yamlConverter struct {
+	aliasNodes int
+}
+
+// value returns the value of the node as it would be decoded from JSON.
+// Timestamps are kept as strings, since the fields of the policies that hold them are strings.
+func (c *yamlConverter) value(node *yaml.Node, aliased bool) (interface{}, error) {
+	if aliased {
+		c.aliasNodes++
+		if c.aliasNodes > maxYAMLAliasNodes {
+			return nil, fmt.Errorf("yaml: line %d: aliases expand to more than %d nodes", node.Line, maxYAMLAliasNodes)
+		}
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return c.value(node.Content[0], aliased)
+	case yaml.AliasNode:
+		return c.value(node.Alias, true)
+	case yaml.SequenceNode:
+		values := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			v, err := c.value(item, aliased)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	case yaml.MappingNode:
+		values := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("yaml: line %d: key must be a string", key.Line)
+			}
+			v, err := c.value(node.Content[i+1], aliased)
+			if err != nil {
+				return nil, err
+			}
+			values[key.Value] = v
+		}
+		return values, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool", "!!int", "!!float":
+			var v interface{}
+			if err := node.Decode(&v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		default:
+			return node.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("yaml: line %d: unsupported node", node.Line)
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	node, err := yamlNodeOf(v)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(yamlIndent)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// yamlNodeOf returns the YAML document of the JSON of v, in the same order and without the fields that are null.
+func yamlNodeOf(v interface{}) (*yaml.Node, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+	node, err := jsonToYAMLNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}, nil
+}
+
+func jsonToYAMLNode(decoder *json.Decoder) (*yaml.Node, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if t == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for decoder.More() {
+			var key *yaml.Node
+			if node.Kind == yaml.MappingNode {
+				tok, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tok.(string)}
+			}
+			value, err := jsonToYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if key == nil {
+				node.Content = append(node.Content, value)
+			} else if value.ShortTag() != "!!null" {
+				node.Content = append(node.Content, key, value)
+			}
+		}
+		// the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}, nil
+	case json.Number:
+		if strings.ContainsAny(t.String(), ".eE") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: t.String()}, nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: t.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(t)}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// copyYAMLComments copies the comments of the nodes of src to the nodes of dst at the same keys and indexes.
+func copyYAMLComments(dst, src *yaml.Node) {
+	if src.Kind == yaml.AliasNode {
+		src = src.Alias
+	}
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+
+	switch {
+	case dst.Kind == yaml.DocumentNode && src.Kind == yaml.DocumentNode:
+		if len(dst.Content) > 0 && len(src.Content) > 0 {
+			copyYAMLComments(dst.Content[0], src.Content[0])
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		for i := 0; i < len(dst.Content) && i < len(src.Content); i++ {
+			copyYAMLComments(dst.Content[i], src.Content[i])
+		}
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			for j := 0; j+1 < len(src.Content); j += 2 {
+				if src.Content[j].Value == dst.Content[i].Value {
+					copyYAMLComments(dst.Content[i], src.Content[j])
+					copyYAMLComments(dst.Content[i+1], src.Content[j+1])
+					break
+				}
+			}
+		}
+	}
+}
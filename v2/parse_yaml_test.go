@@ -0,0 +1,295 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicyYAML_SameAsJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonFile string
+		yamlFile string
+	}{
+		{"policy", "test_data/parse_policy/policy_full.json", "test_data/parse_policy/policy_full.yaml"},
+		{"array", "test_data/parse_policy/policy_array_full.json", "test_data/parse_policy/policy_array_full.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			want, err := ParsePolicyFile(tt.jsonFile, mustReadFile(t, tt.jsonFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Act
+			got, err := ParsePolicyFile(tt.yamlFile, mustReadFile(t, tt.yamlFile))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("want %+v, but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestParsePolicyYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Policy
+		wantErr string
+	}{
+		{
+			name: "flow style and timestamp",
+			data: "Version: 1\nPolicyID: p1\nStatements:\n  - {Effect: Allow, Resource: res:::doc, Actions: [act:::doc:read], Conditions: {AtLeastOne: {prop:::doc:created: {StringEqual: 2024-01-01}}}}\n",
+			want: Policy{Version: 1, PolicyID: "p1", Statements: []Statement{
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}, Conditions: &Condition{
+					AtLeastOne: map[string]Comparator{"prop:::doc:created": {StringEqual: ptr("2024-01-01")}},
+				}},
+			}},
+		},
+		{
+			name: "anchor",
+			data: "PolicyID: p1\nStatements:\n  - Effect: Allow\n    Resource: res:::doc\n    Actions: &actions [act:::doc:read]\n  - Effect: Deny\n    Resource: res:::file\n    Actions: *actions\n",
+			want: Policy{PolicyID: "p1", Statements: []Statement{
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+				{Effect: "Deny", Resource: "res:::file", Actions: []string{"act:::doc:read"}},
+			}},
+		},
+		{
+			name:    "same error as JSON",
+			data:    "Version: one\n",
+			wantErr: "json: cannot unmarshal string into Go struct field Policy.Version of type int",
+		},
+		{
+			name:    "invalid expression",
+			data:    "Statements:\n  - Conditions:\n      AtLeastOne:\n        amount: {Expression: \"prop.amount <\"}\n",
+			wantErr: "invalid expression",
+		},
+		{
+			name:    "invalid YAML",
+			data:    "Version: [1\n",
+			wantErr: "yaml: line",
+		},
+		{
+			name:    "billion laughs",
+			data:    billionLaughsYAML(),
+			wantErr: "aliases expand to more than",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := ParsePolicyYAML([]byte(tt.data))
+
+			// Assert
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("want %q, but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %+v, but got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// billionLaughsYAML returns a small document with nested aliases, which expands to 10^9 nodes.
+func billionLaughsYAML() string {
+	var sb strings.Builder
+	sb.WriteString("PolicyID: p1\nLaughs:\n  l0: &l0 [lol, lol, lol, lol, lol, lol, lol, lol, lol, lol]\n")
+	for i := 1; i < 9; i++ {
+		fmt.Fprintf(&sb, "  l%d: &l%d [", i, i)
+		for j := 0; j < 10; j++ {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "*l%d", i-1)
+		}
+		sb.WriteString("]\n")
+	}
+	return sb.String()
+}
+
+func TestParsePolicyArrayYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantIDs []string
+	}{
+		{"array", "- PolicyID: p1\n- PolicyID: p2\n", []string{"p1", "p2"}},
+		{"documents", "PolicyID: p1\n---\n- PolicyID: p2\n- PolicyID: p3\n", []string{"p1", "p2", "p3"}},
+		{"empty", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := ParsePolicyArrayYAML([]byte(tt.data))
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if ids := policyIDs(got); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("want %v, but got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func TestMarshalPolicyYAML(t *testing.T) {
+	// Arrange
+	p := Policy{Version: 1, PolicyID: "p1", Statements: []Statement{
+		{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}, Conditions: &Condition{
+			MustHaveAll: map[string]Comparator{
+				"prop:::doc:amount": {Expression: mustCompileExpression(t, "prop.doc.amount < 100")},
+				"prop:::doc:public": {StringEqual: ptr("true")},
+			},
+		}},
+	}}
+
+	// Act
+	got, err := MarshalPolicyYAML(p)
+
+	// Assert
+	want := `Version: 1
+PolicyID: p1
+Statements:
+  - Effect: Allow
+    Resource: res:::doc
+    Actions:
+      - act:::doc:read
+    Conditions:
+      MustHaveAll:
+        prop:::doc:amount:
+          Expression: prop.doc.amount < 100
+        prop:::doc:public:
+          StringEqual: "true"
+`
+	if err != nil || string(got) != want {
+		t.Errorf("want %s, but got %s, %v", want, got, err)
+	}
+	if parsed, err := ParsePolicyYAML(got); err != nil || !reflect.DeepEqual(parsed, p) {
+		t.Errorf("want %+v, but got %+v, %v", p, parsed, err)
+	}
+}
+
+func TestFormatPolicyYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "comments are kept",
+			data: `# owned by the platform team
+- PolicyID: documents   # stable ID
+  Version: 1
+  Statements:
+    # anyone can read
+    - Resource: res:::doc
+      Effect: Allow
+      Actions: [act:::doc:read]
+      Conditions: ~
+`,
+			want: `# owned by the platform team
+- Version: 1
+  PolicyID: documents # stable ID
+  Statements:
+    # anyone can read
+    - Effect: Allow
+      Resource: res:::doc
+      Actions:
+        - act:::doc:read
+`,
+		},
+		{
+			name: "documents",
+			data: "PolicyID: p1\n---\nPolicyID: p2\n",
+			want: "Version: 0\nPolicyID: p1\n---\nVersion: 0\nPolicyID: p2\n",
+		},
+		{
+			name: "formatted",
+			data: string(mustReadFile(t, "test_data/parse_policy/policy_full.yaml")),
+			want: string(mustReadFile(t, "test_data/parse_policy/policy_full.yaml")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := FormatPolicyYAML([]byte(tt.data))
+
+			// Assert
+			if err != nil || string(got) != tt.want {
+				t.Errorf("want %s, but got %s, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestFormatPolicyYAML_Invalid(t *testing.T) {
+	// Act
+	_, err := FormatPolicyYAML([]byte("Statements: {Effect: Allow}\n"))
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+}
+
+func TestParsePolicyFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		wantIDs []string
+		wantErr bool
+	}{
+		{"JSON array", "policies.json", `[{"PolicyID": "p1"}, {"PolicyID": "p2"}]`, []string{"p1", "p2"}, false},
+		{"JSON policy", "policy.json", ` {"PolicyID": "p1"}`, []string{"p1"}, false},
+		{"empty JSON", "policies.json", "", []string{}, false},
+		{"YAML", "policies.yaml", "- PolicyID: p1\n", []string{"p1"}, false},
+		{"YML policy", "policy.YML", "PolicyID: p1\n", []string{"p1"}, false},
+		{"YAML is not JSON", "policy.json", "PolicyID: p1\n", []string{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := ParsePolicyFile(tt.file, []byte(tt.data))
+
+			// Assert
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, but got %v", tt.wantErr, err)
+			}
+			if ids := policyIDs(got); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("want %v, but got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func mustReadFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,425 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FilterOp is the operator of a Filter.
+type FilterOp string
+
+const (
+	FilterTrue           FilterOp = "true"
+	FilterFalse          FilterOp = "false"
+	FilterAnd            FilterOp = "&&"
+	FilterOr             FilterOp = "||"
+	FilterNot            FilterOp = "!"
+	FilterEqual          FilterOp = "=="
+	FilterNotEqual       FilterOp = "!="
+	FilterLess           FilterOp = "<"
+	FilterLessOrEqual    FilterOp = "<="
+	FilterGreater        FilterOp = ">"
+	FilterGreaterOrEqual FilterOp = ">="
+	FilterIn             FilterOp = "in"
+	FilterStartsWith     FilterOp = "startsWith"
+	FilterEndsWith       FilterOp = "endsWith"
+	FilterContains       FilterOp = "contains"
+)
+
+// Filter is a boolean expression over resource properties, the result of PartialEvaluate.
+//
+//   - FilterTrue and FilterFalse are constants.
+//   - FilterAnd, FilterOr and FilterNot combine the Operands.
+//   - FilterIn is true when the Property is one of the Values.
+//   - Every other operator compares the Property with the Value.
+//
+// Property is a resource property key, e.g. "prop:::doc:owner".
+// Value and Values are string, int, float64 or bool.
+type Filter struct {
+	Op       FilterOp
+	Property string
+	Value    interface{}
+	Values   []interface{}
+	Operands []*Filter
+}
+
+var errNoUserPropertyGetter = errors.New("no user property getter")
+
+// PartialEvaluate reduces the statements for the resource and action to a filter over the resource properties.
+// A resource is allowed when its properties match the filter, so the filter can be used to query
+// only the allowed resources, e.g. with Filter.ToSQL, or rendered as Go code with Filter.ToGoExpr.
+//
+// All resource properties are unknown, the properties set on the validator are not used.
+// User properties and system values are read and replaced by their values.
+// A ValidationFunc cannot be reduced and is an error, so is an Expression that uses a resource property
+// in a way that cannot be expressed as a Filter, e.g. lower(prop.doc.owner) == "a".
+// The ValidationOverrider cannot be reduced either, and post validators are not part of the filter,
+// they have to be checked on the resources that match the filter.
+//
+// A comparator matches a missing property as its zero value, e.g. StringEqual "" matches a resource
+// without the property. The filter does not, so a query may need to take care of missing values.
+func (pv *policyValidator) PartialEvaluate() (*Filter, error) {
+	if pv.Err != nil {
+		return nil, pv.Err
+	}
+	if pv.ValidationOverrider != nil {
+		return nil, errors.New("cannot partially evaluate with a validation overrider")
+	}
+
+	if err := checkValidPolicies(pv.Policies); err != nil {
+		return nil, err
+	}
+
+	var allow, deny []*Filter
+	for _, stmt := range pv.filterWithResourceAndAction(pv.resource) {
+		filter, err := pv.partialStatement(stmt.Statement)
+		if err != nil {
+			return nil, err
+		}
+		if stmt.Effect == statementEffectDeny {
+			deny = append(deny, filter)
+		} else {
+			allow = append(allow, filter)
+		}
+	}
+
+	// Rule 1, 2 and 3: at least one "Allow" statement is matched and no "Deny" statement is matched.
+	return newAndFilter(newOrFilter(allow...), newNotFilter(newOrFilter(deny...))), nil
+}
+
+func (pv *policyValidator) partialStatement(stmt Statement) (*Filter, error) {
+	// Rule 4: If there are no conditions, then the statement is considered matched.
+	if stmt.Conditions == nil {
+		return &Filter{Op: FilterTrue}, nil
+	}
+
+	var atLeastOne, mustHaveAll []*Filter
+	for _, key := range sortedKeys(stmt.Conditions.AtLeastOne) {
+		filter, err := pv.partialComparator(stmt.Conditions.AtLeastOne[key], key)
+		if err != nil {
+			return nil, err
+		}
+		atLeastOne = append(atLeastOne, filter)
+	}
+	for _, key := range sortedKeys(stmt.Conditions.MustHaveAll) {
+		filter, err := pv.partialComparator(stmt.Conditions.MustHaveAll[key], key)
+		if err != nil {
+			return nil, err
+		}
+		mustHaveAll = append(mustHaveAll, filter)
+	}
+
+	// Rule 5: a quantifier without conditions is matched.
+	atLeastOneFilter := &Filter{Op: FilterTrue}
+	if len(atLeastOne) > 0 {
+		atLeastOneFilter = newOrFilter(atLeastOne...)
+	}
+	// Rule 6: AtLeastOne and MustHaveAll are both matched.
+	return newAndFilter(atLeastOneFilter, newAndFilter(mustHaveAll...)), nil
+}
+
+func (pv *policyValidator) partialComparator(comparator Comparator, key string) (*Filter, error) {
+	var filters []*Filter
+	equal := func(value interface{}) {
+		filters = append(filters, &Filter{Op: FilterEqual, Property: key, Value: value})
+	}
+	in := func(values []interface{}) {
+		filters = append(filters, &Filter{Op: FilterIn, Property: key, Values: values})
+	}
+
+	if comparator.StringIn != nil {
+		in(toInterfaces(*comparator.StringIn))
+	}
+	if comparator.StringEqual != nil {
+		equal(*comparator.StringEqual)
+	}
+	if comparator.StringPrefix != nil {
+		filters = append(filters, &Filter{Op: FilterStartsWith, Property: key, Value: *comparator.StringPrefix})
+	}
+	if comparator.IntegerIn != nil {
+		in(toInterfaces(*comparator.IntegerIn))
+	}
+	if comparator.IntegerEqual != nil {
+		equal(*comparator.IntegerEqual)
+	}
+	if comparator.FloatIn != nil {
+		in(toInterfaces(*comparator.FloatIn))
+	}
+	if comparator.FloatEqual != nil {
+		equal(*comparator.FloatEqual)
+	}
+	if comparator.BooleanEqual != nil {
+		equal(*comparator.BooleanEqual)
+	}
+	if comparator.UserPropEqual != nil {
+		if pv.UserPropertyGetter == nil {
+			return nil, fmt.Errorf("cannot read %s: %w", *comparator.UserPropEqual, errNoUserPropertyGetter)
+		}
+		equal(pv.UserPropertyGetter.GetUserProperty(*comparator.UserPropEqual))
+	}
+	if comparator.Expression != nil {
+		filter, err := pv.newExpressionEnv(Property{}).partial(comparator.Expression.root)
+		if err != nil {
+			return nil, fmt.Errorf("cannot partially evaluate expression %q: %w", comparator.Expression, err)
+		}
+		filters = append(filters, filter)
+	}
+	if comparator.ValidationFunc != nil {
+		return nil, fmt.Errorf("cannot partially evaluate validation function %s of %s", comparator.ValidationFunc.Function, key)
+	}
+
+	return newAndFilter(filters...), nil
+}
+
+// partial reduces a boolean expression to a filter, every part without resource properties is evaluated.
+func (env *exprEnv) partial(node exprNode) (*Filter, error) {
+	if !hasPropertyReference(node) {
+		env.budget = maxExpressionCost
+		v, err := env.eval(node)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("want boolean, but got %s", valueTypeName(v))
+		}
+		return newConstantFilter(b), nil
+	}
+
+	switch n := node.(type) {
+	case *refNode:
+		// A boolean property on its own, e.g. prop.doc.public
+		return &Filter{Op: FilterEqual, Property: n.key(), Value: true}, nil
+
+	case *unaryNode:
+		if n.op == "!" {
+			x, err := env.partial(n.x)
+			if err != nil {
+				return nil, err
+			}
+			return newNotFilter(x), nil
+		}
+
+	case *binaryNode:
+		switch n.op {
+		case "&&", "||":
+			x, err := env.partial(n.x)
+			if err != nil {
+				return nil, err
+			}
+			y, err := env.partial(n.y)
+			if err != nil {
+				return nil, err
+			}
+			if n.op == "&&" {
+				return newAndFilter(x, y), nil
+			}
+			return newOrFilter(x, y), nil
+
+		case "in":
+			ref, ok := propertyRefNode(n.x)
+			if !ok || hasPropertyReference(n.y) {
+				break
+			}
+			v, err := env.evalConstant(n.y)
+			if err != nil {
+				return nil, err
+			}
+			return &Filter{Op: FilterIn, Property: ref.key(), Values: v.([]interface{})}, nil
+
+		default:
+			op := FilterOp(n.op)
+			prop, ok := propertyRefNode(n.x)
+			other := n.y
+			if !ok {
+				prop, ok = propertyRefNode(n.y)
+				other = n.x
+				op = flipFilterOp(op)
+			}
+			if !ok || hasPropertyReference(other) {
+				break
+			}
+			v, err := env.evalConstant(other)
+			if err != nil {
+				return nil, err
+			}
+			return &Filter{Op: op, Property: prop.key(), Value: v}, nil
+		}
+
+	case *callNode:
+		op := FilterOp(n.fn)
+		if op != FilterStartsWith && op != FilterEndsWith && op != FilterContains {
+			break
+		}
+		prop, ok := propertyRefNode(n.args[0])
+		if !ok || hasPropertyReference(n.args[1]) {
+			break
+		}
+		v, err := env.evalConstant(n.args[1])
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Op: op, Property: prop.key(), Value: v}, nil
+	}
+
+	return nil, exprErrorf(node, "cannot reduce an expression with a resource property to a filter")
+}
+
+func (env *exprEnv) evalConstant(node exprNode) (interface{}, error) {
+	env.budget = maxExpressionCost
+	return env.eval(node)
+}
+
+func propertyRefNode(node exprNode) (*refNode, bool) {
+	ref, ok := node.(*refNode)
+	return ref, ok && ref.namespace == namespaceProp
+}
+
+func hasPropertyReference(node exprNode) bool {
+	found := false
+	walkExprNode(node, func(n exprNode) {
+		if ref, ok := n.(*refNode); ok && ref.namespace == namespaceProp {
+			found = true
+		}
+	})
+	return found
+}
+
+func flipFilterOp(op FilterOp) FilterOp {
+	switch op {
+	case FilterLess:
+		return FilterGreater
+	case FilterLessOrEqual:
+		return FilterGreaterOrEqual
+	case FilterGreater:
+		return FilterLess
+	case FilterGreaterOrEqual:
+		return FilterLessOrEqual
+	default:
+		return op
+	}
+}
+
+// ----------------------------------------------
+// Filter constructors, with simplification
+// ----------------------------------------------
+
+func newConstantFilter(b bool) *Filter {
+	if b {
+		return &Filter{Op: FilterTrue}
+	}
+	return &Filter{Op: FilterFalse}
+}
+
+// newAndFilter returns the conjunction of the filters, an empty conjunction is true.
+func newAndFilter(filters ...*Filter) *Filter {
+	return newJunctionFilter(FilterAnd, FilterTrue, FilterFalse, filters)
+}
+
+// newOrFilter returns the disjunction of the filters, an empty disjunction is false.
+func newOrFilter(filters ...*Filter) *Filter {
+	return newJunctionFilter(FilterOr, FilterFalse, FilterTrue, filters)
+}
+
+func newJunctionFilter(op, identity, absorbing FilterOp, filters []*Filter) *Filter {
+	var operands []*Filter
+	for _, f := range filters {
+		switch f.Op {
+		case identity:
+			continue
+		case absorbing:
+			return f
+		case op:
+			operands = append(operands, f.Operands...)
+		default:
+			operands = append(operands, f)
+		}
+	}
+
+	switch len(operands) {
+	case 0:
+		return &Filter{Op: identity}
+	case 1:
+		return operands[0]
+	default:
+		return &Filter{Op: op, Operands: operands}
+	}
+}
+
+func newNotFilter(f *Filter) *Filter {
+	switch f.Op {
+	case FilterTrue:
+		return &Filter{Op: FilterFalse}
+	case FilterFalse:
+		return &Filter{Op: FilterTrue}
+	case FilterNot:
+		return f.Operands[0]
+	default:
+		return &Filter{Op: FilterNot, Operands: []*Filter{f}}
+	}
+}
+
+// String returns the filter in the syntax of an Expression.
+func (f *Filter) String() string {
+	switch f.Op {
+	case FilterTrue, FilterFalse:
+		return string(f.Op)
+
+	case FilterAnd, FilterOr:
+		parts := make([]string, len(f.Operands))
+		for i, operand := range f.Operands {
+			parts[i] = operand.String()
+		}
+		return "(" + strings.Join(parts, " "+string(f.Op)+" ") + ")"
+
+	case FilterNot:
+		return "!" + f.Operands[0].String()
+
+	case FilterIn:
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = formatFilterValue(v)
+		}
+		return "(" + propertyReference(f.Property) + " in [" + strings.Join(values, ", ") + "])"
+
+	case FilterStartsWith, FilterEndsWith, FilterContains:
+		return string(f.Op) + "(" + propertyReference(f.Property) + ", " + formatFilterValue(f.Value) + ")"
+
+	default:
+		return "(" + propertyReference(f.Property) + " " + string(f.Op) + " " + formatFilterValue(f.Value) + ")"
+	}
+}
+
+// propertyReference turns a key such as "prop:::doc:owner" into the reference prop.doc.owner.
+func propertyReference(key string) string {
+	return namespaceProp + "." + strings.ReplaceAll(strings.TrimPrefix(key, propertyPrefix), propertySeparator, ".")
+}
+
+func formatFilterValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x)
+	case float64:
+		s := strconv.FormatFloat(x, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// ----------------------------------------------
+// Helper functions
+// ----------------------------------------------
+
+func toInterfaces[T any](list []T) []interface{} {
+	values := make([]interface{}, len(list))
+	for i, v := range list {
+		values[i] = v
+	}
+	return values
+}
@@ -0,0 +1,340 @@
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustCompileExpression(t *testing.T, source string) *Expression {
+	t.Helper()
+	expr, err := CompileExpression(source)
+	if err != nil {
+		t.Fatalf("compile %q: %v", source, err)
+	}
+	return expr
+}
+
+func partialEvaluationPolicies(t *testing.T) []Policy {
+	return []Policy{
+		{
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						AtLeastOne: map[string]Comparator{
+							"prop:::doc:owner":  {UserPropEqual: ptr("user:::id")},
+							"prop:::doc:public": {BooleanEqual: ptr(true)},
+						},
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:status": {StringIn: &[]string{"draft", "published"}},
+						},
+					},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:level": {Expression: mustCompileExpression(t, `1 < prop.doc.level && sys.action == "act:::doc:read"`)},
+						},
+					},
+				},
+				{
+					Effect:   statementEffectDeny,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:locked": {Expression: mustCompileExpression(t, `prop.doc.locked || startsWith(prop.doc.path, "/private/")`)},
+						},
+					},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:list"},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:write"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:owner": {Expression: mustCompileExpression(t, `user.role == "admin"`)},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPartialEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		role   string
+		want   string
+	}{
+		{
+			name:   "conditions of allow and deny statements",
+			action: "act:::doc:read",
+			want: `(((((prop.doc.owner == "u1") || (prop.doc.public == true)) && (prop.doc.status in ["draft", "published"])) || (prop.doc.level > 1)) && ` +
+				`!((prop.doc.locked == true) || startsWith(prop.doc.path, "/private/")))`,
+		},
+		{
+			name:   "statement without conditions",
+			action: "act:::doc:list",
+			want:   "true",
+		},
+		{
+			name:   "no matching statement",
+			action: "act:::doc:delete",
+			want:   "false",
+		},
+		{
+			name:   "expression without resource properties, matched",
+			action: "act:::doc:write",
+			role:   "admin",
+			want:   "true",
+		},
+		{
+			name:   "expression without resource properties, not matched",
+			action: "act:::doc:write",
+			role:   "guest",
+			want:   "false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = partialEvaluationPolicies(t)
+			pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1", "user:::role": tt.role}}
+			pv.SetResource("res:::doc")
+			pv.SetAction(tt.action)
+
+			// Act
+			filter, err := pv.PartialEvaluate()
+
+			// Assert
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if filter.String() != tt.want {
+				t.Errorf("got %s, but want %s", filter, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialEvaluate_MatchesIsAccessAllowed(t *testing.T) {
+	// Arrange
+	policies := partialEvaluationPolicies(t)
+	userGetter := &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+	pv := New()
+	pv.Policies = policies
+	pv.UserPropertyGetter = userGetter
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+	filter, err := pv.PartialEvaluate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, owner := range []string{"u1", "u2"} {
+		for _, public := range []bool{true, false} {
+			for _, status := range []string{"draft", "archived"} {
+				for _, level := range []int{0, 5} {
+					for _, path := range []string{"/private/a", "/public/a"} {
+						prop := Property{
+							String:  map[string]string{"prop:::doc:owner": owner, "prop:::doc:status": status, "prop:::doc:path": path},
+							Integer: map[string]int{"prop:::doc:level": level},
+							Boolean: map[string]bool{"prop:::doc:public": public, "prop:::doc:locked": false},
+						}
+						row := New()
+						row.Policies = policies
+						row.UserPropertyGetter = userGetter
+						row.SetResource("res:::doc")
+						row.SetAction("act:::doc:read")
+						row.AddProperties(prop)
+
+						// Act
+						want, _ := row.IsAccessAllowed()
+						got := matchFilter(t, filter, prop)
+
+						// Assert
+						if got != want {
+							t.Errorf("%v: filter got %v, but IsAccessAllowed got %v", prop, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// matchFilter evaluates the filter against the properties, the way a database would.
+func matchFilter(t *testing.T, f *Filter, prop Property) bool {
+	switch f.Op {
+	case FilterTrue:
+		return true
+	case FilterFalse:
+		return false
+	case FilterAnd:
+		for _, operand := range f.Operands {
+			if !matchFilter(t, operand, prop) {
+				return false
+			}
+		}
+		return true
+	case FilterOr:
+		for _, operand := range f.Operands {
+			if matchFilter(t, operand, prop) {
+				return true
+			}
+		}
+		return false
+	case FilterNot:
+		return !matchFilter(t, f.Operands[0], prop)
+	}
+
+	v, ok := lookupProperty(prop, f.Property)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case FilterIn:
+		for _, value := range f.Values {
+			if cmp, err := compareValues(v, value); err == nil && cmp == 0 {
+				return true
+			}
+		}
+		return false
+	case FilterStartsWith:
+		return strings.HasPrefix(v.(string), f.Value.(string))
+	case FilterEndsWith:
+		return strings.HasSuffix(v.(string), f.Value.(string))
+	case FilterContains:
+		return strings.Contains(v.(string), f.Value.(string))
+	}
+
+	cmp, err := compareValues(v, f.Value)
+	if err != nil {
+		t.Fatalf("compare %v: %v", f, err)
+	}
+	switch f.Op {
+	case FilterEqual:
+		return cmp == 0
+	case FilterNotEqual:
+		return cmp != 0
+	case FilterLess:
+		return cmp < 0
+	case FilterLessOrEqual:
+		return cmp <= 0
+	case FilterGreater:
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func TestPartialEvaluate_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparator Comparator
+		setup      func(pv *policyValidator)
+	}{
+		{
+			name:       "validation function",
+			comparator: Comparator{ValidationFunc: &ValidationFunc{Function: "fn", StringArg: ptr("a")}},
+		},
+		{
+			name:       "expression with a function of a property",
+			comparator: Comparator{Expression: mustCompileExpression(t, `lower(prop.doc.owner) == "a"`)},
+		},
+		{
+			name:       "expression comparing two properties",
+			comparator: Comparator{Expression: mustCompileExpression(t, `prop.doc.owner == prop.doc.creator`)},
+		},
+		{
+			name:       "user property without getter",
+			comparator: Comparator{UserPropEqual: ptr("user:::id")},
+		},
+		{
+			name:  "validation overrider",
+			setup: func(pv *policyValidator) { pv.ValidationOverrider = &MockValidationOverrider{} },
+		},
+		{
+			name:  "validator with Error",
+			setup: func(pv *policyValidator) { pv.SetError(errors.New("error")) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = []Policy{
+				{
+					Statements: []Statement{
+						{
+							Effect:   statementEffectAllow,
+							Resource: "res:::doc",
+							Actions:  []string{"act:::doc:read"},
+							Conditions: &Condition{
+								MustHaveAll: map[string]Comparator{"prop:::doc:owner": tt.comparator},
+							},
+						},
+					},
+				},
+			}
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+			if tt.setup != nil {
+				tt.setup(pv)
+			}
+
+			// Act
+			_, err := pv.PartialEvaluate()
+
+			// Assert
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
+
+func TestFilterSimplification(t *testing.T) {
+	a := &Filter{Op: FilterEqual, Property: "prop:::a", Value: 1}
+	b := &Filter{Op: FilterEqual, Property: "prop:::b", Value: 2}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   string
+	}{
+		{"empty and", newAndFilter(), "true"},
+		{"empty or", newOrFilter(), "false"},
+		{"and with true", newAndFilter(a, newConstantFilter(true)), "(prop.a == 1)"},
+		{"and with false", newAndFilter(a, newConstantFilter(false)), "false"},
+		{"or with true", newOrFilter(a, newConstantFilter(true)), "true"},
+		{"nested and", newAndFilter(a, newAndFilter(a, b)), "((prop.a == 1) && (prop.a == 1) && (prop.b == 2))"},
+		{"double not", newNotFilter(newNotFilter(a)), "(prop.a == 1)"},
+		{"not true", newNotFilter(newConstantFilter(true)), "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("got %s, but want %s", got, tt.want)
+			}
+		})
+	}
+}
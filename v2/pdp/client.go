@@ -0,0 +1,330 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golfz/policy/v2"
+)
+
+const (
+	defaultClientTimeout  = 2 * time.Second
+	defaultClientRetries  = 2
+	defaultRetryBackoff   = 100 * time.Millisecond
+	defaultCacheMaxLength = 10000
+)
+
+// User is a user that can be sent to the server, it is the UserPropertyGetter to use with a Client.
+type User struct {
+	// Principals selects the policies attached to any of them. Without principals, all policies are used
+	// if the server allows it with Server.AllPoliciesWithoutPrincipals, otherwise the request is rejected.
+	Principals []string
+	// Properties is read like policy.NewDefaultUserPropertyGetter, e.g. "user:::org:id" is {"org": {"id": ...}}.
+	Properties map[string]interface{}
+}
+
+func (u *User) GetUserProperty(key string) string {
+	var current interface{} = u.Properties
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(key), "user:::"), ":") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		if current, ok = m[part]; !ok {
+			return ""
+		}
+	}
+	return fmt.Sprint(current)
+}
+
+// StatusError is a response of the server with an error status.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("policy decision point: %d %s", e.StatusCode, e.Message)
+}
+
+// Client asks a policy decision point server for decisions, it is a policy.Authorizer and a policy.Explainer.
+// The user must be a *User, or nil.
+type Client struct {
+	// HTTPClient sends the requests, the default is http.DefaultClient.
+	HTTPClient *http.Client
+	// Timeout limits each attempt of a request, the default is 2 seconds.
+	Timeout time.Duration
+	// Retries is the number of retries of a request that failed with a network error or a 429, 502, 503 or 504 status.
+	Retries int
+	// RetryBackoff is the wait before the first retry, doubled for each next retry.
+	RetryBackoff time.Duration
+	// CacheTTL is how long a decision is cached by user and resource, decisions are not cached if it is 0.
+	CacheTTL time.Duration
+	// CacheMaxLength limits the number of cached decisions, the default is 10000.
+	CacheMaxLength int
+	// Fallback makes the decision when the server cannot be reached, e.g. a validator with a local copy of the policies.
+	// Errors are returned if it is nil. Decisions of the fallback are not cached.
+	Fallback policy.Authorizer
+
+	baseURL string
+	now     func() time.Time
+	mu      sync.Mutex
+	cache   map[string]cachedDecision
+}
+
+type cachedDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+// NewClient creates a client of the server at the base URL, e.g. "http://pdp:8080".
+func NewClient(baseURL string) *Client {
+	return &Client{
+		Timeout:        defaultClientTimeout,
+		Retries:        defaultClientRetries,
+		RetryBackoff:   defaultRetryBackoff,
+		CacheMaxLength: defaultCacheMaxLength,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		now:            time.Now,
+		cache:          make(map[string]cachedDecision),
+	}
+}
+
+// Authorize asks the server if the user is allowed to perform the action on the resource.
+func (c *Client) Authorize(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+	principals, properties, err := splitUser(user)
+	if err != nil {
+		return false, err
+	}
+	req := AuthorizeRequest{Principals: principals, User: properties, Resource: res}
+
+	key, err := cacheKey(req)
+	if err != nil {
+		return false, err
+	}
+	if allowed, ok := c.cached(key); ok {
+		return allowed, nil
+	}
+
+	var resp AuthorizeResponse
+	if err := c.post(ctx, "/v1/authorize", req, &resp); err != nil {
+		if c.useFallback(ctx, err) {
+			return c.Fallback.Authorize(ctx, user, res)
+		}
+		return false, err
+	}
+	c.store(key, resp.Allowed)
+	return resp.Allowed, nil
+}
+
+// AuthorizeBatch asks the server for the decision of each resource, the results are in the same order as the resources.
+func (c *Client) AuthorizeBatch(ctx context.Context, user policy.UserPropertyGetter, resources []policy.Resource) ([]policy.BatchResult, error) {
+	principals, properties, err := splitUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp BatchAuthorizeResponse
+	err = c.post(ctx, "/v1/authorize/batch", BatchAuthorizeRequest{Principals: principals, User: properties, Resources: resources}, &resp)
+	if err != nil {
+		if !c.useFallback(ctx, err) {
+			return nil, err
+		}
+		results := make([]policy.BatchResult, len(resources))
+		for i, res := range resources {
+			results[i].Allowed, results[i].Err = c.Fallback.Authorize(ctx, user, res)
+		}
+		return results, nil
+	}
+	if len(resp.Results) != len(resources) {
+		return nil, fmt.Errorf("policy decision point: %d results for %d resources", len(resp.Results), len(resources))
+	}
+
+	results := make([]policy.BatchResult, len(resp.Results))
+	for i, result := range resp.Results {
+		results[i].Allowed = result.Allowed
+		if result.Error != "" {
+			results[i].Err = errors.New(result.Error)
+		}
+	}
+	return results, nil
+}
+
+// Explain asks the server to explain the decision, the fallback is used if it is also a policy.Explainer.
+func (c *Client) Explain(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (policy.Explanation, error) {
+	principals, properties, err := splitUser(user)
+	if err != nil {
+		return policy.Explanation{}, err
+	}
+
+	var resp ExplainResponse
+	if err := c.post(ctx, "/v1/explain", AuthorizeRequest{Principals: principals, User: properties, Resource: res}, &resp); err != nil {
+		if explainer, ok := c.Fallback.(policy.Explainer); ok && c.useFallback(ctx, err) {
+			return explainer.Explain(ctx, user, res)
+		}
+		return policy.Explanation{}, err
+	}
+	return policy.Explanation{Allowed: resp.Allowed, Reason: resp.Reason, Matched: resp.Matched}, nil
+}
+
+func splitUser(user policy.UserPropertyGetter) ([]string, json.RawMessage, error) {
+	if user == nil {
+		return nil, nil, nil
+	}
+	u, ok := user.(*User)
+	if !ok {
+		return nil, nil, fmt.Errorf("user of type %T cannot be sent, use *pdp.User", user)
+	}
+	if u == nil {
+		return nil, nil, nil
+	}
+	if u.Properties == nil {
+		return u.Principals, nil, nil
+	}
+	properties, err := json.Marshal(u.Properties)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u.Principals, properties, nil
+}
+
+func cacheKey(req AuthorizeRequest) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *Client) cached(key string) (bool, bool) {
+	if c.CacheTTL <= 0 {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	decision, ok := c.cache[key]
+	if !ok || !c.now().Before(decision.expires) {
+		return false, false
+	}
+	return decision.allowed, true
+}
+
+func (c *Client) store(key string, allowed bool) {
+	if c.CacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.CacheMaxLength > 0 && len(c.cache) >= c.CacheMaxLength {
+		for k, decision := range c.cache {
+			if !now.Before(decision.expires) {
+				delete(c.cache, k)
+			}
+		}
+		// still full of fresh decisions, start over rather than tracking the oldest
+		if len(c.cache) >= c.CacheMaxLength {
+			c.cache = make(map[string]cachedDecision)
+		}
+	}
+	c.cache[key] = cachedDecision{allowed: allowed, expires: now.Add(c.CacheTTL)}
+}
+
+// post sends the request with retries, and decodes the response into resp.
+func (c *Client) post(ctx context.Context, path string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = c.postOnce(ctx, path, body, resp)
+		if err == nil || attempt >= c.Retries || !isRetryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) postOnce(ctx context.Context, path string, body []byte, resp interface{}) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		b, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxRequestBytes))
+		if json.Unmarshal(b, &errResp) != nil || errResp.Error == "" {
+			errResp.Error = http.StatusText(httpResp.StatusCode)
+		}
+		return &StatusError{StatusCode: httpResp.StatusCode, Message: errResp.Error}
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// isRetryable reports whether the request may succeed when it is sent again.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// the request itself is invalid
+	var syntaxErr *json.SyntaxError
+	return !errors.As(err, &syntaxErr)
+}
+
+// useFallback reports whether the fallback makes the decision, because the server cannot make it.
+func (c *Client) useFallback(ctx context.Context, err error) bool {
+	if c.Fallback == nil || ctx.Err() != nil {
+		return false
+	}
+	return isUnavailable(err)
+}
+
+func isUnavailable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
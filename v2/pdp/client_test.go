@@ -0,0 +1,291 @@
+package pdp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golfz/policy/v2"
+)
+
+func docRes(action, owner string) policy.Resource {
+	return policy.Resource{
+		Resource:   "res:::doc",
+		Action:     action,
+		Properties: policy.Property{String: map[string]string{"prop:::doc:owner": owner}},
+	}
+}
+
+// countingHandler counts the requests, and answers the first failures requests with the status.
+type countingHandler struct {
+	next     http.Handler
+	requests atomic.Int32
+	failures int32
+	status   int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.requests.Add(1) <= h.failures {
+		writeError(w, h.status, errors.New(http.StatusText(h.status)))
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+func newTestClient(t *testing.T, failures int32, status int) (*Client, *countingHandler) {
+	t.Helper()
+	handler := &countingHandler{next: newTestServer(t, newTestStore(t)), failures: failures, status: status}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL + "/")
+	client.RetryBackoff = time.Millisecond
+	return client, handler
+}
+
+func TestClient_Authorize(t *testing.T) {
+	tests := []struct {
+		name string
+		user policy.UserPropertyGetter
+		res  policy.Resource
+		want bool
+	}{
+		{"owner", &User{Principals: []string{"role:::owner"}, Properties: map[string]interface{}{"id": "u1"}}, docRes("act:::doc:write", "u1"), true},
+		{"not owner", &User{Principals: []string{"role:::owner"}, Properties: map[string]interface{}{"id": "u2"}}, docRes("act:::doc:write", "u1"), false},
+		{"no user", nil, docRes("act:::doc:read", "u1"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			client, _ := newTestClient(t, 0, 0)
+			var authorizer policy.Authorizer = client
+
+			// Act
+			got, err := authorizer.Authorize(context.Background(), tt.user, tt.res)
+
+			// Assert
+			if err != nil || got != tt.want {
+				t.Errorf("want %v, but got %v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestClient_UnsupportedUser(t *testing.T) {
+	// Arrange
+	client, handler := newTestClient(t, 0, 0)
+	user := policy.NewDefaultUserPropertyGetter(`{"id": "u1"}`)
+
+	// Act
+	_, err := client.Authorize(context.Background(), user, docRes("act:::doc:read", "u1"))
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+	if n := handler.requests.Load(); n != 0 {
+		t.Errorf("want no request, but got %d", n)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		status       int
+		wantRequests int32
+		wantErr      bool
+	}{
+		{"retried until success", 2, http.StatusServiceUnavailable, 3, false},
+		{"too many failures", 5, http.StatusBadGateway, 3, true},
+		{"client error is not retried", 5, http.StatusBadRequest, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			client, handler := newTestClient(t, tt.failures, tt.status)
+
+			// Act
+			_, err := client.Authorize(context.Background(), nil, docRes("act:::doc:read", "u1"))
+
+			// Assert
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, but got %v", tt.wantErr, err)
+			}
+			var statusErr *StatusError
+			if tt.wantErr && (!errors.As(err, &statusErr) || statusErr.StatusCode != tt.status) {
+				t.Errorf("want StatusError %d, but got %v", tt.status, err)
+			}
+			if n := handler.requests.Load(); n != tt.wantRequests {
+				t.Errorf("want %d requests, but got %d", tt.wantRequests, n)
+			}
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	client := NewClient(server.URL)
+	client.Timeout = 10 * time.Millisecond
+	client.Retries = 0
+
+	// Act
+	_, err := client.Authorize(context.Background(), nil, docRes("act:::doc:read", "u1"))
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want %v, but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestClient_Cache(t *testing.T) {
+	// Arrange
+	client, handler := newTestClient(t, 0, 0)
+	client.CacheTTL = time.Minute
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.now = func() time.Time { return now }
+	ctx := context.Background()
+	u1 := &User{Properties: map[string]interface{}{"id": "u1"}}
+	u2 := &User{Properties: map[string]interface{}{"id": "u2"}}
+
+	// Act & Assert
+	_, _ = client.Authorize(ctx, u1, docRes("act:::doc:read", "u1"))
+	_, _ = client.Authorize(ctx, &User{Properties: map[string]interface{}{"id": "u1"}}, docRes("act:::doc:read", "u1"))
+	if n := handler.requests.Load(); n != 1 {
+		t.Errorf("same user and resource: want 1 request, but got %d", n)
+	}
+
+	_, _ = client.Authorize(ctx, u2, docRes("act:::doc:read", "u1"))
+	_, _ = client.Authorize(ctx, u1, docRes("act:::doc:write", "u1"))
+	if n := handler.requests.Load(); n != 3 {
+		t.Errorf("other user or resource: want 3 requests, but got %d", n)
+	}
+
+	now = now.Add(time.Minute)
+	_, _ = client.Authorize(ctx, u1, docRes("act:::doc:read", "u1"))
+	if n := handler.requests.Load(); n != 4 {
+		t.Errorf("expired decision: want 4 requests, but got %d", n)
+	}
+}
+
+func TestClient_CacheMaxLength(t *testing.T) {
+	// Arrange
+	client := NewClient("http://unused")
+	client.CacheTTL = time.Minute
+	client.CacheMaxLength = 2
+
+	// Act
+	client.store("a", true)
+	client.store("b", true)
+	client.store("c", true)
+
+	// Assert
+	if len(client.cache) > 2 {
+		t.Errorf("want at most 2 decisions, but got %d", len(client.cache))
+	}
+	if allowed, ok := client.cached("c"); !ok || !allowed {
+		t.Error("want the last decision cached")
+	}
+}
+
+func TestClient_Fallback(t *testing.T) {
+	// Arrange
+	client, _ := newTestClient(t, 100, http.StatusServiceUnavailable)
+	client.Retries = 0
+	var fallbackCalls int
+	client.Fallback = policy.AuthorizerFunc(func(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+		fallbackCalls++
+		return user.GetUserProperty("user:::id") == "u1", nil
+	})
+	user := &User{Properties: map[string]interface{}{"id": "u1"}}
+	ctx := context.Background()
+
+	// Act
+	allowed, err := client.Authorize(ctx, user, docRes("act:::doc:read", "u1"))
+	results, batchErr := client.AuthorizeBatch(ctx, user, []policy.Resource{docRes("act:::doc:read", "u1"), docRes("act:::doc:write", "u1")})
+
+	// Assert
+	if !allowed || err != nil {
+		t.Errorf("want true, but got %v, %v", allowed, err)
+	}
+	if batchErr != nil || len(results) != 2 {
+		t.Errorf("want 2 results, but got %v, %v", results, batchErr)
+	}
+	if fallbackCalls != 3 {
+		t.Errorf("want 3 fallback calls, but got %d", fallbackCalls)
+	}
+}
+
+func TestClient_FallbackNotUsedForClientErrors(t *testing.T) {
+	// Arrange
+	client, _ := newTestClient(t, 100, http.StatusBadRequest)
+	client.Fallback = policy.AuthorizerFunc(func(ctx context.Context, user policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+		return true, nil
+	})
+
+	// Act
+	allowed, err := client.Authorize(context.Background(), nil, docRes("act:::doc:read", "u1"))
+
+	// Assert
+	if allowed || err == nil {
+		t.Errorf("want false with error, but got %v, %v", allowed, err)
+	}
+}
+
+func TestClient_AuthorizeBatch(t *testing.T) {
+	// Arrange
+	client, _ := newTestClient(t, 0, 0)
+	user := &User{Principals: []string{"role:::owner"}, Properties: map[string]interface{}{"id": "u1"}}
+
+	// Act
+	results, err := client.AuthorizeBatch(context.Background(), user, []policy.Resource{docRes("act:::doc:write", "u1"), docRes("act:::doc:write", "u2")})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if len(results) != 2 || !results[0].Allowed || results[1].Allowed {
+		t.Errorf("want [true false], but got %v", results)
+	}
+}
+
+func TestClient_Explain(t *testing.T) {
+	// Arrange
+	client, _ := newTestClient(t, 0, 0)
+	var explainer policy.Explainer = client
+
+	// Act
+	got, err := explainer.Explain(context.Background(), &User{Properties: map[string]interface{}{"id": "u2"}}, docRes("act:::doc:write", "u1"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got.Allowed || got.Reason != policy.ReasonNoMatch {
+		t.Errorf("want denied with %s, but got %+v", policy.ReasonNoMatch, got)
+	}
+}
+
+func TestUser_GetUserProperty(t *testing.T) {
+	// Arrange
+	user := &User{Properties: map[string]interface{}{"id": "u1", "org": map[string]interface{}{"id": 7}}}
+
+	// Act & Assert
+	for key, want := range map[string]string{"user:::id": "u1", "user:::org:id": "7", "user:::missing": "", "user:::id:more": ""} {
+		if got := user.GetUserProperty(key); got != want {
+			t.Errorf("%s: want %q, but got %q", key, want, got)
+		}
+	}
+}
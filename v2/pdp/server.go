@@ -0,0 +1,297 @@
+// Package pdp is a policy decision point, an HTTP service that makes the decisions of the policies of a store
+// for services that cannot use the policy package directly.
+//
+// Endpoints:
+//
+//	POST /v1/authorize        AuthorizeRequest      -> AuthorizeResponse
+//	POST /v1/authorize/batch  BatchAuthorizeRequest -> BatchAuthorizeResponse
+//	POST /v1/explain          AuthorizeRequest      -> ExplainResponse
+//	GET  /healthz             200 while the server is running
+//	GET  /readyz              200 once the policies are loaded and the store is reachable, 503 otherwise
+package pdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golfz/policy/v2"
+)
+
+const maxRequestBytes = 1 << 20
+
+// Server answers the decision requests with the policies of the store.
+// The policies are cached, and reloaded by Refresh when the version of the store is changed.
+type Server struct {
+	// AllPoliciesWithoutPrincipals decides a request without Principals with all policies of the store.
+	// Such a request is rejected by default, so that a client that forgets the principals is not allowed
+	// by policies that are not attached to them.
+	AllPoliciesWithoutPrincipals bool
+
+	store policy.PolicyStore
+	mux   *http.ServeMux
+	ready atomic.Bool
+
+	mu       sync.RWMutex
+	version  int64
+	all      []policy.Policy
+	attached map[string][]policy.Policy
+}
+
+// NewServer creates a server and loads the policies of the store.
+func NewServer(ctx context.Context, store policy.PolicyStore) (*Server, error) {
+	s := &Server{
+		store: store,
+		mux:   http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /v1/authorize", s.handleAuthorize)
+	s.mux.HandleFunc("POST /v1/authorize/batch", s.handleBatchAuthorize)
+	s.mux.HandleFunc("POST /v1/explain", s.handleExplain)
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Refresh reloads the policies if the version of the store is changed.
+// The server is not ready while the store cannot be read, and keeps answering with the cached policies.
+func (s *Server) Refresh(ctx context.Context) error {
+	version, err := s.store.Version(ctx)
+	if err != nil {
+		s.ready.Store(false)
+		return fmt.Errorf("cannot get the version of the store: %w", err)
+	}
+
+	s.mu.RLock()
+	loaded := s.all != nil && version == s.version
+	s.mu.RUnlock()
+	if loaded {
+		s.ready.Store(true)
+		return nil
+	}
+
+	all, err := s.store.List(ctx)
+	if err != nil {
+		s.ready.Store(false)
+		return fmt.Errorf("cannot list the policies: %w", err)
+	}
+
+	s.mu.Lock()
+	s.version = version
+	s.all = all
+	s.attached = make(map[string][]policy.Policy)
+	s.mu.Unlock()
+	s.ready.Store(true)
+	return nil
+}
+
+// Watch calls Refresh every interval until the context is done, errors are passed to onError if it is not nil.
+func (s *Server) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// policies returns the policies of the principals, or all policies if there are none.
+// Only the principals with policies are cached, so that the cache is bounded by the attachments of the store.
+func (s *Server) policies(ctx context.Context, principals []string) ([]policy.Policy, error) {
+	s.mu.RLock()
+	if len(principals) == 0 {
+		defer s.mu.RUnlock()
+		return s.all, nil
+	}
+	version := s.version
+	var policies []policy.Policy
+	seen := make(map[string]bool)
+	var missing []string
+	for _, principal := range principals {
+		attached, ok := s.attached[principal]
+		if !ok {
+			missing = append(missing, principal)
+			continue
+		}
+		policies = appendUnseen(policies, seen, attached)
+	}
+	s.mu.RUnlock()
+
+	for _, principal := range missing {
+		attached, err := s.store.Attached(ctx, principal)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get policies of %s: %w", principal, err)
+		}
+		s.mu.Lock()
+		// a refresh may have replaced the cache while the store was read
+		if s.version == version && len(attached) > 0 {
+			s.attached[principal] = attached
+		}
+		s.mu.Unlock()
+		policies = appendUnseen(policies, seen, attached)
+	}
+	return policies, nil
+}
+
+func appendUnseen(policies []policy.Policy, seen map[string]bool, add []policy.Policy) []policy.Policy {
+	for _, p := range add {
+		if !seen[p.PolicyID] {
+			seen[p.PolicyID] = true
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	var req AuthorizeRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	policies, user, ok := s.prepare(w, r, req.Principals, req.User)
+	if !ok {
+		return
+	}
+	pv := policy.New()
+	pv.Policies = policies
+
+	allowed, err := pv.Authorize(r.Context(), user, req.Resource)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, AuthorizeResponse{Allowed: allowed})
+}
+
+func (s *Server) handleBatchAuthorize(w http.ResponseWriter, r *http.Request) {
+	var req BatchAuthorizeRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	policies, user, ok := s.prepare(w, r, req.Principals, req.User)
+	if !ok {
+		return
+	}
+	pv := policy.New()
+	pv.Policies = policies
+	pv.UserPropertyGetter = user
+
+	results, err := pv.IsAccessAllowedBatch(req.Resources)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	resp := BatchAuthorizeResponse{Results: make([]BatchResult, len(results))}
+	for i, result := range results {
+		resp.Results[i].Allowed = result.Allowed
+		if result.Err != nil {
+			resp.Results[i].Error = result.Err.Error()
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
+	var req AuthorizeRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	policies, user, ok := s.prepare(w, r, req.Principals, req.User)
+	if !ok {
+		return
+	}
+	pv := policy.New()
+	pv.Policies = policies
+
+	explanation, err := pv.Explain(r.Context(), user, req.Resource)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ExplainResponse{
+		Allowed:     explanation.Allowed,
+		Reason:      explanation.Reason,
+		Matched:     explanation.Matched,
+		Explanation: explanation.String(),
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"Status": "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"Status": "not ready"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"Status": "ready"})
+}
+
+// prepare returns the policies of the principals and the user, or writes the error response.
+func (s *Server) prepare(w http.ResponseWriter, r *http.Request, principals []string, userJSON json.RawMessage) ([]policy.Policy, policy.UserPropertyGetter, bool) {
+	user, err := newUser(userJSON)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+	if len(principals) == 0 && !s.AllPoliciesWithoutPrincipals {
+		writeError(w, http.StatusBadRequest, errors.New("Principals are required"))
+		return nil, nil, false
+	}
+	policies, err := s.policies(r.Context(), principals)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return nil, nil, false
+	}
+	return policies, user, true
+}
+
+func newUser(userJSON json.RawMessage) (policy.UserPropertyGetter, error) {
+	if len(userJSON) == 0 || string(userJSON) == "null" {
+		return policy.NewDefaultUserPropertyGetter("{}"), nil
+	}
+	var user map[string]interface{}
+	if err := json.Unmarshal(userJSON, &user); err != nil {
+		return nil, errors.New("User must be a JSON object")
+	}
+	return policy.NewDefaultUserPropertyGetter(string(userJSON)), nil
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,345 @@
+package pdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golfz/policy/v2"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func newTestStore(t *testing.T) policy.PolicyStore {
+	t.Helper()
+	ctx := context.Background()
+	store := policy.NewMemoryPolicyStore()
+	policies := []policy.Policy{
+		{
+			Version:  1,
+			PolicyID: "owners",
+			Statements: []policy.Statement{
+				{
+					Effect:   "Allow",
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read", "act:::doc:write"},
+					Conditions: &policy.Condition{
+						MustHaveAll: map[string]policy.Comparator{
+							"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+						},
+					},
+				},
+			},
+		},
+		{
+			Version:  1,
+			PolicyID: "readers",
+			Statements: []policy.Statement{
+				{
+					Effect:   "Allow",
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+				},
+			},
+		},
+	}
+	for _, p := range policies {
+		if err := store.Put(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Attach(ctx, "role:::owner", "owners"); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func newTestServer(t *testing.T, store policy.PolicyStore) *Server {
+	t.Helper()
+	s, err := NewServer(context.Background(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AllPoliciesWithoutPrincipals = true
+	return s
+}
+
+func post(t *testing.T, h http.Handler, path, body string, resp interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	if resp != nil {
+		if err := json.NewDecoder(rec.Body).Decode(resp); err != nil {
+			t.Fatalf("decode %s: %v", rec.Body, err)
+		}
+	}
+	return rec.Code
+}
+
+func docResource(action, owner string) string {
+	return `{"Resource": "res:::doc", "Action": "` + action + `", "Properties": {"String": {"prop:::doc:owner": "` + owner + `"}}}`
+}
+
+func TestServer_Authorize(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"all policies", `{"User": {"id": "u2"}, "Resource": ` + docResource("act:::doc:read", "u1") + `}`, true},
+		{"owner", `{"Principals": ["role:::owner"], "User": {"id": "u1"}, "Resource": ` + docResource("act:::doc:write", "u1") + `}`, true},
+		{"not owner", `{"Principals": ["role:::owner"], "User": {"id": "u2"}, "Resource": ` + docResource("act:::doc:write", "u1") + `}`, false},
+		{"principal without policies", `{"Principals": ["role:::guest"], "Resource": ` + docResource("act:::doc:read", "u1") + `}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			s := newTestServer(t, newTestStore(t))
+			var resp AuthorizeResponse
+
+			// Act
+			code := post(t, s, "/v1/authorize", tt.body, &resp)
+
+			// Assert
+			if code != http.StatusOK {
+				t.Fatalf("want %d, but got %d", http.StatusOK, code)
+			}
+			if resp.Allowed != tt.want {
+				t.Errorf("want %v, but got %v", tt.want, resp.Allowed)
+			}
+		})
+	}
+}
+
+func TestServer_BatchAuthorize(t *testing.T) {
+	// Arrange
+	s := newTestServer(t, newTestStore(t))
+	body := `{"Principals": ["role:::owner"], "User": {"id": "u1"}, "Resources": [` +
+		docResource("act:::doc:write", "u1") + `, ` + docResource("act:::doc:write", "u2") + `, ` + docResource("act:::doc:read", "u1") + `]}`
+	var resp BatchAuthorizeResponse
+
+	// Act
+	code := post(t, s, "/v1/authorize/batch", body, &resp)
+
+	// Assert
+	if code != http.StatusOK {
+		t.Fatalf("want %d, but got %d", http.StatusOK, code)
+	}
+	want := []BatchResult{{Allowed: true}, {Allowed: false}, {Allowed: true}}
+	if !reflect.DeepEqual(resp.Results, want) {
+		t.Errorf("want %v, but got %v", want, resp.Results)
+	}
+}
+
+func TestServer_Explain(t *testing.T) {
+	// Arrange
+	s := newTestServer(t, newTestStore(t))
+	body := `{"User": {"id": "u1"}, "Resource": ` + docResource("act:::doc:write", "u1") + `}`
+	var resp ExplainResponse
+
+	// Act
+	code := post(t, s, "/v1/explain", body, &resp)
+
+	// Assert
+	if code != http.StatusOK {
+		t.Fatalf("want %d, but got %d", http.StatusOK, code)
+	}
+	want := ExplainResponse{
+		Allowed:     true,
+		Reason:      policy.ReasonAllowed,
+		Matched:     []policy.MatchedStatement{{PolicyID: "owners", Index: 0, Effect: "Allow"}},
+		Explanation: `allowed: allowed by statements (policy "owners" statement 0)`,
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("want %+v, but got %+v", want, resp)
+	}
+}
+
+func TestServer_BadRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"invalid JSON", "/v1/authorize", `{`},
+		{"unknown field", "/v1/authorize", `{"Resource": {}, "Extra": 1}`},
+		{"user is not an object", "/v1/explain", `{"User": "u1", "Resource": {}}`},
+		{"too large", "/v1/authorize/batch", `{"Resources": [` + strings.Repeat(`{},`, maxRequestBytes/3) + `{}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			s := newTestServer(t, newTestStore(t))
+			var resp ErrorResponse
+
+			// Act
+			code := post(t, s, tt.path, tt.body, &resp)
+
+			// Assert
+			if code != http.StatusBadRequest {
+				t.Errorf("want %d, but got %d", http.StatusBadRequest, code)
+			}
+			if resp.Error == "" {
+				t.Error("want an error message")
+			}
+		})
+	}
+}
+
+func TestServer_MethodNotAllowed(t *testing.T) {
+	// Arrange
+	s := newTestServer(t, newTestStore(t))
+	rec := httptest.NewRecorder()
+
+	// Act
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/authorize", nil))
+
+	// Assert
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("want %d, but got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestServer_Refresh(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := newTestStore(t)
+	s := newTestServer(t, store)
+	body := `{"Principals": ["role:::reader"], "Resource": ` + docResource("act:::doc:read", "u1") + `}`
+	var before, after AuthorizeResponse
+	post(t, s, "/v1/authorize", body, &before)
+
+	// Act
+	_ = store.Attach(ctx, "role:::reader", "readers")
+	err := s.Refresh(ctx)
+	post(t, s, "/v1/authorize", body, &after)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if before.Allowed || !after.Allowed {
+		t.Errorf("want denied before and allowed after the change, but got %v and %v", before.Allowed, after.Allowed)
+	}
+}
+
+func TestServer_PrincipalsRequired(t *testing.T) {
+	// Arrange
+	s, err := NewServer(context.Background(), newTestStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp ErrorResponse
+
+	// Act
+	code := post(t, s, "/v1/authorize", `{"User": {"id": "u2"}, "Resource": `+docResource("act:::doc:read", "u1")+`}`, &resp)
+
+	// Assert
+	if code != http.StatusBadRequest || resp.Error != "Principals are required" {
+		t.Errorf("want %d with Principals are required, but got %d with %q", http.StatusBadRequest, code, resp.Error)
+	}
+}
+
+func TestServer_PrincipalWithoutPoliciesNotCached(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := newTestStore(t)
+	s := newTestServer(t, store)
+	body := `{"Principals": ["role:::reader"], "Resource": ` + docResource("act:::doc:read", "u1") + `}`
+	var before, after AuthorizeResponse
+	post(t, s, "/v1/authorize", body, &before)
+
+	// Act
+	_ = store.Attach(ctx, "role:::reader", "readers")
+	post(t, s, "/v1/authorize", body, &after)
+
+	// Assert
+	if before.Allowed || !after.Allowed {
+		t.Errorf("want denied before and allowed after the attachment, but got %v and %v", before.Allowed, after.Allowed)
+	}
+	if _, ok := s.attached["role:::reader"]; !ok {
+		t.Errorf("want the principal with policies cached")
+	}
+}
+
+type failingStore struct {
+	policy.PolicyStore
+	err error
+}
+
+func (s *failingStore) Version(ctx context.Context) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	return s.PolicyStore.Version(ctx)
+}
+
+func TestServer_Health(t *testing.T) {
+	// Arrange
+	store := &failingStore{PolicyStore: newTestStore(t)}
+	s := newTestServer(t, store)
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// Act & Assert
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("readyz: want %d, but got %d", http.StatusOK, code)
+	}
+
+	store.err = errors.New("store is down")
+	if err := s.Refresh(context.Background()); err == nil {
+		t.Error("want error, but got nil")
+	}
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("readyz: want %d, but got %d", http.StatusServiceUnavailable, code)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz: want %d, but got %d", http.StatusOK, code)
+	}
+
+	var resp AuthorizeResponse
+	post(t, s, "/v1/authorize", `{"Resource": `+docResource("act:::doc:read", "u1")+`}`, &resp)
+	if !resp.Allowed {
+		t.Error("want the cached policies while the store is down")
+	}
+}
+
+func TestNewServer_StoreError(t *testing.T) {
+	// Arrange
+	store := &failingStore{PolicyStore: policy.NewMemoryPolicyStore(), err: errors.New("store is down")}
+
+	// Act
+	_, err := NewServer(context.Background(), store)
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+}
+
+func TestServer_InvalidStatement(t *testing.T) {
+	// Arrange
+	s := newTestServer(t, newTestStore(t))
+	s.all = []policy.Policy{{Statements: []policy.Statement{{Effect: "Maybe"}}}}
+	var resp ErrorResponse
+
+	// Act
+	code := post(t, s, "/v1/authorize", `{"Resource": {}}`, &resp)
+
+	// Assert
+	if code != http.StatusUnprocessableEntity || !strings.Contains(resp.Error, "invalid effect") {
+		t.Errorf("want %d with invalid effect, but got %d %s", http.StatusUnprocessableEntity, code, resp.Error)
+	}
+}
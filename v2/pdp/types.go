@@ -0,0 +1,54 @@
+package pdp
+
+import (
+	"encoding/json"
+
+	"github.com/golfz/policy/v2"
+)
+
+// AuthorizeRequest is the body of POST /v1/authorize and POST /v1/explain.
+type AuthorizeRequest struct {
+	// Principals selects the policies attached to any of them, e.g. the user and the roles of the user.
+	// It is required, unless the server decides with all policies of the store, see Server.AllPoliciesWithoutPrincipals.
+	Principals []string `json:",omitempty"`
+	// User is the JSON object of the user properties, e.g. "user:::org:id" is {"org": {"id": ...}}.
+	User     json.RawMessage `json:",omitempty"`
+	Resource policy.Resource
+}
+
+// AuthorizeResponse is the body of a response to POST /v1/authorize.
+type AuthorizeResponse struct {
+	Allowed bool
+}
+
+// BatchAuthorizeRequest is the body of POST /v1/authorize/batch.
+type BatchAuthorizeRequest struct {
+	Principals []string        `json:",omitempty"`
+	User       json.RawMessage `json:",omitempty"`
+	Resources  []policy.Resource
+}
+
+// BatchAuthorizeResponse is the body of a response to POST /v1/authorize/batch,
+// the results are in the same order as the resources.
+type BatchAuthorizeResponse struct {
+	Results []BatchResult
+}
+
+// BatchResult is the decision for one resource, Error is set if the resource cannot be checked.
+type BatchResult struct {
+	Allowed bool
+	Error   string `json:",omitempty"`
+}
+
+// ExplainResponse is the body of a response to POST /v1/explain.
+type ExplainResponse struct {
+	Allowed     bool
+	Reason      policy.Reason
+	Matched     []policy.MatchedStatement
+	Explanation string
+}
+
+// ErrorResponse is the body of a response with an error status.
+type ErrorResponse struct {
+	Error string
+}
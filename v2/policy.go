@@ -1,8 +1,11 @@
 package policy
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 const (
@@ -24,6 +27,20 @@ type UserPropertyGetter interface {
 	GetUserProperty(key string) string
 }
 
+// ResourcePropertyGetter loads resource properties that are not set with the AddProperty functions.
+// It is called only for the keys referenced by the statements of the resource and action being validated,
+// at most once per key in each validation. A nil value means the resource does not have the property.
+type ResourcePropertyGetter interface {
+	GetResourceProperty(key string) (interface{}, error)
+}
+
+// ResourcePropertyGetterFunc is a function that can be used as a ResourcePropertyGetter.
+type ResourcePropertyGetterFunc func(key string) (interface{}, error)
+
+func (f ResourcePropertyGetterFunc) GetResourceProperty(key string) (interface{}, error) {
+	return f(key)
+}
+
 type ValidationOverrider interface {
 	OverridePolicyValidation(policies []Policy, UserPropertyGetter UserPropertyGetter, res Resource) (bool, error)
 }
@@ -50,15 +67,23 @@ type Condition struct {
 	MustHaveAll map[string]Comparator
 }
 
+// ConditionKeys returns the keys of the conditions in sorted order, e.g. to report them in a stable order.
+func ConditionKeys(conditions map[string]Comparator) []string {
+	return sortedKeys(conditions)
+}
+
 type Comparator struct {
-	StringIn       *[]string
-	StringEqual    *string
+	StringIn    *[]string
+	StringEqual *string
+	// StringPrefix is a comparator of PolicyVersion2.
+	StringPrefix   *string
 	IntegerIn      *[]int
 	IntegerEqual   *int
 	FloatIn        *[]float64
 	FloatEqual     *float64
 	BooleanEqual   *bool
 	UserPropEqual  *string
+	Expression     *Expression
 	ValidationFunc *ValidationFunc
 	//TimeRange     map[string]TimeRange
 	//DateRange     map[string]TimeRange
@@ -96,10 +121,22 @@ func (v *ValidationFunc) IsValid() bool {
 type ValidationFunction func(a, b string) (bool, error)
 
 type policyValidator struct {
-	resource            Resource
-	Policies            []Policy
-	UserPropertyGetter  UserPropertyGetter
-	ValidationOverrider ValidationOverrider
+	resource               Resource
+	Policies               []Policy
+	UserPropertyGetter     UserPropertyGetter
+	ResourcePropertyGetter ResourcePropertyGetter
+	ValidationOverrider    ValidationOverrider
+	// Coverage records the statements and comparators that are evaluated, if it is not nil.
+	Coverage *Coverage
+	// DecisionLogger records every decision, if it is not nil.
+	DecisionLogger DecisionLogger
+	// UserIDProperty is the user property recorded as the user of a decision, the default is "user:::id".
+	UserIDProperty string
+	// Metrics receives the decisions and errors of the validator, if it is not nil.
+	Metrics Metrics
+	// Tracer starts the spans of the decisions and of the calls they make, if it is not nil.
+	Tracer              Tracer
+	ctx                 context.Context
 	validationFunctions map[string]ValidationFunction
 	postValidators      []PostValidator
 	Err                 error
@@ -187,104 +224,195 @@ func (pv *policyValidator) AddPropertyBoolean(key string, value bool) {
 	pv.resource.Properties.Boolean[key] = value
 }
 
+// AddProperties adds all properties to the resource, existing keys are overwritten.
+func (pv *policyValidator) AddProperties(prop Property) {
+	for key, value := range prop.String {
+		pv.AddPropertyString(key, value)
+	}
+	for key, value := range prop.Integer {
+		pv.AddPropertyInteger(key, value)
+	}
+	for key, value := range prop.Float {
+		pv.AddPropertyFloat(key, value)
+	}
+	for key, value := range prop.Boolean {
+		pv.AddPropertyBoolean(key, value)
+	}
+}
+
 // IsAccessAllowed checks if the user is allowed to perform the action on the resource.
+// With a DecisionLogger, the decision is made and recorded like ExplainAccess.
 func (pv *policyValidator) IsAccessAllowed() (bool, error) {
+	start := time.Now()
+	allowed, err := pv.traceDecision(pv.resource, (*policyValidator).isAccessAllowed)
+	pv.observeDecision(pv.resource, allowed, err, start)
+	return allowed, err
+}
+
+func (pv *policyValidator) isAccessAllowed() (bool, error) {
+	if pv.DecisionLogger != nil {
+		explanation, err := pv.explainAndLog()
+		return explanation.Allowed, err
+	}
+
+	explanation, _, err := pv.evaluate(false)
+	return explanation.Allowed, err
+}
+
+// evaluate makes the decision of IsAccessAllowed and ExplainAccess, with the resource and the properties loaded for it.
+// The matched statements of the explanation are collected only if explain is true.
+func (pv *policyValidator) evaluate(explain bool) (Explanation, Resource, error) {
 	if pv.Err != nil {
-		return DENIED, pv.Err
+		return Explanation{}, pv.resource, pv.Err
 	}
 
 	// If there is a validation overrider, use it to determine the result.
 	if pv.ValidationOverrider != nil {
-		return pv.ValidationOverrider.OverridePolicyValidation(pv.Policies, pv.UserPropertyGetter, pv.resource)
+		allowed, err := pv.ValidationOverrider.OverridePolicyValidation(pv.Policies, pv.UserPropertyGetter, pv.resource)
+		return Explanation{Allowed: allowed, Reason: ReasonOverridden}, pv.resource, err
 	}
 
-	// Normal validation
-	if isAllow, err := pv.validateStatements(extractStatements(pv.Policies)); err != nil || !isAllow {
-		return DENIED, err
+	// filtered once, so that each statement is recorded once by the coverage
+	matched := pv.filterWithResourceAndAction(pv.resource)
+	res, err := pv.loadResourceProperties(statementsOf(matched), pv.resource)
+	if err != nil {
+		return Explanation{}, pv.resource, err
 	}
-
-	// Post validation, when normal validation is allowed
-	for _, postValidator := range pv.postValidators {
-		if result, err := postValidator.Validate(extractStatements(pv.Policies), pv.resource); err != nil || !result {
-			return DENIED, err
-		}
+	if err := checkValidPolicies(pv.Policies); err != nil {
+		return Explanation{}, res, err
 	}
 
-	return ALLOWED, nil
+	explanation, err := pv.decide(matched, res, explain)
+	return explanation, res, err
 }
 
-func (pv *policyValidator) validateStatements(statements []Statement) (bool, error) {
-	var err error
+// decide applies the rules to the statements that match the resource and action, and the post validators
+// if the statements allow the access. The matched statements of the explanation are collected only if explain is true.
+func (pv *policyValidator) decide(statements []policyStatement, res Resource, explain bool) (Explanation, error) {
+	explanation := Explanation{Reason: ReasonNoMatch}
+	for _, stmt := range statements {
+		// Rule 4: If there are no conditions, then the statement is considered matched.
+		if stmt.Conditions != nil && !pv.considerStatementConditions(stmt.Conditions, res) {
+			continue
+		}
+		if explain {
+			explanation.Matched = append(explanation.Matched, stmt.ref)
+		}
 
-	if err = pv.checkValidStatements(statements); err != nil {
-		return DENIED, err
+		// Rule 2: If there is at least one "Deny" statement, then the result is "DENIED".
+		if stmt.Effect == statementEffectDeny {
+			explanation.Reason = ReasonDenyStatement
+		} else if explanation.Reason == ReasonNoMatch {
+			// Rule 3: If all statements are "Allow" statements, then the result is "ALLOWED".
+			explanation.Reason = ReasonAllowed
+		}
 	}
-	statements = pv.filterWithResourceAndAction(statements, pv.resource)
-	statements = pv.filterWithStatementConditions(statements, pv.resource)
-
 	// Rule 1: If there are no matching statements, then the result is "DENIED".
-	if len(statements) == 0 {
-		return DENIED, nil
+	if explanation.Reason != ReasonAllowed {
+		return explanation, nil
 	}
 
-	// Rule 2: If there is at least one "Deny" statement, then the result is "DENIED".
-	for _, stmt := range statements {
-		if stmt.Effect == statementEffectDeny {
+	// Post validation, when normal validation is allowed
+	allowed, err := pv.postValidate(res)
+	if err != nil {
+		return Explanation{}, err
+	}
+	if !allowed {
+		explanation.Reason = ReasonPostValidator
+		return explanation, nil
+	}
+	explanation.Allowed = true
+	return explanation, nil
+}
+
+// postValidate runs the post validators with all statements of the policies.
+func (pv *policyValidator) postValidate(res Resource) (bool, error) {
+	if len(pv.postValidators) == 0 {
+		return ALLOWED, nil
+	}
+	statements := extractStatements(pv.Policies)
+	for _, postValidator := range pv.postValidators {
+		result, err := pv.callPostValidator(postValidator, statements, res)
+		if err != nil {
+			return DENIED, err
+		}
+		if !result {
+			if pv.Metrics != nil {
+				pv.Metrics.PostValidatorDenied()
+			}
 			return DENIED, nil
 		}
 	}
-
-	// Rule 3: If all statements are "Allow" statements, then the result is "ALLOWED".
 	return ALLOWED, nil
 }
 
-// checkValidStatements function checks if the effect of each statement is valid.
-// If the effect is not 'Allow' or 'Deny', it returns an error.
-func (pv *policyValidator) checkValidStatements(statements []Statement) error {
-	for _, stmt := range statements {
-		if !isValidEffect(stmt.Effect) {
-			return fmt.Errorf("invalid effect: %s", stmt.Effect)
+// checkValidPolicies function checks if each policy can be interpreted by the specification of its Version,
+// and if the effect of each statement is valid.
+// If the Version is not supported, a statement uses a comparator of a later version,
+// or the effect is not 'Allow' or 'Deny', it returns an error.
+func checkValidPolicies(policies []Policy) error {
+	for _, p := range policies {
+		spec, err := policySpecOf(p.Version)
+		if err != nil {
+			return fmt.Errorf("policy %q: %w", p.PolicyID, err)
+		}
+		for i, stmt := range p.Statements {
+			if !isValidEffect(stmt.Effect) {
+				return fmt.Errorf("invalid effect: %s", stmt.Effect)
+			}
+			if errs := spec.checkConditions(stmt.Conditions); len(errs) > 0 {
+				return fmt.Errorf("policy %q: statement %d: %w", p.PolicyID, i, errs[0])
+			}
 		}
 	}
 	return nil
 }
 
-func (pv *policyValidator) filterWithResourceAndAction(statements []Statement, res Resource) []Statement {
-	var filteredStatements []Statement
-	for _, stmt := range statements {
-		if stmt.Resource == res.Resource && isContainsInList(stmt.Actions, res.Action) {
-			filteredStatements = append(filteredStatements, stmt)
+// policyStatement is a statement with the policy and the index it has in the policy.
+type policyStatement struct {
+	Statement
+	ref MatchedStatement
+}
+
+// filterWithResourceAndAction returns the statements of the policies that match the resource and action,
+// in the order of the policies.
+func (pv *policyValidator) filterWithResourceAndAction(res Resource) []policyStatement {
+	var filteredStatements []policyStatement
+	for _, p := range pv.Policies {
+		for i, stmt := range p.Statements {
+			if isMatchedResourceAndAction(stmt, res) {
+				pv.Coverage.recordStatement(stmt)
+				filteredStatements = append(filteredStatements, policyStatement{
+					Statement: stmt,
+					ref:       MatchedStatement{PolicyID: p.PolicyID, Index: i, Effect: stmt.Effect},
+				})
+			}
 		}
 	}
 	return filteredStatements
 }
 
-func (pv *policyValidator) filterWithStatementConditions(statements []Statement, res Resource) []Statement {
-	var filteredStatements []Statement
-	for _, stmt := range statements {
-		// Rule 4: If there are no conditions, then the statement is considered matched.
-		if stmt.Conditions == nil {
-			filteredStatements = append(filteredStatements, stmt)
-			continue
-		}
-
-		isMatched := pv.considerStatementConditions(*stmt.Conditions, res)
-		if isMatched {
-			filteredStatements = append(filteredStatements, stmt)
-		}
+func statementsOf(statements []policyStatement) []Statement {
+	result := make([]Statement, len(statements))
+	for i, stmt := range statements {
+		result[i] = stmt.Statement
 	}
-	return filteredStatements
+	return result
 }
 
-func (pv *policyValidator) considerStatementConditions(condition Condition, res Resource) bool {
-	isAtLeastOneConditionMatched := pv.considerAtLeastOneCondition(condition.AtLeastOne, res)
-	isMustHaveAllConditionMatched := pv.considerMustHaveAllCondition(condition.MustHaveAll, res)
+func isMatchedResourceAndAction(stmt Statement, res Resource) bool {
+	return stmt.Resource == res.Resource && isContainsInList(stmt.Actions, res.Action)
+}
+
+func (pv *policyValidator) considerStatementConditions(condition *Condition, res Resource) bool {
+	isAtLeastOneConditionMatched := pv.considerAtLeastOneCondition(condition, res)
+	isMustHaveAllConditionMatched := pv.considerMustHaveAllCondition(condition, res)
 	isMatched := isAtLeastOneConditionMatched && isMustHaveAllConditionMatched
 	return isMatched
 }
 
-func (pv *policyValidator) considerAtLeastOneCondition(conditions map[string]Comparator, res Resource) bool {
-	matched, total := pv.countMatchedConditions(conditions, res)
+func (pv *policyValidator) considerAtLeastOneCondition(condition *Condition, res Resource) bool {
+	matched, total := pv.countMatchedConditions(quantifierIdentity{condition, "AtLeastOne"}, condition.AtLeastOne, res)
 	if total == 0 {
 		return conditionMatched
 	}
@@ -294,8 +422,8 @@ func (pv *policyValidator) considerAtLeastOneCondition(conditions map[string]Com
 	return conditionNotMatched
 }
 
-func (pv *policyValidator) considerMustHaveAllCondition(conditions map[string]Comparator, res Resource) bool {
-	matched, total := pv.countMatchedConditions(conditions, res)
+func (pv *policyValidator) considerMustHaveAllCondition(condition *Condition, res Resource) bool {
+	matched, total := pv.countMatchedConditions(quantifierIdentity{condition, "MustHaveAll"}, condition.MustHaveAll, res)
 	if total == 0 {
 		return conditionMatched
 	}
@@ -305,10 +433,15 @@ func (pv *policyValidator) considerMustHaveAllCondition(conditions map[string]Co
 	return conditionNotMatched
 }
 
-func (pv *policyValidator) countMatchedConditions(conditions map[string]Comparator, res Resource) (matched, total int) {
+func (pv *policyValidator) countMatchedConditions(identity quantifierIdentity, conditions map[string]Comparator, res Resource) (matched, total int) {
 	total = len(conditions)
+	if total > 0 {
+		pv.Coverage.recordQuantifier(identity)
+	}
 	for valueRefKey, comparator := range conditions {
-		if pv.isMatchedComparator(comparator, res.Properties, valueRefKey) {
+		isMatched := pv.isMatchedComparator(comparator, res.Properties, valueRefKey)
+		pv.Coverage.recordComparator(identity, valueRefKey, isMatched)
+		if isMatched {
 			matched++
 		}
 	}
@@ -326,6 +459,11 @@ func (pv *policyValidator) isMatchedComparator(comparator Comparator, prop Prope
 			return false
 		}
 	}
+	if comparator.StringPrefix != nil {
+		if !strings.HasPrefix(prop.String[comparisonTargetField], *comparator.StringPrefix) {
+			return false
+		}
+	}
 	if comparator.IntegerIn != nil {
 		if !isContainsInList(*comparator.IntegerIn, prop.Integer[comparisonTargetField]) {
 			return false
@@ -352,7 +490,14 @@ func (pv *policyValidator) isMatchedComparator(comparator Comparator, prop Prope
 		}
 	}
 	if comparator.UserPropEqual != nil {
-		if pv.UserPropertyGetter.GetUserProperty(*comparator.UserPropEqual) != prop.String[comparisonTargetField] {
+		// without a user, e.g. for an unauthenticated request, a user property matches nothing
+		if pv.UserPropertyGetter == nil || pv.UserPropertyGetter.GetUserProperty(*comparator.UserPropEqual) != prop.String[comparisonTargetField] {
+			return false
+		}
+	}
+	if comparator.Expression != nil {
+		isMatched, err := comparator.Expression.evaluate(pv.newExpressionEnv(prop))
+		if err != nil || !isMatched {
 			return false
 		}
 	}
@@ -360,6 +505,9 @@ func (pv *policyValidator) isMatchedComparator(comparator Comparator, prop Prope
 	if comparator.ValidationFunc != nil {
 		fn := pv.getValidationFunction(comparator.ValidationFunc.Function)
 		if fn == nil {
+			if pv.Metrics != nil {
+				pv.Metrics.ValidationFunctionError(comparator.ValidationFunc.Function)
+			}
 			return false
 		}
 
@@ -369,8 +517,11 @@ func (pv *policyValidator) isMatchedComparator(comparator Comparator, prop Prope
 			return false
 		}
 
-		isMatched, err := fn(firstArg, secondArg)
+		isMatched, err := pv.callValidationFunction(comparator.ValidationFunc.Function, fn, firstArg, secondArg)
 		if err != nil {
+			if pv.Metrics != nil {
+				pv.Metrics.ValidationFunctionError(comparator.ValidationFunc.Function)
+			}
 			return false
 		}
 		return isMatched
@@ -390,6 +541,9 @@ func (pv *policyValidator) getSecondArgumentForValidationFunc(prop Property, com
 		return prop.String[*comparator.ValidationFunc.PropArg], nil
 
 	} else if comparator.ValidationFunc.UserArg != nil {
+		if pv.UserPropertyGetter == nil {
+			return "", fmt.Errorf("cannot read %s: %w", *comparator.ValidationFunc.UserArg, errNoUserPropertyGetter)
+		}
 		return pv.UserPropertyGetter.GetUserProperty(*comparator.ValidationFunc.UserArg), nil
 
 	} else {
@@ -397,6 +551,14 @@ func (pv *policyValidator) getSecondArgumentForValidationFunc(prop Property, com
 	}
 }
 
+func (pv *policyValidator) newExpressionEnv(prop Property) *exprEnv {
+	return &exprEnv{
+		prop: prop,
+		user: pv.UserPropertyGetter,
+		res:  pv.resource,
+	}
+}
+
 // ----------------------------------------------
 // Helper functions
 // ----------------------------------------------
@@ -0,0 +1,205 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultLoaderInterval = time.Second
+
+// PolicyLoader loads policies from JSON, YAML or policy DSL files and reloads them when the files are changed,
+// so that the policies can be changed without restarting the service.
+//
+// The path is a file or a directory, every "*.json", "*.yaml", "*.yml" and "*.policy" file of a directory holds
+// policies as read by ParsePolicyFile.
+// Reloaded policies replace the previous ones at once, and only if all files are parsed and pass ValidatePolicies.
+type PolicyLoader struct {
+	// Interval is how often Watch checks the files, the default is 1 second.
+	Interval time.Duration
+	// OnReload is called by Watch after the files are changed, with the error if the previous policies are kept.
+	OnReload func(policies []Policy, err error)
+	// DecisionLogger is the DecisionLogger of the validators of New.
+	DecisionLogger DecisionLogger
+	// Metrics is the Metrics of the validators of New.
+	Metrics Metrics
+	// Tracer is the Tracer of the validators of New.
+	Tracer Tracer
+
+	path     string
+	mu       sync.Mutex
+	checksum uint64
+	// err is the error of the files of the checksum, if they are not loaded.
+	err      error
+	policies atomic.Pointer[[]Policy]
+}
+
+// NewPolicyLoader loads the policies of the path, or returns the error if they cannot be loaded.
+func NewPolicyLoader(path string) (*PolicyLoader, error) {
+	l := &PolicyLoader{
+		Interval: defaultLoaderInterval,
+		path:     path,
+	}
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Policies returns the current policies. They are shared by all callers and must not be modified.
+func (l *PolicyLoader) Policies() []Policy {
+	return *l.policies.Load()
+}
+
+// New creates a validator with the current policies. A validation keeps using the same policies
+// even if they are reloaded during the validation.
+func (l *PolicyLoader) New() *policyValidator {
+	pv := New()
+	pv.Policies = l.Policies()
+	pv.DecisionLogger = l.DecisionLogger
+	pv.Metrics = l.Metrics
+	pv.Tracer = l.Tracer
+	return pv
+}
+
+// Reload loads the files if they are changed since the last reload, and reports whether the policies are replaced.
+// If the files cannot be parsed or the policies are invalid, the previous policies are kept,
+// and the error is returned until the files are changed.
+func (l *PolicyLoader) Reload() (bool, error) {
+	files, err := l.readFiles()
+	if err != nil {
+		return false, err
+	}
+	return l.reload(files, checksumFiles(files))
+}
+
+// Watch checks the files every Interval and reloads them when they are changed, until the context is done.
+// A change is loaded once the files are the same on two checks, so that a file being written is not loaded.
+func (l *PolicyLoader) Watch(ctx context.Context) error {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = defaultLoaderInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending, reported uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		files, err := l.readFiles()
+		if err != nil {
+			// a file may be replaced at the moment, check again later
+			continue
+		}
+		checksum := checksumFiles(files)
+		if checksum != pending {
+			pending = checksum
+			continue
+		}
+		changed, err := l.reload(files, checksum)
+		if err != nil {
+			// a broken change is reported once, not on every check
+			if checksum == reported {
+				continue
+			}
+			reported = checksum
+		} else {
+			reported = 0
+		}
+		if (changed || err != nil) && l.OnReload != nil {
+			l.OnReload(l.Policies(), err)
+		}
+	}
+}
+
+func (l *PolicyLoader) reload(files []policyFile, checksum uint64) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.policies.Load() != nil && checksum == l.checksum {
+		return false, l.err
+	}
+
+	policies, err := parsePolicyFiles(files)
+	l.checksum, l.err = checksum, err
+	if err != nil {
+		return false, err
+	}
+	l.policies.Store(&policies)
+	return true, nil
+}
+
+// parsePolicyFiles parses the policies of the files, and checks them with ValidatePolicies.
+func parsePolicyFiles(files []policyFile) ([]Policy, error) {
+	policies := make([]Policy, 0)
+	for _, f := range files {
+		parsed, err := ParsePolicyFile(f.name, f.content)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", f.name, err)
+		}
+		policies = append(policies, parsed...)
+	}
+	if err := ValidatePolicies(policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+type policyFile struct {
+	name    string
+	content []byte
+}
+
+func (l *PolicyLoader) readFiles() ([]policyFile, error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		content, err := os.ReadFile(l.path)
+		if err != nil {
+			return nil, err
+		}
+		return []policyFile{{name: l.path, content: content}}, nil
+	}
+
+	entries, err := os.ReadDir(l.path)
+	if err != nil {
+		return nil, err
+	}
+	var files []policyFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !(strings.HasSuffix(name, ".json") || IsYAMLFile(name) || IsDSLFile(name)) || strings.HasPrefix(name, ".") {
+			continue
+		}
+		name = filepath.Join(l.path, name)
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, policyFile{name: name, content: content})
+	}
+	return files, nil
+}
+
+// checksumFiles detects changes by content, because the modification time may not change on quick edits.
+func checksumFiles(files []policyFile) uint64 {
+	h := fnv.New64a()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\x00%d\x00", f.name, len(f.content))
+		h.Write(f.content)
+	}
+	return h.Sum64()
+}
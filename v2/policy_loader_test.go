@@ -0,0 +1,234 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func loaderPolicyJSON(id, action string) string {
+	return `[{"Version": 1, "PolicyID": "` + id + `", "Statements": [{"Effect": "Allow", "Resource": "res:::doc", "Actions": ["` + action + `"]}]}]`
+}
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func isAllowedByLoader(l *PolicyLoader, action string) bool {
+	pv := l.New()
+	pv.SetResource("res:::doc")
+	pv.SetAction(action)
+	allowed, _ := pv.IsAccessAllowed()
+	return allowed
+}
+
+func TestNewPolicyLoader_Directory(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "b.json"), loaderPolicyJSON("b", "act:::doc:write"))
+	writeTestFile(t, filepath.Join(dir, "a.json"), loaderPolicyJSON("a", "act:::doc:read"))
+	writeTestFile(t, filepath.Join(dir, "c.yaml"), "Version: 1\nPolicyID: c\nStatements:\n  - {Effect: Allow, Resource: res:::doc, Actions: [act:::doc:delete]}\n")
+	writeTestFile(t, filepath.Join(dir, "d.policy"), "policy \"d\" version 1 {\n    allow act:::doc:share on res:::doc\n}\n")
+	writeTestFile(t, filepath.Join(dir, ".c.json"), `broken`)
+	writeTestFile(t, filepath.Join(dir, "README.md"), `not a policy`)
+
+	// Act
+	l, err := NewPolicyLoader(dir)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got := policyIDs(l.Policies()); len(got) != 4 || got[0] != "a" || got[1] != "b" || got[2] != "c" || got[3] != "d" {
+		t.Errorf("want [a b c d], but got %v", got)
+	}
+}
+
+func TestNewPolicyLoader_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid JSON", `[{`},
+		{"invalid policy", `[{"PolicyID": "a", "Statements": [{"Effect": "Maybe", "Resource": "res:::doc", "Actions": ["act:::doc:read"]}]}]`},
+		{"duplicate PolicyID", `[{"PolicyID": "a"}, {"PolicyID": "a"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			file := filepath.Join(t.TempDir(), "policies.json")
+			writeTestFile(t, file, tt.content)
+
+			// Act
+			_, err := NewPolicyLoader(file)
+
+			// Assert
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
+
+func TestPolicyLoader_Reload(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policies.json")
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	l, err := NewPolicyLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act & Assert
+	if changed, err := l.Reload(); changed || err != nil {
+		t.Errorf("unchanged file: want false, nil, but got %v, %v", changed, err)
+	}
+
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:write"))
+	if changed, err := l.Reload(); !changed || err != nil {
+		t.Errorf("changed file: want true, nil, but got %v, %v", changed, err)
+	}
+	if !isAllowedByLoader(l, "act:::doc:write") || isAllowedByLoader(l, "act:::doc:read") {
+		t.Error("want the changed policies")
+	}
+
+	writeTestFile(t, file, `[{"PolicyID": "p1", "Statements": [{"Effect": "Allow", "Resource": "doc", "Actions": []}]}]`)
+	if changed, err := l.Reload(); changed || err == nil {
+		t.Errorf("invalid file: want false, error, but got %v, %v", changed, err)
+	}
+	if !isAllowedByLoader(l, "act:::doc:write") {
+		t.Error("want the previous policies after an invalid change")
+	}
+	if changed, err := l.Reload(); changed || err == nil {
+		t.Errorf("same invalid file: want false, error, but got %v, %v", changed, err)
+	}
+
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	if changed, err := l.Reload(); !changed || err != nil {
+		t.Errorf("fixed file: want true, nil, but got %v, %v", changed, err)
+	}
+}
+
+func TestPolicyLoader_Watch(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policies.json")
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	l, err := NewPolicyLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Interval = time.Millisecond
+	reloaded := make(chan error, 10)
+	l.OnReload = func(policies []Policy, err error) { reloaded <- err }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Watch(ctx) }()
+
+	// Act
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:write"))
+
+	// Assert
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Errorf("want nil, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want reload, but got timeout")
+	}
+	if !isAllowedByLoader(l, "act:::doc:write") {
+		t.Error("want the changed policies")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("want %v, but got %v", context.Canceled, err)
+	}
+}
+
+func TestPolicyLoader_WatchReportsErrorOnce(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policies.json")
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	l, err := NewPolicyLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Interval = time.Millisecond
+	reloaded := make(chan error, 100)
+	l.OnReload = func(policies []Policy, err error) { reloaded <- err }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Watch(ctx) }()
+
+	// Act
+	writeTestFile(t, file, `not json`)
+	var first error
+	select {
+	case first = <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want reload, but got timeout")
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	// Assert
+	if first == nil {
+		t.Error("want the error of the broken file, but got nil")
+	}
+	if n := len(reloaded); n != 0 {
+		t.Errorf("want the error reported once, but got %d more", n)
+	}
+}
+
+func TestPolicyLoader_ConcurrentValidation(t *testing.T) {
+	// Arrange
+	file := filepath.Join(t.TempDir(), "policies.json")
+	writeTestFile(t, file, loaderPolicyJSON("p1", "act:::doc:read"))
+	l, err := NewPolicyLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				// every snapshot is a complete set of policies, never a partly written file
+				pv := l.New()
+				if len(pv.Policies) != 1 || len(pv.Policies[0].Statements) != 1 {
+					t.Errorf("want 1 policy with 1 statement, but got %+v", pv.Policies)
+					return
+				}
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		action := "act:::doc:read"
+		if j%2 == 0 {
+			action = "act:::doc:write"
+		}
+		if err := writeFileAtomic(file, []byte(loaderPolicyJSON("p1", action))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	// Assert
+	if len(l.Policies()) != 1 {
+		t.Errorf("want 1 policy, but got %d", len(l.Policies()))
+	}
+}
@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPolicyNotFound = errors.New("policy not found")
+	ErrInvalidPolicy  = errors.New("invalid policy")
+)
+
+// PolicyStore keeps policies by PolicyID, and which policies are attached to a principal.
+// A principal is any name policies are granted to, e.g. "user:::1234" or "role:::admin".
+type PolicyStore interface {
+	// List returns all policies, ordered by PolicyID.
+	List(ctx context.Context) ([]Policy, error)
+	// Get returns the policy, or ErrPolicyNotFound.
+	Get(ctx context.Context, policyID string) (Policy, error)
+	// Put adds or replaces the policy, an invalid policy is rejected with ErrInvalidPolicy.
+	Put(ctx context.Context, policy Policy) error
+	// Delete removes the policy and its attachments, or returns ErrPolicyNotFound.
+	Delete(ctx context.Context, policyID string) error
+	// Version changes whenever a policy or an attachment is changed. Attaching an attached policy
+	// or detaching a policy that is not attached changes nothing, so the version stays the same.
+	Version(ctx context.Context) (int64, error)
+
+	// Attach attaches the policy to the principal, or returns ErrPolicyNotFound.
+	Attach(ctx context.Context, principal, policyID string) error
+	// Detach removes the policy from the principal.
+	Detach(ctx context.Context, principal, policyID string) error
+	// Attached returns the policies attached to the principal, ordered by PolicyID.
+	Attached(ctx context.Context, principal string) ([]Policy, error)
+}
+
+// NewFromStore creates a validator with the policies attached to any of the principals,
+// e.g. to a user and to the roles of the user. A policy attached to several principals is added once.
+func NewFromStore(ctx context.Context, store PolicyStore, principals ...string) (*policyValidator, error) {
+	var policies []Policy
+	seen := make(map[string]bool)
+	for _, principal := range principals {
+		attached, err := store.Attached(ctx, principal)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get policies of %s: %w", principal, err)
+		}
+		for _, p := range attached {
+			if !seen[p.PolicyID] {
+				seen[p.PolicyID] = true
+				policies = append(policies, p)
+			}
+		}
+	}
+
+	pv := New()
+	pv.Policies = policies
+	return pv, nil
+}
+
+// checkStoredPolicy checks a policy before it is stored.
+func checkStoredPolicy(p Policy) error {
+	if err := checkPolicyID(p.PolicyID); err != nil {
+		return err
+	}
+	if err := ValidatePolicy(p); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	return nil
+}
+
+func checkPolicyID(policyID string) error {
+	if policyID == "" {
+		return fmt.Errorf("%w: no PolicyID", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// clonePolicy returns a deep copy of the policy, so that a stored policy cannot be changed by the caller.
+func clonePolicy(p Policy) (Policy, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return Policy{}, err
+	}
+	return ParsePolicy(b)
+}
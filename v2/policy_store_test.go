@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func storedPolicy(id, resource string) Policy {
+	return Policy{
+		Version:  1,
+		PolicyID: id,
+		Statements: []Statement{
+			{
+				Effect:   statementEffectAllow,
+				Resource: resource,
+				Actions:  []string{"act:::doc:read"},
+			},
+		},
+	}
+}
+
+func policyIDs(policies []Policy) []string {
+	ids := make([]string, 0, len(policies))
+	for _, p := range policies {
+		ids = append(ids, p.PolicyID)
+	}
+	return ids
+}
+
+// testPolicyStore checks the behavior every PolicyStore must have.
+func testPolicyStore(t *testing.T, newStore func(t *testing.T) PolicyStore) {
+	ctx := context.Background()
+
+	t.Run("put, get and list", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+
+		// Act
+		for _, id := range []string{"p2", "p1", "p3"} {
+			if err := store.Put(ctx, storedPolicy(id, "res:::"+id)); err != nil {
+				t.Fatalf("put %s: %v", id, err)
+			}
+		}
+		got, getErr := store.Get(ctx, "p2")
+		list, listErr := store.List(ctx)
+
+		// Assert
+		if getErr != nil || !reflect.DeepEqual(got, storedPolicy("p2", "res:::p2")) {
+			t.Errorf("want p2, but got %+v, %v", got, getErr)
+		}
+		if want := []string{"p1", "p2", "p3"}; listErr != nil || !reflect.DeepEqual(policyIDs(list), want) {
+			t.Errorf("want %v, but got %v, %v", want, policyIDs(list), listErr)
+		}
+	})
+
+	t.Run("put replaces the policy", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		_ = store.Put(ctx, storedPolicy("p1", "res:::old"))
+
+		// Act
+		err := store.Put(ctx, storedPolicy("p1", "res:::new"))
+		got, _ := store.Get(ctx, "p1")
+		list, _ := store.List(ctx)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("want nil, but got %v", err)
+		}
+		if got.Statements[0].Resource != "res:::new" || len(list) != 1 {
+			t.Errorf("want only the new policy, but got %+v", list)
+		}
+	})
+
+	t.Run("put rejects invalid policies", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		invalid := storedPolicy("p1", "doc")
+
+		// Act
+		errInvalid := store.Put(ctx, invalid)
+		errNoID := store.Put(ctx, storedPolicy("", "res:::doc"))
+
+		// Assert
+		if !errors.Is(errInvalid, ErrInvalidPolicy) {
+			t.Errorf("want ErrInvalidPolicy, but got %v", errInvalid)
+		}
+		if !errors.Is(errNoID, ErrInvalidPolicy) {
+			t.Errorf("want ErrInvalidPolicy, but got %v", errNoID)
+		}
+		if list, _ := store.List(ctx); len(list) != 0 {
+			t.Errorf("want no policies, but got %v", policyIDs(list))
+		}
+	})
+
+	t.Run("stored policy is not changed by the caller", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		p := storedPolicy("p1", "res:::doc")
+		_ = store.Put(ctx, p)
+
+		// Act
+		p.Statements[0].Actions[0] = "act:::changed"
+		got, _ := store.Get(ctx, "p1")
+		got.Statements[0].Actions[0] = "act:::changed"
+		again, _ := store.Get(ctx, "p1")
+
+		// Assert
+		if again.Statements[0].Actions[0] != "act:::doc:read" {
+			t.Errorf("want act:::doc:read, but got %s", again.Statements[0].Actions[0])
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+
+		// Act
+		_, getErr := store.Get(ctx, "p1")
+		deleteErr := store.Delete(ctx, "p1")
+		attachErr := store.Attach(ctx, "role:::admin", "p1")
+
+		// Assert
+		for _, err := range []error{getErr, deleteErr, attachErr} {
+			if !errors.Is(err, ErrPolicyNotFound) {
+				t.Errorf("want ErrPolicyNotFound, but got %v", err)
+			}
+		}
+	})
+
+	t.Run("attach and detach", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		for _, id := range []string{"p1", "p2", "p3"} {
+			_ = store.Put(ctx, storedPolicy(id, "res:::"+id))
+		}
+
+		// Act
+		_ = store.Attach(ctx, "role:::admin", "p3")
+		_ = store.Attach(ctx, "role:::admin", "p1")
+		_ = store.Attach(ctx, "role:::admin", "p1")
+		_ = store.Attach(ctx, "user:::1", "p2")
+		admin, _ := store.Attached(ctx, "role:::admin")
+		_ = store.Detach(ctx, "role:::admin", "p3")
+		afterDetach, _ := store.Attached(ctx, "role:::admin")
+		nobody, err := store.Attached(ctx, "user:::2")
+
+		// Assert
+		if want := []string{"p1", "p3"}; !reflect.DeepEqual(policyIDs(admin), want) {
+			t.Errorf("want %v, but got %v", want, policyIDs(admin))
+		}
+		if want := []string{"p1"}; !reflect.DeepEqual(policyIDs(afterDetach), want) {
+			t.Errorf("want %v, but got %v", want, policyIDs(afterDetach))
+		}
+		if err != nil || len(nobody) != 0 {
+			t.Errorf("want no policies, but got %v, %v", policyIDs(nobody), err)
+		}
+	})
+
+	t.Run("delete removes attachments", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		_ = store.Put(ctx, storedPolicy("p1", "res:::p1"))
+		_ = store.Put(ctx, storedPolicy("p2", "res:::p2"))
+		_ = store.Attach(ctx, "role:::admin", "p1")
+		_ = store.Attach(ctx, "role:::admin", "p2")
+
+		// Act
+		err := store.Delete(ctx, "p1")
+		_ = store.Put(ctx, storedPolicy("p1", "res:::p1"))
+		attached, _ := store.Attached(ctx, "role:::admin")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("want nil, but got %v", err)
+		}
+		if want := []string{"p2"}; !reflect.DeepEqual(policyIDs(attached), want) {
+			t.Errorf("want %v, but got %v", want, policyIDs(attached))
+		}
+	})
+
+	t.Run("version changes", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		versions := make(map[int64]bool)
+		record := func() {
+			v, err := store.Version(ctx)
+			if err != nil {
+				t.Fatalf("version: %v", err)
+			}
+			if versions[v] {
+				t.Errorf("version %d is not changed", v)
+			}
+			versions[v] = true
+		}
+
+		// Act & Assert
+		record()
+		_ = store.Put(ctx, storedPolicy("p1", "res:::p1"))
+		record()
+		_ = store.Attach(ctx, "role:::admin", "p1")
+		record()
+		_ = store.Detach(ctx, "role:::admin", "p1")
+		record()
+		_ = store.Delete(ctx, "p1")
+		record()
+	})
+
+	t.Run("version unchanged without a change", func(t *testing.T) {
+		// Arrange
+		store := newStore(t)
+		_ = store.Put(ctx, storedPolicy("p1", "res:::p1"))
+		_ = store.Attach(ctx, "role:::admin", "p1")
+		want, _ := store.Version(ctx)
+
+		// Act
+		attachErr := store.Attach(ctx, "role:::admin", "p1")
+		detachErr := store.Detach(ctx, "role:::guest", "p1")
+		got, err := store.Version(ctx)
+
+		// Assert
+		if attachErr != nil || detachErr != nil {
+			t.Fatalf("want nil, but got %v, %v", attachErr, detachErr)
+		}
+		if err != nil || got != want {
+			t.Errorf("want version %d, but got %d, %v", want, got, err)
+		}
+	})
+}
+
+func TestNewFromStore(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewMemoryPolicyStore()
+	read := storedPolicy("read", "res:::doc")
+	write := storedPolicy("write", "res:::doc")
+	write.Statements[0].Actions = []string{"act:::doc:write"}
+	for _, p := range []Policy{read, write, storedPolicy("other", "res:::other")} {
+		if err := store.Put(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = store.Attach(ctx, "user:::1", "read")
+	_ = store.Attach(ctx, "role:::editor", "read")
+	_ = store.Attach(ctx, "role:::editor", "write")
+
+	// Act
+	pv, err := NewFromStore(ctx, store, "user:::1", "role:::editor")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if want := []string{"read", "write"}; !reflect.DeepEqual(policyIDs(pv.Policies), want) {
+		t.Errorf("want %v, but got %v", want, policyIDs(pv.Policies))
+	}
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:write")
+	if allowed, err := pv.IsAccessAllowed(); !allowed || err != nil {
+		t.Errorf("want true, but got %v, %v", allowed, err)
+	}
+}
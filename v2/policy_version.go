@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+)
+
+const (
+	// PolicyVersion1 is the policy syntax of the README. A policy without a Version is read as version 1.
+	PolicyVersion1 = 1
+	// PolicyVersion2 is version 1 with the StringPrefix comparator.
+	PolicyVersion2 = 2
+	// LatestPolicyVersion is the latest version of the policy syntax.
+	LatestPolicyVersion = PolicyVersion2
+)
+
+// policySpec is a version of the policy syntax. Policies of different versions can be used together,
+// each policy is interpreted by the specification of its own Version.
+type policySpec struct {
+	version int
+	// comparators are the fields of Comparator that the policies of the version can use.
+	comparators []string
+}
+
+var policyVersion1Comparators = []string{
+	"StringIn", "StringEqual", "IntegerIn", "IntegerEqual", "FloatIn", "FloatEqual", "BooleanEqual",
+	"UserPropEqual", "Expression", "ValidationFunc",
+}
+
+var policySpecs = []policySpec{
+	{version: PolicyVersion1, comparators: policyVersion1Comparators},
+	{version: PolicyVersion2, comparators: append(append([]string(nil), policyVersion1Comparators...), "StringPrefix")},
+}
+
+// policySpecOf returns the specification of the version, version 0 is version 1.
+func policySpecOf(version int) (policySpec, error) {
+	if version == 0 {
+		version = PolicyVersion1
+	}
+	for _, spec := range policySpecs {
+		if spec.version == version {
+			return spec, nil
+		}
+	}
+	return policySpec{}, fmt.Errorf("unsupported Version %d, the versions are %d to %d", version, PolicyVersion1, LatestPolicyVersion)
+}
+
+// checkConditions returns an error for each comparator that the version does not have, by key.
+func (spec policySpec) checkConditions(conditions *Condition) []error {
+	if conditions == nil {
+		return nil
+	}
+	var errs []error
+	for _, quantifier := range []struct {
+		name       string
+		conditions map[string]Comparator
+	}{
+		{"AtLeastOne", conditions.AtLeastOne},
+		{"MustHaveAll", conditions.MustHaveAll},
+	} {
+		for _, key := range sortedKeys(quantifier.conditions) {
+			for _, name := range comparatorNames(quantifier.conditions[key]) {
+				if !isContainsInList(spec.comparators, name) {
+					errs = append(errs, fmt.Errorf("%s %s: %s requires Version %d", quantifier.name, key, name, minPolicyVersion(name)))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// minPolicyVersion returns the first version that has the comparator.
+func minPolicyVersion(comparator string) int {
+	for _, spec := range policySpecs {
+		if isContainsInList(spec.comparators, comparator) {
+			return spec.version
+		}
+	}
+	return 0
+}
+
+// comparatorNames returns the names of the comparisons of the comparator, in the order of the fields.
+func comparatorNames(comparator Comparator) []string {
+	var names []string
+	v := reflect.ValueOf(comparator)
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsNil() {
+			names = append(names, v.Type().Field(i).Name)
+		}
+	}
+	return names
+}
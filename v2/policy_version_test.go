@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"testing"
+)
+
+func TestPolicySpecOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int
+		wantVersion int
+		wantErr     bool
+	}{
+		{"no version is version 1", 0, PolicyVersion1, false},
+		{"version 1", 1, PolicyVersion1, false},
+		{"version 2", 2, PolicyVersion2, false},
+		{"unknown version", 3, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			spec, err := policySpecOf(tt.version)
+
+			// Assert
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, but got %v", tt.wantErr, err)
+			}
+			if spec.version != tt.wantVersion {
+				t.Errorf("want %v, but got %v", tt.wantVersion, spec.version)
+			}
+		})
+	}
+}
+
+func TestPolicySpecs_AllComparators(t *testing.T) {
+	// Arrange
+	latest, err := policySpecOf(LatestPolicyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := Comparator{
+		StringIn: &[]string{}, StringEqual: ptr(""), StringPrefix: ptr(""), IntegerIn: &[]int{}, IntegerEqual: ptr(0),
+		FloatIn: &[]float64{}, FloatEqual: ptr(0.0), BooleanEqual: ptr(false), UserPropEqual: ptr(""),
+		Expression: &Expression{}, ValidationFunc: &ValidationFunc{},
+	}
+
+	// Act
+	names := comparatorNames(all)
+
+	// Assert
+	for _, name := range names {
+		if !isContainsInList(latest.comparators, name) {
+			t.Errorf("want %s in the latest version, but got %v", name, latest.comparators)
+		}
+	}
+	if len(names) != len(latest.comparators) {
+		t.Errorf("want %d comparators, but got %d", len(latest.comparators), len(names))
+	}
+}
+
+func TestIsAccessAllowed_Version(t *testing.T) {
+	prefixStatement := Statement{
+		Effect:   statementEffectAllow,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{
+			MustHaveAll: map[string]Comparator{"prop:::doc:path": {StringPrefix: ptr("/docs/")}},
+		},
+	}
+	denyStatement := Statement{
+		Effect:   statementEffectDeny,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{
+			MustHaveAll: map[string]Comparator{"prop:::doc:locked": {BooleanEqual: ptr(true)}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		policies []Policy
+		path     string
+		locked   bool
+		expected bool
+		wantErr  string
+	}{
+		{
+			name:     "version 2 comparator matched",
+			policies: []Policy{{Version: PolicyVersion2, PolicyID: "p2", Statements: []Statement{prefixStatement}}},
+			path:     "/docs/a",
+			expected: ALLOWED,
+		},
+		{
+			name:     "version 2 comparator not matched",
+			policies: []Policy{{Version: PolicyVersion2, PolicyID: "p2", Statements: []Statement{prefixStatement}}},
+			path:     "/private/a",
+			expected: DENIED,
+		},
+		{
+			name: "version 1 and version 2 policies together",
+			policies: []Policy{
+				{Version: PolicyVersion1, PolicyID: "p1", Statements: []Statement{denyStatement}},
+				{Version: PolicyVersion2, PolicyID: "p2", Statements: []Statement{prefixStatement}},
+			},
+			path:     "/docs/a",
+			locked:   true,
+			expected: DENIED,
+		},
+		{
+			name:     "version 2 comparator in version 1 policy",
+			policies: []Policy{{Version: PolicyVersion1, PolicyID: "p1", Statements: []Statement{prefixStatement}}},
+			path:     "/docs/a",
+			expected: DENIED,
+			wantErr:  `policy "p1": statement 0: MustHaveAll prop:::doc:path: StringPrefix requires Version 2`,
+		},
+		{
+			name: "unsupported version",
+			policies: []Policy{
+				{Version: PolicyVersion2, PolicyID: "p2", Statements: []Statement{prefixStatement}},
+				{Version: 99, PolicyID: "p99"},
+			},
+			path:     "/docs/a",
+			expected: DENIED,
+			wantErr:  `policy "p99": unsupported Version 99, the versions are 1 to 2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pv := New()
+			pv.Policies = tt.policies
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+			pv.AddPropertyString("prop:::doc:path", tt.path)
+			pv.AddPropertyBoolean("prop:::doc:locked", tt.locked)
+
+			// Act
+			result, err := pv.IsAccessAllowed()
+
+			// Assert
+			if result != tt.expected {
+				t.Errorf("want %v, but got %v", tt.expected, result)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("want %q, but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPartialEvaluate_StringPrefix(t *testing.T) {
+	// Arrange
+	pv := New()
+	pv.Policies = []Policy{{Version: PolicyVersion2, PolicyID: "p2", Statements: []Statement{{
+		Effect:   statementEffectAllow,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{
+			MustHaveAll: map[string]Comparator{"prop:::doc:path": {StringPrefix: ptr("/docs/")}},
+		},
+	}}}}
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	filter, err := pv.PartialEvaluate()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if want := `startsWith(prop.doc.path, "/docs/")`; filter.String() != want {
+		t.Errorf("want %s, but got %s", want, filter)
+	}
+}
@@ -0,0 +1,251 @@
+// Package policytest runs declarative test suites of policies, written in YAML or JSON.
+//
+// A suite lists the policy files and the cases to check against them:
+//
+//	policies:
+//	  - policies.json
+//	cases:
+//	  - name: owner can write
+//	    user: {id: u1}
+//	    resource: res:::doc
+//	    action: act:::doc:write
+//	    properties:
+//	      prop:::doc:owner: u1
+//	    expect: ALLOW
+//
+// The policy files are relative to the suite file, and read by policy.ParsePolicyFile. The user is the JSON object read by
+// policy.NewDefaultUserPropertyGetter. A property is a string, an integer, a float or a boolean, by its value.
+package policytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golfz/policy/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ExpectAllow = "ALLOW"
+	ExpectDeny  = "DENY"
+)
+
+type Suite struct {
+	Policies []string `yaml:"policies"`
+	Cases    []Case   `yaml:"cases"`
+}
+
+type Case struct {
+	Name       string                 `yaml:"name"`
+	User       map[string]interface{} `yaml:"user"`
+	Resource   string                 `yaml:"resource"`
+	Action     string                 `yaml:"action"`
+	Properties map[string]interface{} `yaml:"properties"`
+	// Expect is ALLOW or DENY.
+	Expect string `yaml:"expect"`
+}
+
+// Result is the outcome of a case, Err is set if the case cannot be evaluated.
+type Result struct {
+	Case        Case
+	Explanation policy.Explanation
+	Err         error
+}
+
+// Passed reports whether the decision is the expected one.
+func (r Result) Passed() bool {
+	return r.Err == nil && r.Explanation.Allowed == (r.Case.Expect == ExpectAllow)
+}
+
+// String describes the result, e.g. `FAIL owner can write: want ALLOW, but got denied: no statement matched`.
+func (r Result) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v", r.Case.Name, r.Err)
+	case r.Passed():
+		return fmt.Sprintf("PASS %s", r.Case.Name)
+	default:
+		return fmt.Sprintf("FAIL %s: want %s, but got %s", r.Case.Name, r.Case.Expect, r.Explanation)
+	}
+}
+
+// LoadSuite reads a suite, and the policies of its files.
+func LoadSuite(path string) (*Suite, []policy.Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	suite, err := ParseSuite(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var policies []policy.Policy
+	for _, name := range suite.Policies {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(path), name)
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		p, err := policy.ParsePolicyFile(name, b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		policies = append(policies, p...)
+	}
+	return suite, policies, nil
+}
+
+// ParseSuite parses a suite in YAML or JSON, and checks its cases.
+func ParseSuite(b []byte) (*Suite, error) {
+	var suite Suite
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&suite); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i)
+		}
+		c.Expect = strings.ToUpper(c.Expect)
+		if c.Expect != ExpectAllow && c.Expect != ExpectDeny {
+			errs = append(errs, fmt.Errorf("%s: expect must be %s or %s, but got %q", c.Name, ExpectAllow, ExpectDeny, c.Expect))
+		}
+		if _, err := toProperty(c.Properties); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &suite, nil
+}
+
+// Run evaluates each case against the policies.
+func Run(policies []policy.Policy, cases []Case) []Result {
+	return RunWithCoverage(policies, cases, nil)
+}
+
+// RunWithCoverage evaluates each case against the policies, and records the evaluations in the coverage
+// if it is not nil. The policies are added to the coverage.
+func RunWithCoverage(policies []policy.Policy, cases []Case, coverage *policy.Coverage) []Result {
+	if coverage != nil {
+		coverage.Add(policies)
+	}
+	results := make([]Result, len(cases))
+	for i, c := range cases {
+		results[i] = Result{Case: c}
+		results[i].Explanation, results[i].Err = evaluate(policies, c, coverage)
+	}
+	return results
+}
+
+// RunFile loads the suite at the path and runs its cases.
+func RunFile(path string) ([]Result, error) {
+	return RunFileWithCoverage(path, nil)
+}
+
+// RunFileWithCoverage loads the suite at the path and runs its cases like RunWithCoverage.
+func RunFileWithCoverage(path string, coverage *policy.Coverage) ([]Result, error) {
+	suite, policies, err := LoadSuite(path)
+	if err != nil {
+		return nil, err
+	}
+	return RunWithCoverage(policies, suite.Cases, coverage), nil
+}
+
+// Test runs each case of the suite at the path as a subtest, a failure is reported with its explanation.
+func Test(t *testing.T, path string) {
+	t.Helper()
+	suite, policies, err := LoadSuite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	TestCases(t, policies, suite.Cases)
+}
+
+// TestCases runs each case against the policies as a subtest.
+func TestCases(t *testing.T, policies []policy.Policy, cases []Case) {
+	t.Helper()
+	for _, result := range Run(policies, cases) {
+		result := result
+		t.Run(result.Case.Name, func(t *testing.T) {
+			if !result.Passed() {
+				t.Error(result)
+			}
+		})
+	}
+}
+
+func evaluate(policies []policy.Policy, c Case, coverage *policy.Coverage) (policy.Explanation, error) {
+	user := []byte("{}")
+	if c.User != nil {
+		var err error
+		if user, err = json.Marshal(c.User); err != nil {
+			return policy.Explanation{}, fmt.Errorf("invalid user: %w", err)
+		}
+	}
+	properties, err := toProperty(c.Properties)
+	if err != nil {
+		return policy.Explanation{}, err
+	}
+
+	pv := policy.New()
+	pv.Policies = policies
+	pv.Coverage = coverage
+	pv.UserPropertyGetter = policy.NewDefaultUserPropertyGetter(string(user))
+	pv.SetResource(c.Resource)
+	pv.SetAction(c.Action)
+	pv.AddProperties(properties)
+	return pv.ExplainAccess()
+}
+
+// toProperty sorts the properties by the type of their value.
+func toProperty(properties map[string]interface{}) (policy.Property, error) {
+	var property policy.Property
+	for key, value := range properties {
+		switch v := value.(type) {
+		case string:
+			if property.String == nil {
+				property.String = make(map[string]string)
+			}
+			property.String[key] = v
+		case time.Time:
+			if property.String == nil {
+				property.String = make(map[string]string)
+			}
+			property.String[key] = v.Format(time.RFC3339)
+		case int:
+			if property.Integer == nil {
+				property.Integer = make(map[string]int)
+			}
+			property.Integer[key] = v
+		case float64:
+			if property.Float == nil {
+				property.Float = make(map[string]float64)
+			}
+			property.Float[key] = v
+		case bool:
+			if property.Boolean == nil {
+				property.Boolean = make(map[string]bool)
+			}
+			property.Boolean[key] = v
+		default:
+			return policy.Property{}, fmt.Errorf("property %s: unsupported value of type %T", key, value)
+		}
+	}
+	return property, nil
+}
@@ -0,0 +1,177 @@
+package policytest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golfz/policy/v2"
+)
+
+func TestTest(t *testing.T) {
+	Test(t, "test_data/suite.yaml")
+}
+
+func TestRunFile(t *testing.T) {
+	// Act
+	results, err := RunFile("test_data/suite.json")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("want 2 results, but got %d", len(results))
+	}
+	if !results[0].Passed() {
+		t.Errorf("want passed, but got %s", results[0])
+	}
+	want := "FAIL wrong expectation: want ALLOW, but got denied: no statement matched"
+	if results[1].Passed() || results[1].String() != want {
+		t.Errorf("want %q, but got %q", want, results[1])
+	}
+}
+
+func TestRunFileWithCoverage(t *testing.T) {
+	// Arrange
+	coverage := policy.NewCoverage()
+
+	// Act
+	_, err := RunFileWithCoverage("test_data/suite.json", coverage)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	report := coverage.Report()
+	want := policy.CoverageCount{Covered: 2, Total: 3}
+	if report.Statements != want {
+		t.Errorf("want %v, but got %v", want, report.Statements)
+	}
+}
+
+func TestRunFile_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing suite", "test_data/does-not-exist.yaml"},
+		{"not a suite", "test_data/policies.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := RunFile(tt.path)
+
+			// Assert
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
+
+func TestParseSuite(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantCases []Case
+		wantErr   string
+	}{
+		{
+			name: "yaml",
+			data: "cases:\n  - resource: res:::doc\n    action: act:::doc:read\n    properties: {prop:::doc:pages: 3}\n    expect: allow\n",
+			wantCases: []Case{
+				{Name: "case 0", Resource: "res:::doc", Action: "act:::doc:read", Properties: map[string]interface{}{"prop:::doc:pages": 3}, Expect: ExpectAllow},
+			},
+		},
+		{
+			name: "json",
+			data: `{"cases": [{"name": "read", "user": {"id": "u1"}, "expect": "DENY"}]}`,
+			wantCases: []Case{
+				{Name: "read", User: map[string]interface{}{"id": "u1"}, Expect: ExpectDeny},
+			},
+		},
+		{
+			name:    "invalid expect",
+			data:    "cases:\n  - name: read\n    expect: maybe\n",
+			wantErr: `read: expect must be ALLOW or DENY, but got "MAYBE"`,
+		},
+		{
+			name:    "unsupported property",
+			data:    "cases:\n  - name: read\n    properties: {prop:::doc:tags: [a, b]}\n    expect: ALLOW\n",
+			wantErr: "read: property prop:::doc:tags: unsupported value of type []interface {}",
+		},
+		{
+			name:    "unknown field",
+			data:    "cases:\n  - name: read\n    expected: ALLOW\n",
+			wantErr: "field expected not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := ParseSuite([]byte(tt.data))
+
+			// Assert
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("want %q, but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want nil, but got %v", err)
+			}
+			if !reflect.DeepEqual(got.Cases, tt.wantCases) {
+				t.Errorf("want %+v, but got %+v", tt.wantCases, got.Cases)
+			}
+		})
+	}
+}
+
+func TestToProperty(t *testing.T) {
+	// Arrange
+	properties := map[string]interface{}{"s": "a", "i": 1, "f": 1.5, "b": true}
+
+	// Act
+	got, err := toProperty(properties)
+
+	// Assert
+	want := policy.Property{
+		String:  map[string]string{"s": "a"},
+		Integer: map[string]int{"i": 1},
+		Float:   map[string]float64{"f": 1.5},
+		Boolean: map[string]bool{"b": true},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v, %v", want, got, err)
+	}
+}
+
+func TestResult_Passed(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   bool
+	}{
+		{"allowed as expected", Result{Case: Case{Expect: ExpectAllow}, Explanation: policy.Explanation{Allowed: true}}, true},
+		{"denied as expected", Result{Case: Case{Expect: ExpectDeny}}, true},
+		{"unexpected decision", Result{Case: Case{Expect: ExpectDeny}, Explanation: policy.Explanation{Allowed: true}}, false},
+		{"error", Result{Case: Case{Expect: ExpectDeny}, Err: policy.ErrInvalidPolicy}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := tt.result.Passed()
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("want %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
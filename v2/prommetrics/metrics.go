@@ -0,0 +1,235 @@
+// Package prommetrics exposes the Metrics of the validators in the Prometheus text format,
+// without a dependency on the Prometheus client library.
+//
+//	metrics := prommetrics.New()
+//	validator.Metrics = metrics
+//	mux.Handle("GET /metrics", metrics)
+//
+// Metrics:
+//
+//	policy_decisions_total{resource, action, decision}         counter, decision is allowed, denied or error
+//	policy_decision_duration_seconds{resource, action}         histogram of the time of the decisions
+//	policy_validation_function_errors_total{function}          counter of the errors of the validation functions
+//	policy_post_validator_denials_total                        counter of the decisions denied by a post validator
+//
+// The resource and the action of a request may have an unbounded number of values, e.g. with the ID of an object
+// in the resource of httpauth.PathResource. Set Labels to map them to a few values, the number of series
+// is limited by MaxSeries.
+package prommetrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golfz/policy/v2"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the duration histogram, from 100µs to 1s.
+var DefaultBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultMaxSeries is the default maximum number of resource and action pairs.
+const DefaultMaxSeries = 1000
+
+// otherLabel is the resource and the action of the decisions after the maximum number of series.
+const otherLabel = "other"
+
+const (
+	decisionAllowed = "allowed"
+	decisionDenied  = "denied"
+	decisionError   = "error"
+)
+
+// Metrics is a policy.Metrics that keeps the measurements in memory, and an http.Handler that writes them
+// in the Prometheus text format. It is safe for concurrent use.
+type Metrics struct {
+	// Labels maps the resource and the action of a decision to the labels of its series, e.g. to remove
+	// the ID of an object from the resource. The default keeps them. It must be set before the first decision.
+	Labels func(resource, action string) (string, string)
+	// MaxSeries is the maximum number of resource and action pairs, the decisions of the other pairs
+	// are counted with the resource and the action "other". It is DefaultMaxSeries after New.
+	MaxSeries int
+
+	buckets []float64
+
+	mu                       sync.Mutex
+	decisions                map[decisionKey]uint64
+	durations                map[resourceAction]*histogram
+	validationFunctionErrors map[string]uint64
+	postValidatorDenials     uint64
+}
+
+var _ policy.Metrics = (*Metrics)(nil)
+
+type resourceAction struct {
+	resource string
+	action   string
+}
+
+type decisionKey struct {
+	resourceAction
+	decision string
+}
+
+type histogram struct {
+	// counts are the observations of each bucket, not cumulative, the last one is +Inf.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// New creates the metrics with the upper bounds of the duration histogram in seconds, DefaultBuckets if there are none.
+func New(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		MaxSeries:                DefaultMaxSeries,
+		buckets:                  buckets,
+		decisions:                make(map[decisionKey]uint64),
+		durations:                make(map[resourceAction]*histogram),
+		validationFunctionErrors: make(map[string]uint64),
+	}
+}
+
+func (m *Metrics) ObserveDecision(resource, action string, allowed bool, err error, duration time.Duration) {
+	decision := decisionDenied
+	if err != nil {
+		decision = decisionError
+	} else if allowed {
+		decision = decisionAllowed
+	}
+	if m.Labels != nil {
+		resource, action = m.Labels(resource, action)
+	}
+	key := resourceAction{resource: resource, action: action}
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.durations[key]; !ok && m.MaxSeries > 0 && len(m.durations) >= m.MaxSeries {
+		key = resourceAction{resource: otherLabel, action: otherLabel}
+	}
+	m.decisions[decisionKey{resourceAction: key, decision: decision}]++
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		m.durations[key] = h
+	}
+	h.counts[sort.SearchFloat64s(m.buckets, seconds)]++
+	h.sum += seconds
+	h.count++
+}
+
+func (m *Metrics) ValidationFunctionError(function string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validationFunctionErrors[function]++
+}
+
+func (m *Metrics) PostValidatorDenied() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.postValidatorDenials++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format, sorted by their labels.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	m.mu.Lock()
+	m.write(&buf)
+	m.mu.Unlock()
+	return buf.WriteTo(w)
+}
+
+func (m *Metrics) write(buf *bytes.Buffer) {
+	writeHeader(buf, "policy_decisions_total", "counter", "Authorization decisions by resource, action and decision.")
+	decisions := make([]decisionKey, 0, len(m.decisions))
+	for key := range m.decisions {
+		decisions = append(decisions, key)
+	}
+	sort.Slice(decisions, func(i, j int) bool {
+		if decisions[i].resourceAction != decisions[j].resourceAction {
+			return decisions[i].resourceAction.less(decisions[j].resourceAction)
+		}
+		return decisions[i].decision < decisions[j].decision
+	})
+	for _, key := range decisions {
+		fmt.Fprintf(buf, "policy_decisions_total{resource=%s,action=%s,decision=%s} %d\n",
+			quote(key.resource), quote(key.action), quote(key.decision), m.decisions[key])
+	}
+
+	writeHeader(buf, "policy_decision_duration_seconds", "histogram", "Time of the authorization decisions in seconds.")
+	durations := make([]resourceAction, 0, len(m.durations))
+	for key := range m.durations {
+		durations = append(durations, key)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i].less(durations[j]) })
+	for _, key := range durations {
+		h := m.durations[key]
+		labels := fmt.Sprintf("resource=%s,action=%s", quote(key.resource), quote(key.action))
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(m.buckets) {
+				le = formatFloat(m.buckets[i])
+			}
+			fmt.Fprintf(buf, "policy_decision_duration_seconds_bucket{%s,le=%s} %d\n", labels, quote(le), cumulative)
+		}
+		fmt.Fprintf(buf, "policy_decision_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(buf, "policy_decision_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	writeHeader(buf, "policy_validation_function_errors_total", "counter", "Errors returned by the validation functions.")
+	functions := make([]string, 0, len(m.validationFunctionErrors))
+	for function := range m.validationFunctionErrors {
+		functions = append(functions, function)
+	}
+	sort.Strings(functions)
+	for _, function := range functions {
+		fmt.Fprintf(buf, "policy_validation_function_errors_total{function=%s} %d\n", quote(function), m.validationFunctionErrors[function])
+	}
+
+	writeHeader(buf, "policy_post_validator_denials_total", "counter", "Decisions allowed by the statements and denied by a post validator.")
+	fmt.Fprintf(buf, "policy_post_validator_denials_total %d\n", m.postValidatorDenials)
+}
+
+func (k resourceAction) less(other resourceAction) bool {
+	if k.resource != other.resource {
+		return k.resource < other.resource
+	}
+	return k.action < other.action
+}
+
+func writeHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quote returns the label value in double quotes, with the escapes of the text format.
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,167 @@
+package prommetrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golfz/policy/v2"
+)
+
+func TestMetrics_WriteTo(t *testing.T) {
+	// Arrange
+	m := New(0.001, 0.01)
+	m.ObserveDecision("res:::doc", "act:::doc:read", true, nil, 500*time.Microsecond)
+	m.ObserveDecision("res:::doc", "act:::doc:read", false, nil, 5*time.Millisecond)
+	m.ObserveDecision("res:::doc", "act:::doc:read", true, errors.New("error"), 50*time.Millisecond)
+	m.ObserveDecision("res:::\"quoted\"", "act:::a\\b", false, nil, time.Millisecond)
+	m.ValidationFunctionError("isMember")
+	m.ValidationFunctionError("isMember")
+	m.PostValidatorDenied()
+
+	// Act
+	var buf strings.Builder
+	n, err := m.WriteTo(&buf)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	want := `# HELP policy_decisions_total Authorization decisions by resource, action and decision.
+# TYPE policy_decisions_total counter
+policy_decisions_total{resource="res:::\"quoted\"",action="act:::a\\b",decision="denied"} 1
+policy_decisions_total{resource="res:::doc",action="act:::doc:read",decision="allowed"} 1
+policy_decisions_total{resource="res:::doc",action="act:::doc:read",decision="denied"} 1
+policy_decisions_total{resource="res:::doc",action="act:::doc:read",decision="error"} 1
+# HELP policy_decision_duration_seconds Time of the authorization decisions in seconds.
+# TYPE policy_decision_duration_seconds histogram
+policy_decision_duration_seconds_bucket{resource="res:::\"quoted\"",action="act:::a\\b",le="0.001"} 1
+policy_decision_duration_seconds_bucket{resource="res:::\"quoted\"",action="act:::a\\b",le="0.01"} 1
+policy_decision_duration_seconds_bucket{resource="res:::\"quoted\"",action="act:::a\\b",le="+Inf"} 1
+policy_decision_duration_seconds_sum{resource="res:::\"quoted\"",action="act:::a\\b"} 0.001
+policy_decision_duration_seconds_count{resource="res:::\"quoted\"",action="act:::a\\b"} 1
+policy_decision_duration_seconds_bucket{resource="res:::doc",action="act:::doc:read",le="0.001"} 1
+policy_decision_duration_seconds_bucket{resource="res:::doc",action="act:::doc:read",le="0.01"} 2
+policy_decision_duration_seconds_bucket{resource="res:::doc",action="act:::doc:read",le="+Inf"} 3
+policy_decision_duration_seconds_sum{resource="res:::doc",action="act:::doc:read"} 0.0555
+policy_decision_duration_seconds_count{resource="res:::doc",action="act:::doc:read"} 3
+# HELP policy_validation_function_errors_total Errors returned by the validation functions.
+# TYPE policy_validation_function_errors_total counter
+policy_validation_function_errors_total{function="isMember"} 2
+# HELP policy_post_validator_denials_total Decisions allowed by the statements and denied by a post validator.
+# TYPE policy_post_validator_denials_total counter
+policy_post_validator_denials_total 1
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want\n%s\nbut got\n%s", want, got)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("want %d, but got %d", buf.Len(), n)
+	}
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	// Arrange
+	m := New()
+	pv := policy.New()
+	pv.Policies = []policy.Policy{{PolicyID: "p", Statements: []policy.Statement{
+		{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+	}}}
+	pv.Metrics = m
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+	_, _ = pv.IsAccessAllowed()
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	rec := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	if rec.Code != http.StatusOK {
+		t.Errorf("want %d, but got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("want %q, but got %q", contentType, got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`policy_decisions_total{resource="res:::doc",action="act:::doc:read",decision="allowed"} 1`,
+		`policy_decision_duration_seconds_count{resource="res:::doc",action="act:::doc:read"} 1`,
+		`policy_decision_duration_seconds_bucket{resource="res:::doc",action="act:::doc:read",le="0.0001"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want %s in\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_Labels(t *testing.T) {
+	// Arrange
+	m := New()
+	m.Labels = func(resource, action string) (string, string) {
+		return resource[:strings.LastIndex(resource, ":")], action
+	}
+
+	// Act
+	m.ObserveDecision("res:::documents:1", "act:::get", true, nil, time.Millisecond)
+	m.ObserveDecision("res:::documents:2", "act:::get", true, nil, time.Millisecond)
+
+	// Assert
+	var buf strings.Builder
+	_, _ = m.WriteTo(&buf)
+	want := `policy_decisions_total{resource="res:::documents",action="act:::get",decision="allowed"} 2`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("want %s in\n%s", want, buf.String())
+	}
+}
+
+func TestMetrics_MaxSeries(t *testing.T) {
+	// Arrange
+	m := New()
+	m.MaxSeries = 2
+
+	// Act
+	for _, resource := range []string{"res:::doc:1", "res:::doc:2", "res:::doc:3", "res:::doc:4", "res:::doc:1"} {
+		m.ObserveDecision(resource, "act:::get", false, nil, time.Millisecond)
+	}
+
+	// Assert
+	var buf strings.Builder
+	_, _ = m.WriteTo(&buf)
+	for _, want := range []string{
+		`policy_decisions_total{resource="res:::doc:1",action="act:::get",decision="denied"} 2`,
+		`policy_decisions_total{resource="res:::doc:2",action="act:::get",decision="denied"} 1`,
+		`policy_decisions_total{resource="other",action="other",decision="denied"} 2`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %s in\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "res:::doc:3") {
+		t.Errorf("want no series after the maximum, but got\n%s", buf.String())
+	}
+}
+
+func TestNew_SortsBuckets(t *testing.T) {
+	// Arrange
+	buckets := []float64{1, 0.1}
+
+	// Act
+	m := New(buckets...)
+	m.ObserveDecision("res:::doc", "act:::doc:read", true, nil, 500*time.Millisecond)
+
+	// Assert
+	var buf strings.Builder
+	_, _ = m.WriteTo(&buf)
+	if !strings.Contains(buf.String(), `le="0.1"} 0`) || !strings.Contains(buf.String(), `le="1"} 1`) {
+		t.Errorf("want sorted buckets, but got\n%s", buf.String())
+	}
+	if buckets[0] != 1 {
+		t.Errorf("want the buckets of the caller unchanged, but got %v", buckets)
+	}
+}
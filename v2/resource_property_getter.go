@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loadResourceProperties returns a copy of the resource with the properties referenced by the statements
+// that are loaded with the ResourcePropertyGetter. Properties already set on the resource are not loaded.
+func (pv *policyValidator) loadResourceProperties(statements []Statement, res Resource) (Resource, error) {
+	if pv.ResourcePropertyGetter == nil {
+		return res, nil
+	}
+
+	var keys []string
+	for _, key := range referencedPropertyKeys(statements) {
+		if _, ok := lookupProperty(res.Properties, key); !ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	prop := Property{
+		String:  copyMap(res.Properties.String),
+		Integer: copyMap(res.Properties.Integer),
+		Float:   copyMap(res.Properties.Float),
+		Boolean: copyMap(res.Properties.Boolean),
+	}
+	for _, key := range keys {
+		value, err := pv.ResourcePropertyGetter.GetResourceProperty(key)
+		if err != nil {
+			return res, fmt.Errorf("cannot get resource property %s: %w", key, err)
+		}
+		if err := addJSONPropertyValue(&prop, key, value); err != nil {
+			return res, err
+		}
+	}
+
+	res.Properties = prop
+	return res, nil
+}
+
+// referencedPropertyKeys returns the unique "prop:::" keys used by the conditions of the statements.
+func referencedPropertyKeys(statements []Statement) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if strings.HasPrefix(key, propertyPrefix) && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, stmt := range statements {
+		if stmt.Conditions == nil {
+			continue
+		}
+		for _, conditions := range []map[string]Comparator{stmt.Conditions.AtLeastOne, stmt.Conditions.MustHaveAll} {
+			for key, comparator := range conditions {
+				add(key)
+				if comparator.ValidationFunc != nil && comparator.ValidationFunc.PropArg != nil {
+					add(*comparator.ValidationFunc.PropArg)
+				}
+				if comparator.Expression != nil {
+					for _, ref := range comparator.Expression.references() {
+						add(ref)
+					}
+				}
+			}
+		}
+	}
+	return keys
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
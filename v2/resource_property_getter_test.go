@@ -0,0 +1,228 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type MockResourceGetter struct {
+	Values map[string]interface{}
+	Err    error
+	Calls  map[string]int
+}
+
+func (mock *MockResourceGetter) GetResourceProperty(key string) (interface{}, error) {
+	if mock.Calls == nil {
+		mock.Calls = make(map[string]int)
+	}
+	mock.Calls[key]++
+	return mock.Values[key], mock.Err
+}
+
+func lazyPropertyPolicies() []Policy {
+	expr, _ := CompileExpression(`prop.doc.size < 100`)
+	return []Policy{
+		{
+			Statements: []Statement{
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+							"prop:::doc:rule":  {Expression: expr},
+						},
+					},
+				},
+				{
+					Effect:   statementEffectDeny,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:read"},
+					Conditions: &Condition{
+						AtLeastOne: map[string]Comparator{
+							"prop:::doc:owner":  {StringEqual: ptr("blocked")},
+							"prop:::doc:locked": {BooleanEqual: ptr(true)},
+						},
+					},
+				},
+				{
+					Effect:   statementEffectAllow,
+					Resource: "res:::doc",
+					Actions:  []string{"act:::doc:delete"},
+					Conditions: &Condition{
+						MustHaveAll: map[string]Comparator{
+							"prop:::doc:expensive": {StringEqual: ptr("yes")},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestIsAccessAllowed_ResourcePropertyGetter(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    map[string]interface{}
+		want      bool
+		wantCalls []string
+	}{
+		{
+			name:      "all properties loaded, expect ALLOWED",
+			values:    map[string]interface{}{"prop:::doc:owner": "u1", "prop:::doc:size": 10, "prop:::doc:locked": false},
+			want:      ALLOWED,
+			wantCalls: []string{"prop:::doc:locked", "prop:::doc:owner", "prop:::doc:rule", "prop:::doc:size"},
+		},
+		{
+			name:      "deny property loaded, expect DENIED",
+			values:    map[string]interface{}{"prop:::doc:owner": "u1", "prop:::doc:size": 10.5, "prop:::doc:locked": true},
+			want:      DENIED,
+			wantCalls: []string{"prop:::doc:locked", "prop:::doc:owner", "prop:::doc:rule", "prop:::doc:size"},
+		},
+		{
+			name:      "missing property, expect DENIED",
+			values:    map[string]interface{}{"prop:::doc:owner": "u1"},
+			want:      DENIED,
+			wantCalls: []string{"prop:::doc:locked", "prop:::doc:owner", "prop:::doc:rule", "prop:::doc:size"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			getter := &MockResourceGetter{Values: tt.values}
+			pv := New()
+			pv.Policies = lazyPropertyPolicies()
+			pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+			pv.ResourcePropertyGetter = getter
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+
+			// Act
+			result, err := pv.IsAccessAllowed()
+
+			// Assert
+			if err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %v, but want %v", result, tt.want)
+			}
+			var calls []string
+			for key, count := range getter.Calls {
+				if count != 1 {
+					t.Errorf("%s was loaded %d times, want 1", key, count)
+				}
+				calls = append(calls, key)
+			}
+			sort.Strings(calls)
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("loaded %v, but want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsAccessAllowed_ResourcePropertyGetter_SkipsSetProperties(t *testing.T) {
+	// Arrange
+	getter := &MockResourceGetter{Values: map[string]interface{}{"prop:::doc:expensive": "yes"}}
+	pv := New()
+	pv.Policies = lazyPropertyPolicies()
+	pv.ResourcePropertyGetter = getter
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:delete")
+	pv.AddPropertyString("prop:::doc:expensive", "no")
+
+	// Act
+	result, err := pv.IsAccessAllowed()
+
+	// Assert
+	if err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+	if result != DENIED {
+		t.Errorf("got %v, but want %v", result, DENIED)
+	}
+	if len(getter.Calls) != 0 {
+		t.Errorf("want no calls, but got %v", getter.Calls)
+	}
+}
+
+func TestIsAccessAllowed_ResourcePropertyGetter_Error(t *testing.T) {
+	// Arrange
+	pv := New()
+	pv.Policies = lazyPropertyPolicies()
+	pv.ResourcePropertyGetter = &MockResourceGetter{Err: errors.New("database is down")}
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:delete")
+
+	// Act
+	result, err := pv.IsAccessAllowed()
+
+	// Assert
+	if err == nil {
+		t.Error("want error, but got nil")
+	}
+	if result != DENIED {
+		t.Errorf("got %v, but want %v", result, DENIED)
+	}
+}
+
+func TestIsAccessAllowed_ResourcePropertyGetter_PostValidator(t *testing.T) {
+	// Arrange
+	postValidator := &MockPostValidator{Result: ALLOWED}
+	pv := New()
+	pv.Policies = lazyPropertyPolicies()
+	pv.ResourcePropertyGetter = ResourcePropertyGetterFunc(func(key string) (interface{}, error) {
+		return "yes", nil
+	})
+	pv.AddPostExecutor(postValidator)
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:delete")
+
+	// Act
+	result, err := pv.IsAccessAllowed()
+
+	// Assert
+	if err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+	if result != ALLOWED {
+		t.Errorf("got %v, but want %v", result, ALLOWED)
+	}
+	if postValidator.Resource.Properties.String["prop:::doc:expensive"] != "yes" {
+		t.Errorf("want loaded property in post validator, but got %v", postValidator.Resource.Properties)
+	}
+	if pv.resource.Properties.String != nil {
+		t.Errorf("want resource of validator unchanged, but got %v", pv.resource.Properties)
+	}
+}
+
+func TestReferencedPropertyKeys(t *testing.T) {
+	expr, _ := CompileExpression(`prop.a.b == user.id && prop.a.c`)
+	statements := []Statement{
+		{Conditions: nil},
+		{
+			Conditions: &Condition{
+				AtLeastOne: map[string]Comparator{
+					"prop:::a:b": {Expression: expr},
+				},
+				MustHaveAll: map[string]Comparator{
+					"prop:::a:d": {ValidationFunc: &ValidationFunc{Function: "fn", PropArg: ptr("prop:::a:e")}},
+					"sys:::x":    {StringEqual: ptr("x")},
+				},
+			},
+		},
+	}
+	want := []string{"prop:::a:b", "prop:::a:c", "prop:::a:d", "prop:::a:e"}
+
+	got := referencedPropertyKeys(statements)
+
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, but want %v", got, want)
+	}
+}
@@ -0,0 +1,264 @@
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+//go:generate go test -run TestPolicySchema_UpToDate -update-schema .
+
+//go:embed policy.schema.json
+var policySchema []byte
+
+// PolicySchema returns the JSON Schema of a policy file in JSON, which holds a policy or an array of policies.
+// Editors and CI pipelines can check the files with it before they are loaded.
+//
+// The schema checks the structure of the policies, the effects and the prefixes of resources, actions and
+// user properties, that a ValidationFunc has exactly one argument, and that a comparator is not used in a policy
+// of an earlier Version than its own, e.g. StringPrefix in a policy of Version 1. ValidatePolicies also checks the
+// expressions and the rules that a schema cannot describe.
+func PolicySchema() []byte {
+	return append([]byte(nil), policySchema...)
+}
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaTypes are the types of a policy, each is a definition of the schema.
+var schemaTypes = []reflect.Type{
+	reflect.TypeOf(Policy{}),
+	reflect.TypeOf(Statement{}),
+	reflect.TypeOf(Condition{}),
+	reflect.TypeOf(Comparator{}),
+	reflect.TypeOf(ValidationFunc{}),
+}
+
+// schemaFields describe the fields of the policy types, by type and field name, in addition to their Go types.
+// Each field must be described, so that a new field is not left out of the schema. A required field may not be null.
+var schemaFields = map[string]map[string]interface{}{
+	"Policy.Version":    {"description": "The version of the policy syntax, no version is version 1.", "minimum": 0, "maximum": LatestPolicyVersion},
+	"Policy.PolicyID":   {"description": "The ID of the policy."},
+	"Policy.Statements": {"description": "The statements of the policy, a Deny statement that matches wins over Allow statements."},
+
+	"Statement.Effect":     {"description": "Allow or Deny.", "required": true, "enum": []string{statementEffectAllow, statementEffectDeny}},
+	"Statement.Resource":   {"description": "The resource of the statement.", "required": true, "pattern": "^" + resourcePrefix},
+	"Statement.Actions":    {"description": "The actions of the statement.", "required": true, "minItems": 1, "items": map[string]interface{}{"pattern": "^" + actionPrefix}},
+	"Statement.Conditions": {"description": "The conditions of the statement, no conditions always match."},
+
+	"Condition.AtLeastOne":  {"description": "At least one comparator must match, by the key of the property."},
+	"Condition.MustHaveAll": {"description": "All comparators must match, by the key of the property."},
+
+	"Comparator.StringIn":      {"description": "The string property is one of the values."},
+	"Comparator.StringEqual":   {"description": "The string property equals the value."},
+	"Comparator.StringPrefix":  {"description": "The string property starts with the value, from Version 2."},
+	"Comparator.IntegerIn":     {"description": "The integer property is one of the values."},
+	"Comparator.IntegerEqual":  {"description": "The integer property equals the value."},
+	"Comparator.FloatIn":       {"description": "The float property is one of the values."},
+	"Comparator.FloatEqual":    {"description": "The float property equals the value."},
+	"Comparator.BooleanEqual":  {"description": "The boolean property equals the value."},
+	"Comparator.UserPropEqual": {"description": "The property equals the user property.", "pattern": "^" + userPropertyPrefix},
+	"Comparator.Expression": {
+		"description": "The expression is true.",
+		"examples":    []string{`prop.amount < 100 && user.role == "admin"`, `prop.owner == user.id || !prop.private`},
+	},
+	"Comparator.ValidationFunc": {"description": "The validation function of the validator returns true."},
+
+	"ValidationFunc.Function":  {"description": "The name of the validation function.", "required": true},
+	"ValidationFunc.PropArg":   {"description": "The key of the property passed to the function."},
+	"ValidationFunc.UserArg":   {"description": "The user property passed to the function.", "pattern": "^" + userPropertyPrefix},
+	"ValidationFunc.StringArg": {"description": "The string passed to the function."},
+}
+
+// generatePolicySchema generates the JSON Schema of PolicySchema from the policy types.
+func generatePolicySchema() ([]byte, error) {
+	defs := make(map[string]interface{}, len(schemaTypes))
+	for _, t := range schemaTypes {
+		def, err := structSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		defs[t.Name()] = def
+	}
+
+	// at least one comparison, and a ValidationFunc has exactly one argument
+	defs["Comparator"].(map[string]interface{})["minProperties"] = 1
+	args := []string{"PropArg", "UserArg", "StringArg"}
+	oneOf := make([]interface{}, len(args))
+	for i, arg := range args {
+		oneOf[i] = map[string]interface{}{
+			"required":   []string{arg},
+			"properties": map[string]interface{}{arg: map[string]interface{}{"type": "string"}},
+		}
+	}
+	defs["ValidationFunc"].(map[string]interface{})["oneOf"] = oneOf
+
+	// a comparator of a later version is not allowed
+	if rules := versionSchemaRules(); len(rules) > 0 {
+		defs["Policy"].(map[string]interface{})["allOf"] = rules
+	}
+
+	policyRef := schemaRef(reflect.TypeOf(Policy{}))
+	schema := map[string]interface{}{
+		"$schema":     jsonSchemaDraft,
+		"title":       "Policy",
+		"description": "A policy or an array of policies.",
+		"oneOf": []interface{}{
+			policyRef,
+			map[string]interface{}{"type": "array", "items": policyRef},
+		},
+		"$defs": defs,
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(schema); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// versionSchemaRules returns a rule for each version without some comparators:
+// if the policy has the version, its comparators do not have them.
+func versionSchemaRules() []interface{} {
+	var rules []interface{}
+	comparatorType := reflect.TypeOf(Comparator{})
+	for _, spec := range policySpecs {
+		forbidden := make(map[string]interface{})
+		for i := 0; i < comparatorType.NumField(); i++ {
+			if name := comparatorType.Field(i).Name; !isContainsInList(spec.comparators, name) {
+				// null is the same as no comparison
+				forbidden[name] = map[string]interface{}{"type": "null"}
+			}
+		}
+		if len(forbidden) == 0 {
+			continue
+		}
+
+		versions := []int{spec.version}
+		if spec.version == PolicyVersion1 {
+			versions = []int{0, PolicyVersion1}
+		}
+		comparators := map[string]interface{}{"additionalProperties": map[string]interface{}{"properties": forbidden}}
+		rules = append(rules, map[string]interface{}{
+			"if": map[string]interface{}{"properties": map[string]interface{}{"Version": map[string]interface{}{"enum": versions}}},
+			"then": map[string]interface{}{"properties": map[string]interface{}{"Statements": map[string]interface{}{
+				"items": map[string]interface{}{"properties": map[string]interface{}{"Conditions": map[string]interface{}{
+					"properties": map[string]interface{}{"AtLeastOne": comparators, "MustHaveAll": comparators},
+				}}},
+			}}},
+		})
+	}
+	return rules
+}
+
+func structSchema(t reflect.Type) (map[string]interface{}, error) {
+	properties := make(map[string]interface{}, t.NumField())
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := t.Name() + "." + field.Name
+		annotations, ok := schemaFields[name]
+		if !ok {
+			return nil, fmt.Errorf("schema: field %s is not described", name)
+		}
+
+		property, err := typeSchema(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("schema: field %s: %w", name, err)
+		}
+		for key, value := range annotations {
+			if key == "required" {
+				required = append(required, field.Name)
+				if types, ok := property["type"].([]string); ok {
+					property["type"] = types[0]
+				}
+				continue
+			}
+			if items, ok := value.(map[string]interface{}); ok && key == "items" {
+				for itemKey, itemValue := range items {
+					property["items"].(map[string]interface{})[itemKey] = itemValue
+				}
+				continue
+			}
+			property[key] = value
+		}
+		properties[field.Name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// typeSchema returns the schema of a value of the type in JSON, a pointer, a slice or a map may be null.
+func typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(schema), nil
+	case reflect.Slice:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": items}, nil
+	case reflect.Map:
+		values, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": values}, nil
+	case reflect.Struct:
+		return schemaRef(t), nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+func schemaRef(t reflect.Type) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+}
+
+// nullable returns the schema that also accepts null.
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	switch v := schema["type"].(type) {
+	case string:
+		schema["type"] = []string{v, "null"}
+		return schema
+	case []string:
+		return schema
+	default:
+		return map[string]interface{}{"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}}}
+	}
+}
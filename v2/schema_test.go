@@ -0,0 +1,214 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var updateSchema = flag.Bool("update-schema", false, "write policy.schema.json from the policy types")
+
+func TestPolicySchema_UpToDate(t *testing.T) {
+	// Arrange
+	want, err := generatePolicySchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updateSchema {
+		if err := os.WriteFile("policy.schema.json", want, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// Act
+	got := PolicySchema()
+
+	// Assert
+	if !bytes.Equal(got, want) {
+		t.Errorf("policy.schema.json is out of date with the policy types, run go generate")
+	}
+}
+
+func TestPolicySchema_Fields(t *testing.T) {
+	// Arrange
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+
+	// Act
+	err := json.Unmarshal(PolicySchema(), &schema)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	for _, typ := range schemaTypes {
+		def, ok := schema.Defs[typ.Name()]
+		if !ok {
+			t.Errorf("want definition of %s, but got none", typ.Name())
+			continue
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			if _, ok := def.Properties[typ.Field(i).Name]; !ok {
+				t.Errorf("want property %s.%s, but got none", typ.Name(), typ.Field(i).Name)
+			}
+		}
+		if len(def.Properties) != typ.NumField() {
+			t.Errorf("want %d properties of %s, but got %d", typ.NumField(), typ.Name(), len(def.Properties))
+		}
+	}
+}
+
+func TestStructSchema_UndescribedField(t *testing.T) {
+	// Arrange
+	type Undescribed struct {
+		Name string
+	}
+
+	// Act
+	_, err := structSchema(reflect.TypeOf(Undescribed{}))
+
+	// Assert
+	if err == nil || err.Error() != "schema: field Undescribed.Name is not described" {
+		t.Errorf("want field is not described, but got %v", err)
+	}
+}
+
+func TestPolicySchema_Patterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		field   string
+		value   string
+		isMatch bool
+	}{
+		{"resource", "Statement", "Resource", "res:::doc", true},
+		{"resource without prefix", "Statement", "Resource", "doc", false},
+		{"user property", "Comparator", "UserPropEqual", "user:::id", true},
+		{"user property without prefix", "Comparator", "UserPropEqual", "prop:::id", false},
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Pattern string `json:"pattern"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(PolicySchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			pattern := regexp.MustCompile(schema.Defs[tt.def].Properties[tt.field].Pattern)
+
+			// Act
+			got := pattern.MatchString(tt.value)
+
+			// Assert
+			if got != tt.isMatch {
+				t.Errorf("want %v, but got %v", tt.isMatch, got)
+			}
+		})
+	}
+}
+
+func TestPolicySchema_ExpressionExamples(t *testing.T) {
+	// Arrange
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Examples []string `json:"examples"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(PolicySchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	examples := schema.Defs["Comparator"].Properties["Expression"].Examples
+	if len(examples) == 0 {
+		t.Fatal("want examples of Expression, but got none")
+	}
+
+	for _, example := range examples {
+		t.Run(example, func(t *testing.T) {
+			// Act
+			_, err := CompileExpression(example)
+
+			// Assert
+			if err != nil {
+				t.Errorf("want nil, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicySchema_VersionComparators(t *testing.T) {
+	// Arrange
+	var schema struct {
+		Defs map[string]struct {
+			AllOf []struct {
+				If struct {
+					Properties struct {
+						Version struct {
+							Enum []int `json:"enum"`
+						} `json:"Version"`
+					} `json:"properties"`
+				} `json:"if"`
+				Then struct {
+					Properties struct {
+						Statements struct {
+							Items struct {
+								Properties struct {
+									Conditions struct {
+										Properties map[string]struct {
+											AdditionalProperties struct {
+												Properties map[string]map[string]interface{} `json:"properties"`
+											} `json:"additionalProperties"`
+										} `json:"properties"`
+									} `json:"Conditions"`
+								} `json:"properties"`
+							} `json:"items"`
+						} `json:"Statements"`
+					} `json:"properties"`
+				} `json:"then"`
+			} `json:"allOf"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(PolicySchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	forbidden := make(map[int][]string)
+	for _, rule := range schema.Defs["Policy"].AllOf {
+		conditions := rule.Then.Properties.Statements.Items.Properties.Conditions.Properties
+		for _, version := range rule.If.Properties.Version.Enum {
+			for _, quantifier := range []string{"AtLeastOne", "MustHaveAll"} {
+				for name, property := range conditions[quantifier].AdditionalProperties.Properties {
+					if property["type"] == "null" {
+						forbidden[version] = append(forbidden[version], quantifier+"."+name)
+					}
+				}
+			}
+		}
+	}
+
+	// Assert
+	want := map[int][]string{
+		0: {"AtLeastOne.StringPrefix", "MustHaveAll.StringPrefix"},
+		1: {"AtLeastOne.StringPrefix", "MustHaveAll.StringPrefix"},
+	}
+	if !reflect.DeepEqual(forbidden, want) {
+		t.Errorf("want %v, but got %v", want, forbidden)
+	}
+}
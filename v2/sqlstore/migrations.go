@@ -0,0 +1,72 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order. Never change a released migration, add a new one.
+//
+// Replicas may run Migrate at the same time, so a migration can run again after another replica has applied it:
+// every migration must be idempotent.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS policies (
+		policy_id TEXT NOT NULL PRIMARY KEY,
+		document TEXT NOT NULL,
+		revision BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS policy_attachments (
+		principal TEXT NOT NULL,
+		policy_id TEXT NOT NULL,
+		PRIMARY KEY (principal, policy_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS policy_store_version (
+		id INTEGER NOT NULL PRIMARY KEY,
+		version BIGINT NOT NULL
+	)`,
+	`INSERT INTO policy_store_version (id, version) VALUES (1, 0) ON CONFLICT (id) DO NOTHING`,
+}
+
+// Migrate creates or upgrades the tables of the store. It is safe to call on every start.
+func (s *Store) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS policy_schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY
+	)`)
+	if err != nil {
+		return fmt.Errorf("cannot create policy_schema_migrations: %w", err)
+	}
+
+	var applied int
+	err = s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM policy_schema_migrations`).Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("cannot read policy_schema_migrations: %w", err)
+	}
+	if applied > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than %d", applied, len(migrations))
+	}
+
+	for i := applied; i < len(migrations); i++ {
+		version := i + 1
+		err := s.withTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO policy_schema_migrations (version) VALUES (?)
+				ON CONFLICT (version) DO NOTHING`), version)
+			return err
+		})
+		if err != nil && !s.migrationApplied(ctx, version) {
+			return fmt.Errorf("cannot apply migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// migrationApplied reports whether the migration is recorded, e.g. by another replica that applied it concurrently.
+func (s *Store) migrationApplied(ctx context.Context, version int) bool {
+	var count int
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM policy_schema_migrations WHERE version = ?`), version).
+		Scan(&count)
+	return err == nil && count > 0
+}
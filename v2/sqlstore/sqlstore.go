@@ -0,0 +1,280 @@
+// Package sqlstore keeps policies in a SQL database with database/sql.
+//
+// The store works with SQLite and PostgreSQL, the caller opens the database with its driver:
+//
+//	db, err := sql.Open("postgres", dsn)
+//	store := sqlstore.New(db, sqlstore.Postgres)
+//	err = store.Migrate(ctx)
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golfz/policy/v2"
+)
+
+// ErrRevisionConflict is returned when a policy is changed since it is read.
+var ErrRevisionConflict = errors.New("policy revision conflict")
+
+// Dialect is the SQL syntax of a database.
+type Dialect struct {
+	// Placeholder returns the placeholder of the n-th argument, starting at 1.
+	Placeholder func(n int) string
+}
+
+var (
+	SQLite   = Dialect{Placeholder: func(n int) string { return "?" }}
+	Postgres = Dialect{Placeholder: policy.PostgresPlaceholder}
+)
+
+// Store is a policy.PolicyStore in a SQL database.
+//
+// Each policy has a revision, which starts at 1 and is incremented on every Put.
+// GetWithRevision and PutWithRevision use the revision to update a policy without overwriting a concurrent change.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+var _ policy.PolicyStore = (*Store)(nil)
+
+// New creates a store in the database, call Migrate before using it.
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+func (s *Store) List(ctx context.Context) ([]policy.Policy, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT document FROM policies ORDER BY policy_id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanPolicies(rows)
+}
+
+func (s *Store) Get(ctx context.Context, policyID string) (policy.Policy, error) {
+	p, _, err := s.GetWithRevision(ctx, policyID)
+	return p, err
+}
+
+// GetWithRevision returns the policy and its revision, or policy.ErrPolicyNotFound.
+func (s *Store) GetWithRevision(ctx context.Context, policyID string) (policy.Policy, int64, error) {
+	var document string
+	var revision int64
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT document, revision FROM policies WHERE policy_id = ?`), policyID).
+		Scan(&document, &revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return policy.Policy{}, 0, policy.ErrPolicyNotFound
+	}
+	if err != nil {
+		return policy.Policy{}, 0, err
+	}
+	p, err := policy.ParsePolicy([]byte(document))
+	if err != nil {
+		return policy.Policy{}, 0, fmt.Errorf("cannot parse policy %s: %w", policyID, err)
+	}
+	return p, revision, nil
+}
+
+func (s *Store) Put(ctx context.Context, p policy.Policy) error {
+	document, err := marshalPolicy(p)
+	if err != nil {
+		return err
+	}
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO policies (policy_id, document, revision) VALUES (?, ?, 1)
+			ON CONFLICT (policy_id) DO UPDATE SET document = excluded.document, revision = policies.revision + 1`),
+			p.PolicyID, document)
+		if err != nil {
+			return err
+		}
+		return s.incrementVersion(ctx, tx)
+	})
+}
+
+// PutWithRevision replaces the policy only if its revision is still the given revision, and returns the new revision.
+// A revision of 0 adds a new policy. If the policy is changed or added since, it returns ErrRevisionConflict.
+func (s *Store) PutWithRevision(ctx context.Context, p policy.Policy, revision int64) (int64, error) {
+	document, err := marshalPolicy(p)
+	if err != nil {
+		return 0, err
+	}
+	err = s.withTx(ctx, func(tx *sql.Tx) error {
+		var result sql.Result
+		var err error
+		if revision == 0 {
+			result, err = tx.ExecContext(ctx, s.rebind(`INSERT INTO policies (policy_id, document, revision) VALUES (?, ?, 1)
+				ON CONFLICT (policy_id) DO NOTHING`),
+				p.PolicyID, document)
+		} else {
+			result, err = tx.ExecContext(ctx, s.rebind(`UPDATE policies SET document = ?, revision = revision + 1
+				WHERE policy_id = ? AND revision = ?`),
+				document, p.PolicyID, revision)
+		}
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrRevisionConflict
+		}
+		return s.incrementVersion(ctx, tx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revision + 1, nil
+}
+
+func (s *Store) Delete(ctx context.Context, policyID string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM policies WHERE policy_id = ?`), policyID)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return policy.ErrPolicyNotFound
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM policy_attachments WHERE policy_id = ?`), policyID); err != nil {
+			return err
+		}
+		return s.incrementVersion(ctx, tx)
+	})
+}
+
+func (s *Store) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := s.db.QueryRowContext(ctx, `SELECT version FROM policy_store_version WHERE id = 1`).Scan(&version)
+	return version, err
+}
+
+func (s *Store) Attach(ctx context.Context, principal, policyID string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, s.rebind(`SELECT 1 FROM policies WHERE policy_id = ?`), policyID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return policy.ErrPolicyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO policy_attachments (principal, policy_id) VALUES (?, ?)
+			ON CONFLICT (principal, policy_id) DO NOTHING`),
+			principal, policyID)
+		if err != nil {
+			return err
+		}
+		return s.incrementVersionIfChanged(ctx, tx, result)
+	})
+}
+
+func (s *Store) Detach(ctx context.Context, principal, policyID string) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM policy_attachments WHERE principal = ? AND policy_id = ?`),
+			principal, policyID)
+		if err != nil {
+			return err
+		}
+		return s.incrementVersionIfChanged(ctx, tx, result)
+	})
+}
+
+func (s *Store) Attached(ctx context.Context, principal string) ([]policy.Policy, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT p.document FROM policies p
+		JOIN policy_attachments a ON a.policy_id = p.policy_id
+		WHERE a.principal = ?
+		ORDER BY p.policy_id`),
+		principal)
+	if err != nil {
+		return nil, err
+	}
+	return scanPolicies(rows)
+}
+
+func (s *Store) incrementVersion(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `UPDATE policy_store_version SET version = version + 1 WHERE id = 1`)
+	return err
+}
+
+// incrementVersionIfChanged increments the version only when the statement changed rows,
+// so that attaching twice or detaching nothing does not invalidate the caches of the readers.
+func (s *Store) incrementVersionIfChanged(ctx context.Context, tx *sql.Tx, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	return s.incrementVersion(ctx, tx)
+}
+
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebind replaces the "?" placeholders of the query with the placeholders of the dialect.
+func (s *Store) rebind(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString(s.dialect.Placeholder(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func marshalPolicy(p policy.Policy) (string, error) {
+	if p.PolicyID == "" {
+		return "", fmt.Errorf("%w: no PolicyID", policy.ErrInvalidPolicy)
+	}
+	if err := policy.ValidatePolicy(p); err != nil {
+		return "", fmt.Errorf("%w: %w", policy.ErrInvalidPolicy, err)
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func scanPolicies(rows *sql.Rows) ([]policy.Policy, error) {
+	defer rows.Close()
+
+	policies := make([]policy.Policy, 0)
+	for rows.Next() {
+		var document string
+		if err := rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		p, err := policy.ParsePolicy([]byte(document))
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
@@ -0,0 +1,321 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golfz/policy/v2"
+	_ "modernc.org/sqlite"
+)
+
+func newTestStore(t *testing.T) (*Store, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "policy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := New(db, SQLite)
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return store, db
+}
+
+func testPolicy(id, resource string) policy.Policy {
+	return policy.Policy{
+		Version:  1,
+		PolicyID: id,
+		Statements: []policy.Statement{
+			{
+				Effect:   "Allow",
+				Resource: resource,
+				Actions:  []string{"act:::doc:read"},
+			},
+		},
+	}
+}
+
+func policyIDs(policies []policy.Policy) []string {
+	ids := make([]string, 0, len(policies))
+	for _, p := range policies {
+		ids = append(ids, p.PolicyID)
+	}
+	return ids
+}
+
+func TestMigrate_Twice(t *testing.T) {
+	// Arrange
+	store, db := newTestStore(t)
+
+	// Act
+	err := store.Migrate(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	var count int
+	_ = db.QueryRow(`SELECT COUNT(*) FROM policy_schema_migrations`).Scan(&count)
+	if count != len(migrations) {
+		t.Errorf("want %d, but got %d", len(migrations), count)
+	}
+}
+
+func TestMigrate_AppliedByAnotherReplica(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, db := newTestStore(t)
+	_ = store.Put(ctx, testPolicy("p1", "res:::p1"))
+	version, _ := store.Version(ctx)
+	// another replica created the tables, but has not recorded its migrations yet
+	_, _ = db.Exec(`DELETE FROM policy_schema_migrations`)
+
+	// Act
+	err := store.Migrate(ctx)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got, err := store.Version(ctx); err != nil || got != version {
+		t.Errorf("want version %d, but got %d, %v", version, got, err)
+	}
+	if _, err := store.Get(ctx, "p1"); err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+}
+
+func TestStore_PutGetList(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	// Act
+	for _, id := range []string{"p2", "p1"} {
+		if err := store.Put(ctx, testPolicy(id, "res:::"+id)); err != nil {
+			t.Fatalf("put %s: %v", id, err)
+		}
+	}
+	got, getErr := store.Get(ctx, "p2")
+	list, listErr := store.List(ctx)
+	_, notFoundErr := store.Get(ctx, "p3")
+
+	// Assert
+	if getErr != nil || !reflect.DeepEqual(got, testPolicy("p2", "res:::p2")) {
+		t.Errorf("want p2, but got %+v, %v", got, getErr)
+	}
+	if want := []string{"p1", "p2"}; listErr != nil || !reflect.DeepEqual(policyIDs(list), want) {
+		t.Errorf("want %v, but got %v, %v", want, policyIDs(list), listErr)
+	}
+	if !errors.Is(notFoundErr, policy.ErrPolicyNotFound) {
+		t.Errorf("want ErrPolicyNotFound, but got %v", notFoundErr)
+	}
+}
+
+func TestStore_PutInvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy policy.Policy
+	}{
+		{"no PolicyID", testPolicy("", "res:::doc")},
+		{"invalid resource", testPolicy("p1", "doc")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			store, _ := newTestStore(t)
+
+			// Act
+			err := store.Put(context.Background(), tt.policy)
+
+			// Assert
+			if !errors.Is(err, policy.ErrInvalidPolicy) {
+				t.Errorf("want ErrInvalidPolicy, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestStore_PutWithRevision(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+
+	// Act
+	rev1, addErr := store.PutWithRevision(ctx, testPolicy("p1", "res:::v1"), 0)
+	_, addAgainErr := store.PutWithRevision(ctx, testPolicy("p1", "res:::other"), 0)
+	_, readRev, _ := store.GetWithRevision(ctx, "p1")
+	rev2, updateErr := store.PutWithRevision(ctx, testPolicy("p1", "res:::v2"), readRev)
+	_, staleErr := store.PutWithRevision(ctx, testPolicy("p1", "res:::stale"), readRev)
+	got, gotRev, _ := store.GetWithRevision(ctx, "p1")
+
+	// Assert
+	if addErr != nil || rev1 != 1 {
+		t.Errorf("want revision 1, but got %d, %v", rev1, addErr)
+	}
+	if !errors.Is(addAgainErr, ErrRevisionConflict) {
+		t.Errorf("want ErrRevisionConflict, but got %v", addAgainErr)
+	}
+	if updateErr != nil || rev2 != 2 {
+		t.Errorf("want revision 2, but got %d, %v", rev2, updateErr)
+	}
+	if !errors.Is(staleErr, ErrRevisionConflict) {
+		t.Errorf("want ErrRevisionConflict, but got %v", staleErr)
+	}
+	if got.Statements[0].Resource != "res:::v2" || gotRev != 2 {
+		t.Errorf("want res:::v2 at revision 2, but got %s at revision %d", got.Statements[0].Resource, gotRev)
+	}
+}
+
+func TestStore_PutIncrementsRevision(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	_ = store.Put(ctx, testPolicy("p1", "res:::v1"))
+
+	// Act
+	_ = store.Put(ctx, testPolicy("p1", "res:::v2"))
+	_, rev, err := store.GetWithRevision(ctx, "p1")
+
+	// Assert
+	if err != nil || rev != 2 {
+		t.Errorf("want revision 2, but got %d, %v", rev, err)
+	}
+}
+
+func TestStore_Attachments(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	for _, id := range []string{"p1", "p2", "p3"} {
+		_ = store.Put(ctx, testPolicy(id, "res:::"+id))
+	}
+
+	// Act
+	_ = store.Attach(ctx, "role:::admin", "p3")
+	_ = store.Attach(ctx, "role:::admin", "p1")
+	_ = store.Attach(ctx, "role:::admin", "p2")
+	attachTwiceErr := store.Attach(ctx, "role:::admin", "p1")
+	notFoundErr := store.Attach(ctx, "role:::admin", "p4")
+	_ = store.Detach(ctx, "role:::admin", "p3")
+	_ = store.Delete(ctx, "p2")
+	attached, err := store.Attached(ctx, "role:::admin")
+	nobody, _ := store.Attached(ctx, "role:::guest")
+
+	// Assert
+	if attachTwiceErr != nil {
+		t.Errorf("want nil, but got %v", attachTwiceErr)
+	}
+	if !errors.Is(notFoundErr, policy.ErrPolicyNotFound) {
+		t.Errorf("want ErrPolicyNotFound, but got %v", notFoundErr)
+	}
+	if want := []string{"p1"}; err != nil || !reflect.DeepEqual(policyIDs(attached), want) {
+		t.Errorf("want %v, but got %v, %v", want, policyIDs(attached), err)
+	}
+	if len(nobody) != 0 {
+		t.Errorf("want no policies, but got %v", policyIDs(nobody))
+	}
+}
+
+func TestStore_DeleteNotFound(t *testing.T) {
+	// Arrange
+	store, _ := newTestStore(t)
+
+	// Act
+	err := store.Delete(context.Background(), "p1")
+
+	// Assert
+	if !errors.Is(err, policy.ErrPolicyNotFound) {
+		t.Errorf("want ErrPolicyNotFound, but got %v", err)
+	}
+}
+
+func TestStore_Version(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	v0, _ := store.Version(ctx)
+
+	// Act
+	_ = store.Put(ctx, testPolicy("p1", "res:::p1"))
+	v1, _ := store.Version(ctx)
+	_ = store.Attach(ctx, "role:::admin", "p1")
+	v2, _ := store.Version(ctx)
+	_, _ = store.PutWithRevision(ctx, testPolicy("p1", "res:::p1"), 5)
+	v3, err := store.Version(ctx)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if !(v0 < v1 && v1 < v2) {
+		t.Errorf("want increasing versions, but got %d, %d, %d", v0, v1, v2)
+	}
+	if v3 != v2 {
+		t.Errorf("want %d after a conflict, but got %d", v2, v3)
+	}
+}
+
+func TestStore_VersionUnchangedAttachments(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	_ = store.Put(ctx, testPolicy("p1", "res:::p1"))
+	_ = store.Attach(ctx, "role:::admin", "p1")
+	want, _ := store.Version(ctx)
+
+	// Act
+	attachErr := store.Attach(ctx, "role:::admin", "p1")
+	detachErr := store.Detach(ctx, "role:::guest", "p1")
+	got, err := store.Version(ctx)
+
+	// Assert
+	if attachErr != nil || detachErr != nil {
+		t.Fatalf("want nil, but got %v, %v", attachErr, detachErr)
+	}
+	if err != nil || got != want {
+		t.Errorf("want version %d, but got %d, %v", want, got, err)
+	}
+}
+
+func TestStore_NewFromStore(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store, _ := newTestStore(t)
+	_ = store.Put(ctx, testPolicy("p1", "res:::doc"))
+	_ = store.Attach(ctx, "user:::1", "p1")
+
+	// Act
+	pv, err := policy.NewFromStore(ctx, store, "user:::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+	allowed, err := pv.IsAccessAllowed()
+
+	// Assert
+	if !allowed || err != nil {
+		t.Errorf("want true, but got %v, %v", allowed, err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	// Arrange
+	store := New(nil, Postgres)
+
+	// Act
+	got := store.rebind(`SELECT 1 WHERE a = ? AND b = ?`)
+
+	// Assert
+	if want := `SELECT 1 WHERE a = $1 AND b = $2`; got != want {
+		t.Errorf("want %s, but got %s", want, got)
+	}
+}
@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	structTagName     = "policy"
+	propertyPrefix    = "prop:::"
+	propertySeparator = ":"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ExtractProperties reads the resource properties from a struct with "policy" tags.
+//
+//	type Invoice struct {
+//		Amount   float64   `policy:"prop:::invoice:amount"`
+//		Owner    *string   `policy:"prop:::invoice:owner"`
+//		IssuedAt time.Time `policy:"prop:::invoice:issued_at"`
+//		Customer Customer  `policy:"prop:::invoice:customer"`
+//	}
+//
+// A tag that does not start with "prop:::" is a subkey of the enclosing struct's tag,
+// e.g. a field tagged `policy:"uuid"` inside Customer is read as "prop:::invoice:customer:uuid".
+// Untagged nested structs are read with the key of the enclosing struct, other untagged fields
+// and fields tagged `policy:"-"` are skipped.
+//
+// Strings are added to Property.String, integers to Property.Integer, floats to Property.Float,
+// booleans to Property.Boolean and time.Time to Property.String in RFC3339 format.
+// Nil pointers are skipped, and so are pointers to a struct that is being read, e.g. the parent of a child
+// that points back to it, so that cyclic data is read once. Any other type is an error.
+func ExtractProperties(v interface{}) (Property, error) {
+	prop := Property{
+		String:  make(map[string]string),
+		Integer: make(map[string]int),
+		Float:   make(map[string]float64),
+		Boolean: make(map[string]bool),
+	}
+
+	visiting := make(map[structPointer]bool)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return prop, nil
+		}
+		visiting[structPointer{address: rv.Pointer(), typ: rv.Type()}] = true
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return prop, fmt.Errorf("cannot extract properties from %T, must be a struct", v)
+	}
+
+	err := extractStructProperties(rv, "", &prop, visiting)
+	return prop, err
+}
+
+// structPointer identifies a pointer by its address and type, since a struct and its first field have the same address.
+type structPointer struct {
+	address uintptr
+	typ     reflect.Type
+}
+
+// extractStructProperties reads the fields of the struct, visiting holds the pointers of the structs being read.
+func extractStructProperties(rv reflect.Value, parentKey string, prop *Property, visiting map[structPointer]bool) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, hasTag := field.Tag.Lookup(structTagName)
+		if tag == "-" {
+			continue
+		}
+
+		value := rv.Field(i)
+		var pointers []structPointer
+		isCycle := false
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				break
+			}
+			pointer := structPointer{address: value.Pointer(), typ: value.Type()}
+			isCycle = isCycle || visiting[pointer]
+			pointers = append(pointers, pointer)
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Pointer || isCycle {
+			continue
+		}
+
+		isStruct := value.Kind() == reflect.Struct && value.Type() != timeType
+		if !hasTag {
+			if isStruct {
+				if err := extractNestedProperties(value, parentKey, prop, visiting, pointers); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		key := propertyKey(parentKey, tag)
+		if key == "" {
+			return fmt.Errorf("field %s: tag %q must start with %q", field.Name, tag, propertyPrefix)
+		}
+
+		if isStruct {
+			if err := extractNestedProperties(value, key, prop, visiting, pointers); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := addPropertyValue(prop, key, value); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractNestedProperties reads a nested struct, with the pointers to it marked as being read.
+func extractNestedProperties(rv reflect.Value, parentKey string, prop *Property, visiting map[structPointer]bool, pointers []structPointer) error {
+	for _, pointer := range pointers {
+		visiting[pointer] = true
+	}
+	defer func() {
+		for _, pointer := range pointers {
+			delete(visiting, pointer)
+		}
+	}()
+	return extractStructProperties(rv, parentKey, prop, visiting)
+}
+
+// propertyKey joins the tag with the parent key, it returns an empty string
+// if the tag is relative but there is no parent key.
+func propertyKey(parentKey, tag string) string {
+	if strings.HasPrefix(tag, propertyPrefix) {
+		return tag
+	}
+	if parentKey == "" {
+		return ""
+	}
+	return parentKey + propertySeparator + tag
+}
+
+func addPropertyValue(prop *Property, key string, value reflect.Value) error {
+	if value.Type() == timeType {
+		prop.String[key] = value.Interface().(time.Time).Format(time.RFC3339)
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		prop.String[key] = value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		prop.Integer[key] = int(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() > math.MaxInt {
+			return fmt.Errorf("value %d overflows int", value.Uint())
+		}
+		prop.Integer[key] = int(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		prop.Float[key] = value.Float()
+	case reflect.Bool:
+		prop.Boolean[key] = value.Bool()
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+// AddPropertiesFromStruct adds the properties read by ExtractProperties to the resource.
+// If the struct cannot be read, the error is set to the validator and returned by IsAccessAllowed.
+func (pv *policyValidator) AddPropertiesFromStruct(v interface{}) {
+	prop, err := ExtractProperties(v)
+	if err != nil {
+		pv.SetError(err)
+		return
+	}
+	pv.AddProperties(prop)
+}
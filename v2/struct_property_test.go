@@ -0,0 +1,220 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testCustomer struct {
+	UUID    string `policy:"uuid"`
+	Premium bool   `policy:"premium"`
+}
+
+type AuditInfo struct {
+	CreatedBy string `policy:"prop:::invoice:created_by"`
+}
+
+type testInvoice struct {
+	Amount    float64       `policy:"prop:::invoice:amount"`
+	Quantity  uint16        `policy:"prop:::invoice:quantity"`
+	Version   int64         `policy:"prop:::invoice:version"`
+	Owner     *string       `policy:"prop:::invoice:owner"`
+	Approver  *string       `policy:"prop:::invoice:approver"`
+	IssuedAt  time.Time     `policy:"prop:::invoice:issued_at"`
+	Customer  testCustomer  `policy:"prop:::invoice:customer"`
+	Reseller  *testCustomer `policy:"prop:::invoice:reseller"`
+	Note      string
+	Ignored   string `policy:"-"`
+	AuditInfo        // untagged embedded struct
+	internal  string `policy:"prop:::invoice:internal"`
+}
+
+func TestExtractProperties(t *testing.T) {
+	// Arrange
+	owner := "u1"
+	invoice := &testInvoice{
+		Amount:    99.5,
+		Quantity:  3,
+		Version:   7,
+		Owner:     &owner,
+		IssuedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Customer:  testCustomer{UUID: "c1", Premium: true},
+		Note:      "not a property",
+		Ignored:   "ignored",
+		AuditInfo: AuditInfo{CreatedBy: "u2"},
+		internal:  "internal",
+	}
+	want := Property{
+		String: map[string]string{
+			"prop:::invoice:owner":         "u1",
+			"prop:::invoice:issued_at":     "2024-01-02T03:04:05Z",
+			"prop:::invoice:customer:uuid": "c1",
+			"prop:::invoice:created_by":    "u2",
+		},
+		Integer: map[string]int{
+			"prop:::invoice:quantity": 3,
+			"prop:::invoice:version":  7,
+		},
+		Float: map[string]float64{
+			"prop:::invoice:amount": 99.5,
+		},
+		Boolean: map[string]bool{
+			"prop:::invoice:customer:premium": true,
+		},
+	}
+
+	// Act
+	got, err := ExtractProperties(invoice)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, but want %v", got, want)
+	}
+}
+
+func TestExtractProperties_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{
+			name:  "not a struct",
+			value: "hello",
+		},
+		{
+			name: "unsupported field type",
+			value: struct {
+				Tags []string `policy:"prop:::doc:tags"`
+			}{},
+		},
+		{
+			name: "relative tag without parent",
+			value: struct {
+				Owner string `policy:"owner"`
+			}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractProperties(tt.value)
+			if err == nil {
+				t.Error("want error, but got nil")
+			}
+		})
+	}
+}
+
+func TestExtractProperties_NilPointer(t *testing.T) {
+	var invoice *testInvoice
+
+	got, err := ExtractProperties(invoice)
+
+	if err != nil {
+		t.Errorf("want nil, but got %v", err)
+	}
+	if len(got.String)+len(got.Integer)+len(got.Float)+len(got.Boolean) != 0 {
+		t.Errorf("want empty properties, but got %v", got)
+	}
+}
+
+type testFolder struct {
+	Name   string      `policy:"name"`
+	Parent *testFolder `policy:"parent"`
+	Child  *testFolder
+}
+
+type testDocument struct {
+	Owner  string      `policy:"prop:::doc:owner"`
+	Folder *testFolder `policy:"prop:::doc:folder"`
+	Self   *testDocument
+}
+
+func TestExtractProperties_Cycle(t *testing.T) {
+	// Arrange
+	parent := &testFolder{Name: "root"}
+	child := &testFolder{Name: "docs", Parent: parent}
+	parent.Child = child
+	doc := &testDocument{Owner: "u1", Folder: child}
+	doc.Self = doc
+
+	// Act
+	got, err := ExtractProperties(doc)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	want := map[string]string{
+		"prop:::doc:owner":              "u1",
+		"prop:::doc:folder:name":        "docs",
+		"prop:::doc:folder:parent:name": "root",
+	}
+	if !reflect.DeepEqual(got.String, want) {
+		t.Errorf("want %v, but got %v", want, got.String)
+	}
+}
+
+func TestAddPropertiesFromStruct(t *testing.T) {
+	t.Run("valid struct, expect ALLOWED", func(t *testing.T) {
+		// Arrange
+		pv := New()
+		pv.Policies = []Policy{
+			{
+				Statements: []Statement{
+					{
+						Effect:   statementEffectAllow,
+						Resource: "res:::invoice",
+						Actions:  []string{"act:::invoice:read"},
+						Conditions: &Condition{
+							MustHaveAll: map[string]Comparator{
+								"prop:::invoice:customer:uuid": {StringEqual: ptr("c1")},
+							},
+						},
+					},
+				},
+			},
+		}
+		pv.SetResource("res:::invoice")
+		pv.SetAction("act:::invoice:read")
+
+		// Act
+		pv.AddPropertiesFromStruct(testInvoice{Customer: testCustomer{UUID: "c1"}})
+		result, err := pv.IsAccessAllowed()
+
+		// Assert
+		if err != nil {
+			t.Errorf("want nil, but got %v", err)
+		}
+		if result != ALLOWED {
+			t.Errorf("got %v, but want %v", result, ALLOWED)
+		}
+	})
+
+	t.Run("invalid struct, expect error", func(t *testing.T) {
+		// Arrange
+		pv := New()
+
+		// Act
+		pv.AddPropertiesFromStruct(struct {
+			Tags []string `policy:"prop:::doc:tags"`
+		}{})
+		result, err := pv.IsAccessAllowed()
+
+		// Assert
+		if err == nil {
+			t.Error("want error, but got nil")
+		}
+		if result != DENIED {
+			t.Errorf("got %v, but want %v", result, DENIED)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,239 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The names of the spans of the Tracer.
+const (
+	SpanEvaluate           = "policy.Evaluate"
+	SpanValidationFunction = "policy.ValidationFunction"
+	SpanPostValidator      = "policy.PostValidator"
+	SpanUserProperty       = "policy.GetUserProperty"
+)
+
+// Tracer starts the spans of the evaluations, so that the time of an evaluation can be split into its
+// validation functions, post validators and user property lookups. It has the shape of the Tracer of
+// OpenTelemetry, so that an adapter only converts the attributes:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, policy.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+// Spans:
+//
+//	policy.Evaluate            a decision of IsAccessAllowed, ExplainAccess, Authorize, Explain or one resource of
+//	                           IsAccessAllowedBatch, with policy.resource, policy.action and policy.decision
+//	policy.ValidationFunction  a call of a validation function, with policy.function and policy.matched
+//	policy.PostValidator       a call of a post validator, with policy.post_validator and policy.allowed
+//	policy.GetUserProperty     a lookup of a user property, with policy.user_property
+//
+// The spans of the calls are children of the span of the decision, and the span of the decision is a child
+// of the span of the context of Authorize or Explain. The errors are recorded with RecordError.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer, it is ended once.
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is an attribute of a Span, the value is a string or a bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// context returns the context of the validation, that of Authorize or Explain, or the background context.
+func (pv *policyValidator) context() context.Context {
+	if pv.ctx == nil {
+		return context.Background()
+	}
+	return pv.ctx
+}
+
+// traceDecision makes the decision in a span of the Tracer, if it is not nil, with a copy of the validator
+// that starts the spans of the calls as children of that span.
+func (pv *policyValidator) traceDecision(res Resource, decide func(pv *policyValidator) (bool, error)) (bool, error) {
+	if pv.Tracer == nil {
+		return decide(pv)
+	}
+
+	ctx, span := pv.Tracer.Start(pv.context(), SpanEvaluate)
+	defer span.End()
+
+	traced := *pv
+	traced.ctx = ctx
+	if pv.UserPropertyGetter != nil {
+		traced.UserPropertyGetter = &tracedUserPropertyGetter{getter: pv.UserPropertyGetter, tracer: pv.Tracer, ctx: ctx}
+	}
+	allowed, err := decide(&traced)
+
+	decision := "denied"
+	if err != nil {
+		decision = "error"
+		span.RecordError(err)
+	} else if allowed {
+		decision = "allowed"
+	}
+	span.SetAttributes(
+		Attribute{Key: "policy.resource", Value: res.Resource},
+		Attribute{Key: "policy.action", Value: res.Action},
+		Attribute{Key: "policy.decision", Value: decision},
+	)
+	return allowed, err
+}
+
+// callValidationFunction calls the validation function, in a span if there is a Tracer.
+func (pv *policyValidator) callValidationFunction(name string, fn ValidationFunction, a, b string) (bool, error) {
+	if pv.Tracer == nil {
+		return fn(a, b)
+	}
+
+	_, span := pv.Tracer.Start(pv.context(), SpanValidationFunction)
+	defer span.End()
+
+	matched, err := fn(a, b)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.SetAttributes(Attribute{Key: "policy.function", Value: name}, Attribute{Key: "policy.matched", Value: matched})
+	return matched, err
+}
+
+// callPostValidator calls the post validator, in a span if there is a Tracer.
+func (pv *policyValidator) callPostValidator(postValidator PostValidator, statements []Statement, res Resource) (bool, error) {
+	if pv.Tracer == nil {
+		return postValidator.Validate(statements, res)
+	}
+
+	_, span := pv.Tracer.Start(pv.context(), SpanPostValidator)
+	defer span.End()
+
+	allowed, err := postValidator.Validate(statements, res)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.SetAttributes(
+		Attribute{Key: "policy.post_validator", Value: fmt.Sprintf("%T", postValidator)},
+		Attribute{Key: "policy.allowed", Value: allowed},
+	)
+	return allowed, err
+}
+
+// tracedUserPropertyGetter looks up each user property in a span.
+type tracedUserPropertyGetter struct {
+	getter UserPropertyGetter
+	tracer Tracer
+	ctx    context.Context
+}
+
+func (g *tracedUserPropertyGetter) GetUserProperty(key string) string {
+	_, span := g.tracer.Start(g.ctx, SpanUserProperty)
+	defer span.End()
+
+	span.SetAttributes(Attribute{Key: "policy.user_property", Value: key})
+	return g.getter.GetUserProperty(key)
+}
+
+// MemoryTracer keeps the ended spans in memory, e.g. to check them in tests. It is safe for concurrent use.
+type MemoryTracer struct {
+	mu     sync.Mutex
+	nextID int
+	spans  []SpanRecord
+}
+
+// SpanRecord is a span ended with a MemoryTracer.
+type SpanRecord struct {
+	// ID is the number of the span in the order they are started, from 1.
+	ID int
+	// ParentID is the ID of the span of the context of Start, 0 if there is none.
+	ParentID   int
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Errors     []error
+}
+
+// Attribute returns the value of the attribute of the key, nil if the span does not have it.
+func (r SpanRecord) Attribute(key string) interface{} {
+	for _, attribute := range r.Attributes {
+		if attribute.Key == key {
+			return attribute.Value
+		}
+	}
+	return nil
+}
+
+type memorySpanKey struct{}
+
+func (t *MemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	t.nextID++
+	span := &memorySpan{tracer: t, record: SpanRecord{ID: t.nextID, Name: name, Start: time.Now()}}
+	t.mu.Unlock()
+
+	if parent, ok := ctx.Value(memorySpanKey{}).(*memorySpan); ok && parent.tracer == t {
+		span.record.ParentID = parent.record.ID
+	}
+	return context.WithValue(ctx, memorySpanKey{}, span), span
+}
+
+// Spans returns the ended spans in the order they are ended.
+func (t *MemoryTracer) Spans() []SpanRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SpanRecord(nil), t.spans...)
+}
+
+// Reset removes the ended spans.
+func (t *MemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+type memorySpan struct {
+	tracer *MemoryTracer
+	mu     sync.Mutex
+	record SpanRecord
+	ended  bool
+}
+
+func (s *memorySpan) SetAttributes(attributes ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.Attributes = append(s.record.Attributes, attributes...)
+}
+
+func (s *memorySpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.Errors = append(s.record.Errors, err)
+}
+
+func (s *memorySpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.record.End = time.Now()
+	record := s.record
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, record)
+}
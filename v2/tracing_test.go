@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func tracingPolicies() []Policy {
+	return []Policy{{PolicyID: "p", Statements: []Statement{{
+		Effect:   statementEffectAllow,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{MustHaveAll: map[string]Comparator{
+			"prop:::doc:team": {ValidationFunc: &ValidationFunc{Function: "isMember", UserArg: ptr("user:::id")}},
+		}},
+	}}}}
+}
+
+// spanNames returns the names of the spans, and checks that each span other than the first ended is a child of it.
+func spanNames(t *testing.T, spans []SpanRecord) []string {
+	t.Helper()
+	var names []string
+	root := spans[len(spans)-1]
+	for _, span := range spans {
+		names = append(names, span.Name)
+		if span.ID != root.ID && span.ParentID != root.ID {
+			t.Errorf("want %s a child of %s, but got parent %d", span.Name, root.Name, span.ParentID)
+		}
+	}
+	return names
+}
+
+func TestTracer_IsAccessAllowed(t *testing.T) {
+	tests := []struct {
+		name              string
+		member            bool
+		memberErr         error
+		postValidator     *MockPostValidator
+		wantNames         []string
+		wantDecision      string
+		wantMatched       bool
+		wantErrors        int
+		wantPostValidator bool
+	}{
+		{
+			name:              "allowed",
+			member:            true,
+			postValidator:     &MockPostValidator{Result: true},
+			wantNames:         []string{SpanUserProperty, SpanValidationFunction, SpanPostValidator, SpanEvaluate},
+			wantDecision:      "allowed",
+			wantMatched:       true,
+			wantPostValidator: true,
+		},
+		{
+			name:         "not matched",
+			wantNames:    []string{SpanUserProperty, SpanValidationFunction, SpanEvaluate},
+			wantDecision: "denied",
+		},
+		{
+			name:         "validation function error",
+			memberErr:    errors.New("directory unavailable"),
+			wantNames:    []string{SpanUserProperty, SpanValidationFunction, SpanEvaluate},
+			wantDecision: "denied",
+			wantErrors:   1,
+		},
+		{
+			name:              "denied by post validator",
+			member:            true,
+			postValidator:     &MockPostValidator{Result: false},
+			wantNames:         []string{SpanUserProperty, SpanValidationFunction, SpanPostValidator, SpanEvaluate},
+			wantDecision:      "denied",
+			wantMatched:       true,
+			wantPostValidator: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tracer := &MemoryTracer{}
+			pv := New()
+			pv.Policies = tracingPolicies()
+			pv.Tracer = tracer
+			pv.UserPropertyGetter = &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+			pv.SetValidationFunction("isMember", func(a, b string) (bool, error) { return tt.member, tt.memberErr })
+			if tt.postValidator != nil {
+				pv.AddPostExecutor(tt.postValidator)
+			}
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+			pv.AddPropertyString("prop:::doc:team", "t1")
+
+			// Act
+			_, _ = pv.IsAccessAllowed()
+
+			// Assert
+			spans := tracer.Spans()
+			if got := spanNames(t, spans); !reflect.DeepEqual(got, tt.wantNames) {
+				t.Fatalf("want %v, but got %v", tt.wantNames, got)
+			}
+			evaluate := spans[len(spans)-1]
+			if evaluate.Attribute("policy.resource") != "res:::doc" || evaluate.Attribute("policy.action") != "act:::doc:read" {
+				t.Errorf("want the resource and action, but got %v", evaluate.Attributes)
+			}
+			if got := evaluate.Attribute("policy.decision"); got != tt.wantDecision {
+				t.Errorf("want %v, but got %v", tt.wantDecision, got)
+			}
+			if got := spans[0].Attribute("policy.user_property"); got != "user:::id" {
+				t.Errorf("want user:::id, but got %v", got)
+			}
+			fn := spans[1]
+			if fn.Attribute("policy.function") != "isMember" || fn.Attribute("policy.matched") != tt.wantMatched {
+				t.Errorf("want isMember matched %v, but got %v", tt.wantMatched, fn.Attributes)
+			}
+			if len(fn.Errors) != tt.wantErrors {
+				t.Errorf("want %d errors, but got %v", tt.wantErrors, fn.Errors)
+			}
+			if tt.postValidator != nil {
+				post := spans[2]
+				if post.Attribute("policy.post_validator") != "*policy.MockPostValidator" || post.Attribute("policy.allowed") != tt.wantPostValidator {
+					t.Errorf("want the post validator allowed %v, but got %v", tt.wantPostValidator, post.Attributes)
+				}
+			}
+		})
+	}
+}
+
+func TestTracer_Error(t *testing.T) {
+	// Arrange
+	errPost := errors.New("post validator error")
+	tracer := &MemoryTracer{}
+	pv := New()
+	pv.Policies = explanationPolicies()
+	pv.Tracer = tracer
+	pv.AddPostExecutor(&MockPostValidator{Error: errPost})
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	_, err := pv.ExplainAccess()
+
+	// Assert
+	if !errors.Is(err, errPost) {
+		t.Fatalf("want %v, but got %v", errPost, err)
+	}
+	spans := tracer.Spans()
+	for _, span := range spans {
+		if !reflect.DeepEqual(span.Errors, []error{errPost}) {
+			t.Errorf("want %s with %v, but got %v", span.Name, errPost, span.Errors)
+		}
+	}
+	if got := spans[len(spans)-1].Attribute("policy.decision"); got != "error" {
+		t.Errorf("want error, but got %v", got)
+	}
+}
+
+func TestTracer_Authorize(t *testing.T) {
+	// Arrange
+	tracer := &MemoryTracer{}
+	pv := New()
+	pv.Policies = tracingPolicies()
+	pv.Tracer = tracer
+	pv.DecisionLogger = &MemoryDecisionLogger{}
+	pv.SetValidationFunction("isMember", func(a, b string) (bool, error) { return true, nil })
+	ctx, request := tracer.Start(context.Background(), "request")
+	user := &MockUserGetter{UserValue: map[string]string{"user:::id": "u1"}}
+
+	// Act
+	allowed, err := pv.Authorize(ctx, user, Resource{
+		Resource:   "res:::doc",
+		Action:     "act:::doc:read",
+		Properties: Property{String: map[string]string{"prop:::doc:team": "t1"}},
+	})
+	request.End()
+
+	// Assert
+	if !allowed || err != nil {
+		t.Fatalf("want true, but got %v, %v", allowed, err)
+	}
+	spans := tracer.Spans()
+	var evaluate SpanRecord
+	for _, span := range spans {
+		if span.Name == SpanEvaluate {
+			evaluate = span
+		}
+	}
+	if evaluate.ParentID != spans[len(spans)-1].ID {
+		t.Errorf("want %s a child of the request, but got parent %d", SpanEvaluate, evaluate.ParentID)
+	}
+	if len(spans) != 5 {
+		t.Errorf("want 5 spans with the lookup of the DecisionLogger, but got %+v", spans)
+	}
+}
+
+func TestTracer_Batch(t *testing.T) {
+	// Arrange
+	tracer := &MemoryTracer{}
+	pv := New()
+	pv.Policies = explanationPolicies()
+	pv.Tracer = tracer
+	resources := []Resource{
+		{Resource: "res:::doc", Action: "act:::doc:read"},
+		{Resource: "res:::doc", Action: "act:::doc:write"},
+	}
+
+	// Act
+	_, err := pv.IsAccessAllowedBatch(resources)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	var got []interface{}
+	for _, span := range tracer.Spans() {
+		got = append(got, span.Attribute("policy.decision"))
+	}
+	if want := []interface{}{"allowed", "denied"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, but got %v", want, got)
+	}
+}
+
+func TestMemoryTracer(t *testing.T) {
+	// Arrange
+	tracer := &MemoryTracer{}
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child")
+
+	// Act
+	child.End()
+	child.End()
+	parent.End()
+
+	// Assert
+	spans := tracer.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, but got %+v", spans)
+	}
+	if spans[0].Name != "child" || spans[0].ParentID != spans[1].ID || spans[1].ParentID != 0 {
+		t.Errorf("want child of parent, but got %+v", spans)
+	}
+	if spans[0].End.Before(spans[0].Start) {
+		t.Errorf("want the end after the start, but got %v, %v", spans[0].Start, spans[0].End)
+	}
+
+	tracer.Reset()
+	if got := tracer.Spans(); len(got) != 0 {
+		t.Errorf("want no spans, but got %+v", got)
+	}
+}
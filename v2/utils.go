@@ -1,5 +1,7 @@
 package policy
 
+import "sort"
+
 func isContainsInList[T comparable](list []T, s T) bool {
 	for _, v := range list {
 		if v == s {
@@ -9,6 +11,25 @@ func isContainsInList[T comparable](list []T, s T) bool {
 	return false
 }
 
+func removeFromList[T comparable](list []T, s T) []T {
+	result := make([]T, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
 func isEquals[T comparable](a, b T) bool {
 	return a == b
 }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+package v1compat
+
+import (
+	"fmt"
+
+	v1 "github.com/golfz/policy"
+	"github.com/golfz/policy/v2"
+)
+
+// Difference is a resource for which the engines of v1 and v2 do not agree.
+type Difference struct {
+	Resource v1.Resource
+	V1       v1.ResultEffect
+	V1Err    error
+	V2       bool
+	V2Err    error
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s %s: v1 %s (%v), but v2 %s (%v)",
+		d.Resource.Resource, d.Resource.Action, v1ResultString(d.V1), d.V1Err, v2ResultString(d.V2), d.V2Err)
+}
+
+func v1ResultString(result v1.ResultEffect) string {
+	if result == v1.ALLOWED {
+		return "ALLOWED"
+	}
+	return "DENIED"
+}
+
+func v2ResultString(allowed bool) string {
+	if allowed {
+		return "ALLOWED"
+	}
+	return "DENIED"
+}
+
+// Compare evaluates each resource against the policies with the ValidationController of v1 and the validator of v2,
+// and returns the resources for which the decisions or the presence of an error differ. No differences means that
+// the service can move to v2 without changing the decisions for these inputs.
+func Compare(policies []v1.Policy, user v1.UserPropertyGetter, resources []v1.Resource) []Difference {
+	var differences []Difference
+	converted := ConvertPolicies(policies)
+	for _, res := range resources {
+		ctrl := &v1.ValidationController{Policies: policies, UserPropertyGetter: user}
+		v1Result, v1Err := ctrl.IsAccessAllowed(res)
+
+		pv := policy.New()
+		pv.Policies = converted
+		pv.UserPropertyGetter = user
+		pv.SetResource(res.Resource)
+		pv.SetAction(res.Action)
+		pv.AddProperties(ConvertResource(res).Properties)
+		v2Result, v2Err := pv.IsAccessAllowed()
+
+		if (v1Result == v1.ALLOWED) != v2Result || (v1Err == nil) != (v2Err == nil) {
+			differences = append(differences, Difference{Resource: res, V1: v1Result, V1Err: v1Err, V2: v2Result, V2Err: v2Err})
+		}
+	}
+	return differences
+}
@@ -0,0 +1,223 @@
+package v1compat
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	v1 "github.com/golfz/policy"
+)
+
+const compareResources = 500
+
+// randomResources returns resources for the statements of the policies, with the resource, an action and
+// properties that either take a value of the comparators or miss them, and some resources that match no statement.
+func randomResources(rnd *rand.Rand, policies []v1.Policy, n int) []v1.Resource {
+	var statements []v1.Statement
+	for _, p := range policies {
+		statements = append(statements, p.Statements...)
+	}
+
+	resources := make([]v1.Resource, n)
+	for i := range resources {
+		if len(statements) == 0 || rnd.Intn(10) == 0 {
+			resources[i] = v1.Resource{Resource: "res:::unknown", Action: "act:::unknown"}
+			continue
+		}
+		stmt := statements[rnd.Intn(len(statements))]
+		res := v1.Resource{Resource: stmt.Resource}
+		if len(stmt.Actions) > 0 {
+			res.Action = stmt.Actions[rnd.Intn(len(stmt.Actions))]
+		}
+		if stmt.Conditions != nil {
+			addRandomProperties(rnd, &res.Properties, stmt.Conditions.AtLeastOne)
+			addRandomProperties(rnd, &res.Properties, stmt.Conditions.MustHaveAll)
+		}
+		resources[i] = res
+	}
+	return resources
+}
+
+func addRandomProperties(rnd *rand.Rand, prop *v1.Property, conditions map[string]v1.Comparator) {
+	for key, c := range conditions {
+		hit := rnd.Intn(3) > 0
+		if c.StringEqual != nil || c.StringIn != nil || c.UserPropEqual != nil {
+			values := []string{"miss"}
+			if hit && c.StringEqual != nil {
+				values = append(values, *c.StringEqual)
+			}
+			if hit && c.StringIn != nil {
+				values = append(values, *c.StringIn...)
+			}
+			if hit && c.UserPropEqual != nil {
+				values = append(values, randomUser.GetUserProperty(*c.UserPropEqual))
+			}
+			if prop.String == nil {
+				prop.String = make(map[string]string)
+			}
+			prop.String[key] = values[rnd.Intn(len(values))]
+		}
+		if c.IntegerEqual != nil || c.IntegerIn != nil {
+			values := []int{-1}
+			if hit && c.IntegerEqual != nil {
+				values = append(values, *c.IntegerEqual)
+			}
+			if hit && c.IntegerIn != nil {
+				values = append(values, *c.IntegerIn...)
+			}
+			if prop.Integer == nil {
+				prop.Integer = make(map[string]int)
+			}
+			prop.Integer[key] = values[rnd.Intn(len(values))]
+		}
+		if c.FloatEqual != nil || c.FloatIn != nil {
+			values := []float64{-1.5}
+			if hit && c.FloatEqual != nil {
+				values = append(values, *c.FloatEqual)
+			}
+			if hit && c.FloatIn != nil {
+				values = append(values, *c.FloatIn...)
+			}
+			if prop.Float == nil {
+				prop.Float = make(map[string]float64)
+			}
+			prop.Float[key] = values[rnd.Intn(len(values))]
+		}
+		if c.BooleanEqual != nil {
+			if prop.Boolean == nil {
+				prop.Boolean = make(map[string]bool)
+			}
+			prop.Boolean[key] = rnd.Intn(2) == 0
+		}
+	}
+}
+
+var randomUser = &mockUserGetter{UserValue: map[string]string{
+	"user:::user_1:prop_1": "u1",
+	"user:::user_1:prop_2": "u2",
+	"user:::id":            "u1",
+}}
+
+// randomPolicies returns policies built from a few resources, actions, keys and values, so that the
+// statements overlap and the Deny statements meet the Allow statements.
+func randomPolicies(rnd *rand.Rand) []v1.Policy {
+	pick := func(items ...string) string { return items[rnd.Intn(len(items))] }
+	randomConditions := func() map[string]v1.Comparator {
+		conditions := make(map[string]v1.Comparator)
+		for i := rnd.Intn(3); i > 0; i-- {
+			var c v1.Comparator
+			switch rnd.Intn(6) {
+			case 0:
+				c.StringEqual = ptr(pick("a", "b"))
+			case 1:
+				c.StringIn = &[]string{pick("a", "b"), "c"}
+			case 2:
+				c.IntegerEqual = ptr(rnd.Intn(3))
+			case 3:
+				c.FloatIn = &[]float64{float64(rnd.Intn(3)) + 0.5}
+			case 4:
+				c.BooleanEqual = ptr(rnd.Intn(2) == 0)
+			default:
+				c.UserPropEqual = ptr(pick("user:::id", "user:::user_1:prop_2"))
+			}
+			conditions[pick("prop:::doc:a", "prop:::doc:b", "prop:::doc:c")] = c
+		}
+		return conditions
+	}
+
+	policies := make([]v1.Policy, 1+rnd.Intn(3))
+	for i := range policies {
+		policies[i] = v1.Policy{Version: 1, PolicyID: fmt.Sprintf("p%d", i)}
+		for j := 1 + rnd.Intn(4); j > 0; j-- {
+			stmt := v1.Statement{
+				Effect:   pick("Allow", "Allow", "Deny"),
+				Resource: pick("res:::doc", "res:::file"),
+				Actions:  []string{pick("act:::read", "act:::write")},
+			}
+			if rnd.Intn(4) > 0 {
+				stmt.Conditions = &v1.Condition{AtLeastOne: randomConditions(), MustHaveAll: randomConditions()}
+			}
+			policies[i].Statements = append(policies[i].Statements, stmt)
+		}
+	}
+	return policies
+}
+
+func TestCompare_SharedTestData(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"full conditions", "../../test_data/is_access_allowed/1policy_full_conditions.json"},
+		{"partial conditions", "../../test_data/is_access_allowed/1policy_partial_conditions.json"},
+		{"nil conditions", "../../test_data/is_access_allowed/1policy_nil_conditions.json"},
+		{"no conditions", "../../test_data/is_access_allowed/1policy_no_conditions.json"},
+		{"full comparators", "../../test_data/parse_policy/policy_array_full.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			policies, err := v1.ParsePolicyArray(mustReadFile(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resources := randomResources(rand.New(rand.NewSource(1)), policies, compareResources)
+
+			// Act
+			differences := Compare(policies, randomUser, resources)
+
+			// Assert
+			for _, d := range differences {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestCompare_RandomPolicies(t *testing.T) {
+	// Arrange
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		policies := randomPolicies(rnd)
+		resources := randomResources(rnd, policies, 50)
+
+		// Act
+		differences := Compare(policies, randomUser, resources)
+
+		// Assert
+		for _, d := range differences {
+			t.Errorf("policies %d: %v", i, d)
+		}
+	}
+}
+
+func TestCompare_InvalidEffect(t *testing.T) {
+	// Arrange
+	policies := []v1.Policy{{PolicyID: "p", Statements: []v1.Statement{
+		{Effect: "Maybe", Resource: "res:::doc", Actions: []string{"act:::read"}},
+	}}}
+	resources := []v1.Resource{{Resource: "res:::doc", Action: "act:::read"}}
+
+	// Act
+	differences := Compare(policies, randomUser, resources)
+
+	// Assert
+	if len(differences) != 0 {
+		t.Errorf("want no differences, but got %v", differences)
+	}
+}
+
+func TestDifference_String(t *testing.T) {
+	// Arrange
+	d := Difference{Resource: v1.Resource{Resource: "res:::doc", Action: "act:::read"}, V1: v1.ALLOWED, V2: false}
+
+	// Act
+	got := d.String()
+
+	// Assert
+	if want := "res:::doc act:::read: v1 ALLOWED (<nil>), but v2 DENIED (<nil>)"; got != want {
+		t.Errorf("want %q, but got %q", want, got)
+	}
+}
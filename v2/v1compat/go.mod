@@ -0,0 +1,15 @@
+module github.com/golfz/policy/v2/v1compat
+
+go 1.22.1
+
+require (
+	github.com/golfz/policy v0.0.0-00010101000000-000000000000
+	github.com/golfz/policy/v2 v2.0.0-00010101000000-000000000000
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace (
+	github.com/golfz/policy => ../../
+	github.com/golfz/policy/v2 => ../
+)
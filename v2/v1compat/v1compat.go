@@ -0,0 +1,263 @@
+// Package v1compat moves code and stored policies from github.com/golfz/policy (v1) to github.com/golfz/policy/v2.
+//
+// The policies of v1 are a subset of those of v2 version 1, so ConvertPolicies never fails, and ConvertPoliciesToV1 fails only
+// for the comparators that v1 does not have. ValidationController replaces the v1 controller of the same name with
+// the v2 engine, and the overrider adapters let the ValidationOverrider and Validator implementations of v1 be used
+// with the validator of policy.New. The user property getters of v1 and v2 have the same method, so they need no adapter.
+//
+// Compare evaluates the same inputs with both engines, to check that they make the same decisions before
+// a service is moved to v2.
+//
+// v1compat is a module of its own, so that only the services moving from v1 depend on both versions.
+package v1compat
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "github.com/golfz/policy"
+	"github.com/golfz/policy/v2"
+)
+
+// ErrNotSupportedByV1 is returned by ConvertPoliciesToV1 for a comparator that v1 does not have.
+var ErrNotSupportedByV1 = errors.New("not supported by v1")
+
+// ConvertPolicies converts policies of v1 to v2, the comparators keep their values.
+func ConvertPolicies(policies []v1.Policy) []policy.Policy {
+	if policies == nil {
+		return nil
+	}
+	converted := make([]policy.Policy, len(policies))
+	for i, p := range policies {
+		converted[i] = ConvertPolicy(p)
+	}
+	return converted
+}
+
+// ConvertPolicy converts a policy of v1 to v2. The Version is set to policy.PolicyVersion1,
+// since v1 does not read it and a stored policy may have any value, which v2 rejects.
+func ConvertPolicy(p v1.Policy) policy.Policy {
+	converted := policy.Policy{Version: policy.PolicyVersion1, PolicyID: p.PolicyID}
+	if p.Statements != nil {
+		converted.Statements = make([]policy.Statement, len(p.Statements))
+	}
+	for i, stmt := range p.Statements {
+		converted.Statements[i] = policy.Statement{
+			Effect:   stmt.Effect,
+			Resource: stmt.Resource,
+			Actions:  stmt.Actions,
+		}
+		if stmt.Conditions != nil {
+			converted.Statements[i].Conditions = &policy.Condition{
+				AtLeastOne:  convertConditions(stmt.Conditions.AtLeastOne),
+				MustHaveAll: convertConditions(stmt.Conditions.MustHaveAll),
+			}
+		}
+	}
+	return converted
+}
+
+func convertConditions(conditions map[string]v1.Comparator) map[string]policy.Comparator {
+	if conditions == nil {
+		return nil
+	}
+	converted := make(map[string]policy.Comparator, len(conditions))
+	for key, c := range conditions {
+		converted[key] = policy.Comparator{
+			StringIn:      c.StringIn,
+			StringEqual:   c.StringEqual,
+			IntegerIn:     c.IntegerIn,
+			IntegerEqual:  c.IntegerEqual,
+			FloatIn:       c.FloatIn,
+			FloatEqual:    c.FloatEqual,
+			BooleanEqual:  c.BooleanEqual,
+			UserPropEqual: c.UserPropEqual,
+		}
+	}
+	return converted
+}
+
+// ConvertPoliciesToV1 converts policies of v2 to v1. The error wraps ErrNotSupportedByV1 for a policy with
+// an Expression, a ValidationFunc or a StringPrefix, the comparators of v2 only.
+func ConvertPoliciesToV1(policies []policy.Policy) ([]v1.Policy, error) {
+	if policies == nil {
+		return nil, nil
+	}
+	converted := make([]v1.Policy, len(policies))
+	for i, p := range policies {
+		var err error
+		if converted[i], err = ConvertPolicyToV1(p); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+// ConvertPolicyToV1 converts a policy of v2 to v1, like ConvertPoliciesToV1.
+func ConvertPolicyToV1(p policy.Policy) (v1.Policy, error) {
+	converted := v1.Policy{Version: p.Version, PolicyID: p.PolicyID}
+	if p.Statements != nil {
+		converted.Statements = make([]v1.Statement, len(p.Statements))
+	}
+	for i, stmt := range p.Statements {
+		converted.Statements[i] = v1.Statement{
+			Effect:   stmt.Effect,
+			Resource: stmt.Resource,
+			Actions:  stmt.Actions,
+		}
+		if stmt.Conditions == nil {
+			continue
+		}
+		atLeastOne, err := convertConditionsToV1(stmt.Conditions.AtLeastOne)
+		if err != nil {
+			return v1.Policy{}, fmt.Errorf("policy %q: statement %d: AtLeastOne %w", p.PolicyID, i, err)
+		}
+		mustHaveAll, err := convertConditionsToV1(stmt.Conditions.MustHaveAll)
+		if err != nil {
+			return v1.Policy{}, fmt.Errorf("policy %q: statement %d: MustHaveAll %w", p.PolicyID, i, err)
+		}
+		converted.Statements[i].Conditions = &v1.Condition{AtLeastOne: atLeastOne, MustHaveAll: mustHaveAll}
+	}
+	return converted, nil
+}
+
+func convertConditionsToV1(conditions map[string]policy.Comparator) (map[string]v1.Comparator, error) {
+	if conditions == nil {
+		return nil, nil
+	}
+	converted := make(map[string]v1.Comparator, len(conditions))
+	for _, key := range policy.ConditionKeys(conditions) {
+		c := conditions[key]
+		if c.Expression != nil {
+			return nil, fmt.Errorf("%s: Expression is %w", key, ErrNotSupportedByV1)
+		}
+		if c.ValidationFunc != nil {
+			return nil, fmt.Errorf("%s: ValidationFunc is %w", key, ErrNotSupportedByV1)
+		}
+		if c.StringPrefix != nil {
+			return nil, fmt.Errorf("%s: StringPrefix is %w", key, ErrNotSupportedByV1)
+		}
+		converted[key] = v1.Comparator{
+			StringIn:      c.StringIn,
+			StringEqual:   c.StringEqual,
+			IntegerIn:     c.IntegerIn,
+			IntegerEqual:  c.IntegerEqual,
+			FloatIn:       c.FloatIn,
+			FloatEqual:    c.FloatEqual,
+			BooleanEqual:  c.BooleanEqual,
+			UserPropEqual: c.UserPropEqual,
+		}
+	}
+	return converted, nil
+}
+
+// ConvertResource converts a resource of v1 to v2, the properties are shared.
+func ConvertResource(res v1.Resource) policy.Resource {
+	return policy.Resource{
+		Resource: res.Resource,
+		Action:   res.Action,
+		Properties: policy.Property{
+			String:  res.Properties.String,
+			Integer: res.Properties.Integer,
+			Float:   res.Properties.Float,
+			Boolean: res.Properties.Boolean,
+		},
+	}
+}
+
+// ConvertResourceToV1 converts a resource of v2 to v1, the properties are shared.
+func ConvertResourceToV1(res policy.Resource) v1.Resource {
+	return v1.Resource{
+		Resource: res.Resource,
+		Action:   res.Action,
+		Properties: v1.Property{
+			String:  res.Properties.String,
+			Integer: res.Properties.Integer,
+			Float:   res.Properties.Float,
+			Boolean: res.Properties.Boolean,
+		},
+	}
+}
+
+func convertResult(allowed bool) v1.ResultEffect {
+	if allowed {
+		return v1.ALLOWED
+	}
+	return v1.DENIED
+}
+
+// ValidationController is the ValidationController of v1 on the v2 engine, a v1.Validator that can replace it
+// without other changes to the code.
+type ValidationController struct {
+	Policies            []v1.Policy
+	UserPropertyGetter  v1.UserPropertyGetter
+	ValidationOverrider v1.ValidationOverrider
+	Err                 error
+}
+
+var _ v1.Validator = (*ValidationController)(nil)
+
+// IsAccessAllowed checks if the user is allowed to perform the action on the resource, with the v2 engine.
+func (ctrl *ValidationController) IsAccessAllowed(res v1.Resource) (v1.ResultEffect, error) {
+	pv := policy.New()
+	pv.Policies = ConvertPolicies(ctrl.Policies)
+	pv.UserPropertyGetter = ctrl.UserPropertyGetter
+	if ctrl.ValidationOverrider != nil {
+		pv.ValidationOverrider = FromV1Overrider(ctrl.ValidationOverrider)
+	}
+	pv.SetError(ctrl.Err)
+	pv.SetResource(res.Resource)
+	pv.SetAction(res.Action)
+	pv.AddProperties(ConvertResource(res).Properties)
+
+	allowed, err := pv.IsAccessAllowed()
+	return convertResult(allowed), err
+}
+
+// FromV1Overrider returns a ValidationOverrider of v2 that calls the one of v1, with the policies converted to v1.
+// A policy that cannot be converted is denied with the error of ConvertPoliciesToV1.
+func FromV1Overrider(overrider v1.ValidationOverrider) policy.ValidationOverrider {
+	return v1Overrider{overrider}
+}
+
+type v1Overrider struct {
+	overrider v1.ValidationOverrider
+}
+
+func (o v1Overrider) OverridePolicyValidation(policies []policy.Policy, getter policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+	converted, err := ConvertPoliciesToV1(policies)
+	if err != nil {
+		return policy.DENIED, err
+	}
+	result, err := o.overrider.OverridePolicyValidation(converted, getter, ConvertResourceToV1(res))
+	return result == v1.ALLOWED, err
+}
+
+// ToV1Overrider returns a ValidationOverrider of v1 that calls the one of v2, with the policies converted to v2.
+func ToV1Overrider(overrider policy.ValidationOverrider) v1.ValidationOverrider {
+	return v2Overrider{overrider}
+}
+
+type v2Overrider struct {
+	overrider policy.ValidationOverrider
+}
+
+func (o v2Overrider) OverridePolicyValidation(policies []v1.Policy, getter v1.UserPropertyGetter, res v1.Resource) (v1.ResultEffect, error) {
+	allowed, err := o.overrider.OverridePolicyValidation(ConvertPolicies(policies), getter, ConvertResource(res))
+	return convertResult(allowed), err
+}
+
+// FromV1Validator returns a ValidationOverrider of v2 that decides with a Validator of v1, for a custom Validator
+// to be used as the overrider of the validator of policy.New. The policies of the validator are not used.
+func FromV1Validator(validator v1.Validator) policy.ValidationOverrider {
+	return v1Validator{validator}
+}
+
+type v1Validator struct {
+	validator v1.Validator
+}
+
+func (v v1Validator) OverridePolicyValidation(_ []policy.Policy, _ policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+	result, err := v.validator.IsAccessAllowed(ConvertResourceToV1(res))
+	return result == v1.ALLOWED, err
+}
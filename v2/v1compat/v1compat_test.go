@@ -0,0 +1,379 @@
+package v1compat
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	v1 "github.com/golfz/policy"
+	"github.com/golfz/policy/v2"
+)
+
+type mockUserGetter struct {
+	UserValue map[string]string
+}
+
+func (mock *mockUserGetter) GetUserProperty(key string) string {
+	return mock.UserValue[key]
+}
+
+type mockV1Overrider struct {
+	Result   v1.ResultEffect
+	Error    error
+	Policies []v1.Policy
+	Resource v1.Resource
+}
+
+func (mock *mockV1Overrider) OverridePolicyValidation(policies []v1.Policy, _ v1.UserPropertyGetter, res v1.Resource) (v1.ResultEffect, error) {
+	mock.Policies = policies
+	mock.Resource = res
+	return mock.Result, mock.Error
+}
+
+type mockV2Overrider struct {
+	Result   bool
+	Policies []policy.Policy
+	Resource policy.Resource
+}
+
+func (mock *mockV2Overrider) OverridePolicyValidation(policies []policy.Policy, _ policy.UserPropertyGetter, res policy.Resource) (bool, error) {
+	mock.Policies = policies
+	mock.Resource = res
+	return mock.Result, nil
+}
+
+type mockV1Validator struct {
+	Result   v1.ResultEffect
+	Resource v1.Resource
+}
+
+func (mock *mockV1Validator) IsAccessAllowed(res v1.Resource) (v1.ResultEffect, error) {
+	mock.Resource = res
+	return mock.Result, nil
+}
+
+func mustReadFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestConvertPolicies_StoredPolicies(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"full", "../../test_data/parse_policy/policy_array_full.json"},
+		{"full conditions", "../../test_data/is_access_allowed/1policy_full_conditions.json"},
+		{"nil conditions", "../../test_data/is_access_allowed/1policy_nil_conditions.json"},
+		{"no conditions", "../../test_data/is_access_allowed/1policy_no_conditions.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			b := mustReadFile(t, tt.file)
+			v1Policies, err := v1.ParsePolicyArray(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := policy.ParsePolicyArray(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Act
+			got := ConvertPolicies(v1Policies)
+
+			// Assert
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("want %+v, but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestConvertPolicy_AnyVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+	}{
+		{"no version", 0},
+		{"unknown version", 7},
+		{"negative version", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			p := v1.Policy{Version: tt.version, PolicyID: "p1", Statements: []v1.Statement{
+				{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+			}}
+			pv := policy.New()
+			pv.SetResource("res:::doc")
+			pv.SetAction("act:::doc:read")
+
+			// Act
+			got := ConvertPolicy(p)
+			pv.Policies = []policy.Policy{got}
+			allowed, err := pv.IsAccessAllowed()
+
+			// Assert
+			if got.Version != policy.PolicyVersion1 {
+				t.Errorf("want version %d, but got %d", policy.PolicyVersion1, got.Version)
+			}
+			if !allowed || err != nil {
+				t.Errorf("want true, but got %v, %v", allowed, err)
+			}
+		})
+	}
+}
+
+func TestConvertPoliciesToV1_RoundTrip(t *testing.T) {
+	// Arrange
+	want, err := v1.ParsePolicyArray(mustReadFile(t, "../../test_data/parse_policy/policy_array_full.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Act
+	got, err := ConvertPoliciesToV1(ConvertPolicies(want))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, but got %+v", want, got)
+	}
+}
+
+func TestConvertPoliciesToV1_NotSupported(t *testing.T) {
+	expression, err := policy.CompileExpression("prop.amount < 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		comparator policy.Comparator
+		wantErr    string
+	}{
+		{
+			name:       "Expression",
+			comparator: policy.Comparator{Expression: expression},
+			wantErr:    `policy "p": statement 0: MustHaveAll prop:::amount: Expression is not supported by v1`,
+		},
+		{
+			name:       "ValidationFunc",
+			comparator: policy.Comparator{ValidationFunc: &policy.ValidationFunc{Function: "fn", StringArg: ptr("a")}},
+			wantErr:    `policy "p": statement 0: MustHaveAll prop:::amount: ValidationFunc is not supported by v1`,
+		},
+		{
+			name:       "StringPrefix",
+			comparator: policy.Comparator{StringPrefix: ptr("a")},
+			wantErr:    `policy "p": statement 0: MustHaveAll prop:::amount: StringPrefix is not supported by v1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			policies := []policy.Policy{{PolicyID: "p", Statements: []policy.Statement{{
+				Effect:     "Allow",
+				Resource:   "res:::doc",
+				Actions:    []string{"act:::doc:read"},
+				Conditions: &policy.Condition{MustHaveAll: map[string]policy.Comparator{"prop:::amount": tt.comparator}},
+			}}}}
+
+			// Act
+			got, err := ConvertPoliciesToV1(policies)
+
+			// Assert
+			if got != nil {
+				t.Errorf("want nil, but got %+v", got)
+			}
+			if !errors.Is(err, ErrNotSupportedByV1) || err.Error() != tt.wantErr {
+				t.Errorf("want %q, but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidationController(t *testing.T) {
+	policies, err := v1.ParsePolicyArray(mustReadFile(t, "../../test_data/is_access_allowed/1policy_full_conditions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ctrl     ValidationController
+		resource v1.Resource
+		want     v1.ResultEffect
+		wantErr  bool
+	}{
+		{
+			name: "matched Allow statement",
+			ctrl: ValidationController{Policies: policies},
+			resource: v1.Resource{
+				Resource: "res:::resource_1",
+				Action:   "act:::resource_1:action_1",
+				Properties: v1.Property{
+					String:  map[string]string{"prop:::resource_1:prop_1": "hello"},
+					Integer: map[string]int{"prop:::resource_1:prop_3": 1},
+					Boolean: map[string]bool{"prop:::resource_1:prop_4": true},
+				},
+			},
+			want: v1.ALLOWED,
+		},
+		{
+			name:     "no matched statement",
+			ctrl:     ValidationController{Policies: policies},
+			resource: v1.Resource{Resource: "res:::resource_3", Action: "act:::resource_3:action_1"},
+			want:     v1.DENIED,
+		},
+		{
+			name:     "error",
+			ctrl:     ValidationController{Policies: policies, Err: errors.New("error")},
+			resource: v1.Resource{Resource: "res:::resource_1", Action: "act:::resource_1:action_1"},
+			want:     v1.DENIED,
+			wantErr:  true,
+		},
+		{
+			name:     "overrider",
+			ctrl:     ValidationController{Policies: policies, ValidationOverrider: &mockV1Overrider{Result: v1.ALLOWED}},
+			resource: v1.Resource{Resource: "res:::resource_3", Action: "act:::resource_3:action_1"},
+			want:     v1.ALLOWED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := tt.ctrl.IsAccessAllowed(tt.resource)
+
+			// Assert
+			if got != tt.want {
+				t.Errorf("want %v, but got %v", tt.want, got)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFromV1Overrider(t *testing.T) {
+	// Arrange
+	overrider := &mockV1Overrider{Result: v1.ALLOWED}
+	pv := policy.New()
+	pv.Policies = []policy.Policy{{PolicyID: "p"}}
+	pv.ValidationOverrider = FromV1Overrider(overrider)
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+	pv.AddPropertyString("prop:::doc:owner", "u1")
+
+	// Act
+	got, err := pv.IsAccessAllowed()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got != policy.ALLOWED {
+		t.Errorf("want ALLOWED, but got %v", got)
+	}
+	if len(overrider.Policies) != 1 || overrider.Policies[0].PolicyID != "p" {
+		t.Errorf("want policy p, but got %+v", overrider.Policies)
+	}
+	if overrider.Resource.Resource != "res:::doc" || overrider.Resource.Properties.String["prop:::doc:owner"] != "u1" {
+		t.Errorf("want res:::doc with the properties, but got %+v", overrider.Resource)
+	}
+}
+
+func TestFromV1Overrider_NotSupported(t *testing.T) {
+	// Arrange
+	overrider := &mockV1Overrider{Result: v1.ALLOWED}
+	pv := policy.New()
+	pv.Policies = []policy.Policy{{PolicyID: "p", Statements: []policy.Statement{{
+		Effect:   "Allow",
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &policy.Condition{MustHaveAll: map[string]policy.Comparator{
+			"prop:::doc:owner": {ValidationFunc: &policy.ValidationFunc{Function: "fn", StringArg: ptr("a")}},
+		}},
+	}}}}
+	pv.ValidationOverrider = FromV1Overrider(overrider)
+
+	// Act
+	got, err := pv.IsAccessAllowed()
+
+	// Assert
+	if got != policy.DENIED {
+		t.Errorf("want DENIED, but got %v", got)
+	}
+	if !errors.Is(err, ErrNotSupportedByV1) {
+		t.Errorf("want ErrNotSupportedByV1, but got %v", err)
+	}
+}
+
+func TestToV1Overrider(t *testing.T) {
+	// Arrange
+	overrider := &mockV2Overrider{Result: policy.ALLOWED}
+	ctrl := v1.ValidationController{
+		Policies:            []v1.Policy{{PolicyID: "p"}},
+		ValidationOverrider: ToV1Overrider(overrider),
+	}
+
+	// Act
+	got, err := ctrl.IsAccessAllowed(v1.Resource{Resource: "res:::doc", Action: "act:::doc:read"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got != v1.ALLOWED {
+		t.Errorf("want ALLOWED, but got %v", got)
+	}
+	if len(overrider.Policies) != 1 || overrider.Policies[0].PolicyID != "p" {
+		t.Errorf("want policy p, but got %+v", overrider.Policies)
+	}
+	if overrider.Resource.Action != "act:::doc:read" {
+		t.Errorf("want act:::doc:read, but got %v", overrider.Resource.Action)
+	}
+}
+
+func TestFromV1Validator(t *testing.T) {
+	// Arrange
+	validator := &mockV1Validator{Result: v1.DENIED}
+	pv := policy.New()
+	pv.Policies = []policy.Policy{{PolicyID: "p", Statements: []policy.Statement{
+		{Effect: "Allow", Resource: "res:::doc", Actions: []string{"act:::doc:read"}},
+	}}}
+	pv.ValidationOverrider = FromV1Validator(validator)
+	pv.SetResource("res:::doc")
+	pv.SetAction("act:::doc:read")
+
+	// Act
+	got, err := pv.IsAccessAllowed()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("want nil, but got %v", err)
+	}
+	if got != policy.DENIED {
+		t.Errorf("want DENIED, but got %v", got)
+	}
+	if validator.Resource.Resource != "res:::doc" || validator.Resource.Action != "act:::doc:read" {
+		t.Errorf("want res:::doc and act:::doc:read, but got %+v", validator.Resource)
+	}
+}
@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	resourcePrefix     = "res:::"
+	actionPrefix       = "act:::"
+	userPropertyPrefix = "user:::"
+)
+
+// ValidatePolicy checks that the policy follows the policy syntax of its Version, all problems are joined in the returned error.
+// It is stricter than IsAccessAllowed, which only rejects an unsupported Version, a comparator of a later version
+// and an invalid effect.
+func ValidatePolicy(p Policy) error {
+	var errs []error
+	spec, err := policySpecOf(p.Version)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("policy %q: %w", p.PolicyID, err))
+		// the statements are checked with all comparators
+		spec, _ = policySpecOf(LatestPolicyVersion)
+	}
+	for i, stmt := range p.Statements {
+		for _, err := range append(validateStatement(stmt), spec.checkConditions(stmt.Conditions)...) {
+			errs = append(errs, fmt.Errorf("policy %q: statement %d: %w", p.PolicyID, i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidatePolicies checks every policy with ValidatePolicy, and that no PolicyID is used twice.
+func ValidatePolicies(policies []Policy) error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, p := range policies {
+		if p.PolicyID != "" && seen[p.PolicyID] {
+			errs = append(errs, fmt.Errorf("policy %q: duplicate PolicyID", p.PolicyID))
+		}
+		seen[p.PolicyID] = true
+		if err := ValidatePolicy(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateStatement(stmt Statement) []error {
+	var errs []error
+	if !isValidEffect(stmt.Effect) {
+		errs = append(errs, fmt.Errorf("invalid effect: %s", stmt.Effect))
+	}
+	if !strings.HasPrefix(stmt.Resource, resourcePrefix) {
+		errs = append(errs, fmt.Errorf("invalid resource: %q must start with %q", stmt.Resource, resourcePrefix))
+	}
+	if len(stmt.Actions) == 0 {
+		errs = append(errs, errors.New("no actions"))
+	}
+	for _, action := range stmt.Actions {
+		if !strings.HasPrefix(action, actionPrefix) {
+			errs = append(errs, fmt.Errorf("invalid action: %q must start with %q", action, actionPrefix))
+		}
+	}
+
+	if stmt.Conditions == nil {
+		return errs
+	}
+	for _, key := range sortedKeys(stmt.Conditions.AtLeastOne) {
+		for _, err := range validateComparator(stmt.Conditions.AtLeastOne[key]) {
+			errs = append(errs, fmt.Errorf("AtLeastOne %s: %w", key, err))
+		}
+	}
+	for _, key := range sortedKeys(stmt.Conditions.MustHaveAll) {
+		for _, err := range validateComparator(stmt.Conditions.MustHaveAll[key]) {
+			errs = append(errs, fmt.Errorf("MustHaveAll %s: %w", key, err))
+		}
+	}
+	return errs
+}
+
+func validateComparator(comparator Comparator) []error {
+	var errs []error
+	if comparator.UserPropEqual != nil && !strings.HasPrefix(*comparator.UserPropEqual, userPropertyPrefix) {
+		errs = append(errs, fmt.Errorf("invalid UserPropEqual: %q must start with %q", *comparator.UserPropEqual, userPropertyPrefix))
+	}
+	if comparator.ValidationFunc != nil {
+		if comparator.ValidationFunc.Function == "" {
+			errs = append(errs, errors.New("invalid ValidationFunc: no function"))
+		}
+		if !comparator.ValidationFunc.IsValid() {
+			errs = append(errs, errors.New("invalid ValidationFunc: must have exactly one of PropArg, UserArg and StringArg"))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	valid := func() Statement {
+		return Statement{
+			Effect:   statementEffectAllow,
+			Resource: "res:::doc",
+			Actions:  []string{"act:::doc:read"},
+			Conditions: &Condition{
+				MustHaveAll: map[string]Comparator{
+					"prop:::doc:owner": {UserPropEqual: ptr("user:::id")},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(stmt *Statement)
+		wantErr []string
+	}{
+		{
+			name:   "valid statement",
+			modify: func(stmt *Statement) {},
+		},
+		{
+			name:    "invalid effect",
+			modify:  func(stmt *Statement) { stmt.Effect = "maybe" },
+			wantErr: []string{"invalid effect: maybe"},
+		},
+		{
+			name:    "resource without prefix",
+			modify:  func(stmt *Statement) { stmt.Resource = "doc" },
+			wantErr: []string{`invalid resource: "doc"`},
+		},
+		{
+			name:    "no actions",
+			modify:  func(stmt *Statement) { stmt.Actions = nil },
+			wantErr: []string{"no actions"},
+		},
+		{
+			name:    "action without prefix",
+			modify:  func(stmt *Statement) { stmt.Actions = []string{"act:::doc:read", "read"} },
+			wantErr: []string{`invalid action: "read"`},
+		},
+		{
+			name: "UserPropEqual without prefix",
+			modify: func(stmt *Statement) {
+				stmt.Conditions.AtLeastOne = map[string]Comparator{"prop:::doc:owner": {UserPropEqual: ptr("id")}}
+			},
+			wantErr: []string{`AtLeastOne prop:::doc:owner: invalid UserPropEqual: "id"`},
+		},
+		{
+			name: "ValidationFunc without function and argument",
+			modify: func(stmt *Statement) {
+				stmt.Conditions.MustHaveAll["prop:::doc:owner"] = Comparator{ValidationFunc: &ValidationFunc{}}
+			},
+			wantErr: []string{"MustHaveAll prop:::doc:owner: invalid ValidationFunc: no function", "MustHaveAll prop:::doc:owner: invalid ValidationFunc: must have exactly one"},
+		},
+		{
+			name: "StringPrefix of version 2",
+			modify: func(stmt *Statement) {
+				stmt.Conditions.MustHaveAll["prop:::doc:path"] = Comparator{StringPrefix: ptr("/docs/")}
+			},
+			wantErr: []string{"MustHaveAll prop:::doc:path: StringPrefix requires Version 2"},
+		},
+		{
+			name: "several problems",
+			modify: func(stmt *Statement) {
+				stmt.Effect = ""
+				stmt.Resource = ""
+			},
+			wantErr: []string{"invalid effect", "invalid resource"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			stmt := valid()
+			tt.modify(&stmt)
+			p := Policy{PolicyID: "p1", Statements: []Statement{valid(), stmt}}
+
+			// Act
+			err := ValidatePolicy(p)
+
+			// Assert
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("want nil, but got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("want error, but got nil")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), `policy "p1": statement 1: `+want) {
+					t.Errorf("want %q in error, but got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePolicy_Version(t *testing.T) {
+	stmt := Statement{
+		Effect:   statementEffectAllow,
+		Resource: "res:::doc",
+		Actions:  []string{"act:::doc:read"},
+		Conditions: &Condition{
+			MustHaveAll: map[string]Comparator{"prop:::doc:path": {StringPrefix: ptr("/docs/")}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		version int
+		wantErr string
+	}{
+		{"version 2", PolicyVersion2, ""},
+		{"unsupported version", 3, `policy "p1": unsupported Version 3, the versions are 1 to 2`},
+		{"negative version", -1, `policy "p1": unsupported Version -1, the versions are 1 to 2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			p := Policy{Version: tt.version, PolicyID: "p1", Statements: []Statement{stmt}}
+
+			// Act
+			err := ValidatePolicy(p)
+
+			// Assert
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("want nil, but got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("want %q, but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatePolicies_DuplicatePolicyID(t *testing.T) {
+	// Arrange
+	policies := []Policy{{PolicyID: "p1"}, {PolicyID: "p2"}, {PolicyID: "p1"}, {}, {}}
+
+	// Act
+	err := ValidatePolicies(policies)
+
+	// Assert
+	if err == nil || err.Error() != `policy "p1": duplicate PolicyID` {
+		t.Errorf("want duplicate PolicyID error, but got %v", err)
+	}
+}